@@ -0,0 +1,410 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultEvaluationInterval = 30 * time.Second
+
+	// The nodes silent for longer than this are forgotten along with their alerts
+	DefaultNodeTTL = 7 * 24 * time.Hour
+
+	// Room for the events waiting to be stored, the evaluation does not wait for the database
+	eventQueueSize = 1024
+)
+
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Notifier is told about every transition of the alerts, it must not block
+type Notifier interface {
+	Notify(event models.AlertEvent)
+}
+
+type alertKey struct {
+	rule   string
+	nodeId string
+}
+
+type alertState struct {
+	pendingSince time.Time // when the node started to meet the condition
+	value        float64
+	firing       *models.AlertEvent // nil while pending
+}
+
+type seenNode struct {
+	nodeType receiver.NodeType
+	lastSeen time.Time
+}
+
+// Engine evaluates the rules on every written sample, and on a timer for the nodes which went silent.
+// The transitions are stored as alert events and handed to the notifier.
+type Engine struct {
+	metrics  *metrics.Metrics
+	rules    []Rule
+	notifier Notifier
+	nodeTTL  time.Duration
+	logger   *zap.Logger
+
+	mu     sync.Mutex
+	nodes  map[string]seenNode
+	alerts map[alertKey]*alertState
+
+	events chan models.AlertEvent // to be stored
+}
+
+// NewEngine checks the rules, notifier may be nil when the events are only stored
+func NewEngine(mt *metrics.Metrics, rules []Rule, notifier Notifier, logger *zap.Logger) (*Engine, error) {
+
+	if problems := ValidateRules(rules); len(problems) > 0 {
+		return nil, fmt.Errorf("alerting rules: %v", problems)
+	}
+
+	e := &Engine{
+		metrics:  mt,
+		rules:    make([]Rule, len(rules)),
+		notifier: notifier,
+		nodeTTL:  DefaultNodeTTL,
+		logger:   logger,
+		nodes:    map[string]seenNode{},
+		alerts:   map[alertKey]*alertState{},
+		events:   make(chan models.AlertEvent, eventQueueSize),
+	}
+	for i, r := range rules {
+		if r.NodeType != "" {
+			nType, _ := metrics.ParseNodeType(r.NodeType) // validated above
+			r.nodeType = &nType
+		}
+		e.rules[i] = r
+	}
+
+	return e, nil
+}
+
+// SetNodeTTL sets how long a silent node is kept, it must be called before Restore
+func (e *Engine) SetNodeTTL(ttl time.Duration) {
+	if ttl > 0 {
+		e.nodeTTL = ttl
+	}
+}
+
+// Rules returns the rules the engine evaluates
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Active returns the firing alerts, the oldest first
+func (e *Engine) Active() []models.AlertEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	res := []models.AlertEvent{}
+	for _, st := range e.alerts {
+		if st.firing != nil {
+			ev := *st.firing
+			ev.Value = st.value
+			res = append(res, ev)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	return res
+}
+
+// Restore brings back the alerts which were firing when the service stopped, and the nodes
+// seen within the node TTL, so the silent nodes are caught without waiting for a sample.
+// The alerts of the rules which do not exist anymore and of the forgotten nodes are resolved.
+// The events are stored before it returns.
+func (e *Engine) Restore() error {
+
+	now := time.Now()
+	states, err := e.metrics.GetLatestNodeStates(now.Add(-e.nodeTTL))
+	if err != nil {
+		return err
+	}
+	last, err := e.metrics.GetLastAlertEvents()
+	if err != nil {
+		return err
+	}
+
+	var events []models.AlertEvent
+
+	e.mu.Lock()
+	for _, s := range states {
+		e.nodes[s.NodeId] = seenNode{nodeType: s.NodeType, lastSeen: s.LastSeenAt}
+	}
+	for _, ev := range last {
+		if ev.State != StateFiring {
+			continue
+		}
+		if e.rule(ev.Rule) == nil {
+			events = append(events, resolvedEvent(ev, ev.Value, "rule removed", now))
+			continue
+		}
+		if _, ok := e.nodes[ev.NodeId]; !ok {
+			events = append(events, resolvedEvent(ev, ev.Value, "node forgotten", now))
+			continue
+		}
+		ev := ev
+		e.alerts[alertKey{ev.Rule, ev.NodeId}] = &alertState{
+			pendingSince: ev.CreatedAt,
+			value:        ev.Value,
+			firing:       &ev,
+		}
+	}
+	e.mu.Unlock()
+
+	for _, ev := range events {
+		e.notify(ev)
+		e.store(ev)
+	}
+	return nil
+}
+
+// Run evaluates the rules until ctx is done
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+
+	if interval <= 0 {
+		interval = DefaultEvaluationInterval
+	}
+
+	// the buffer of the subscription holds a few insert batches, it is dropped only when the evaluation really lags behind
+	sub := e.metrics.Hub.Subscribe(metrics.SampleFilter{})
+	defer func() {
+		e.metrics.Hub.Unsubscribe(sub)
+	}()
+
+	// the events are stored aside, the ones left are stored before returning
+	stored := make(chan struct{})
+	go e.storeEvents(stored)
+	defer func() {
+		close(e.events)
+		<-stored
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case node, ok := <-sub.Samples():
+			if !ok {
+				// dropped for lagging behind, the missed samples are caught up by the next ones
+				e.logger.Warn("alerting: fell behind the samples, subscribing again")
+				sub = e.metrics.Hub.Subscribe(metrics.SampleFilter{})
+				continue
+			}
+			e.emit(e.evaluateSample(node, time.Now()))
+
+		case now := <-ticker.C:
+			e.emit(e.evaluateTimer(now))
+		}
+	}
+}
+
+// evaluateSample checks the rules on a new sample of a node
+func (e *Engine) evaluateSample(node *models.CelestiaNode, now time.Time) []models.AlertEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	seenAt := node.CreatedAt
+	if seenAt.IsZero() {
+		seenAt = now
+	}
+	if prev, ok := e.nodes[node.NodeId]; !ok || prev.lastSeen.Before(seenAt) {
+		e.nodes[node.NodeId] = seenNode{nodeType: node.NodeType, lastSeen: seenAt}
+	}
+
+	var events []models.AlertEvent
+	for i := range e.rules {
+		r := &e.rules[i]
+		if !r.matches(node.NodeId, node.NodeType) {
+			continue
+		}
+
+		var value float64
+		var met bool
+		if r.Condition == ConditionNoData {
+			value, met = r.checkAge(now.Sub(e.nodes[node.NodeId].lastSeen))
+		} else {
+			value, met = r.check(node)
+		}
+		if ev := e.transition(r, node.NodeId, node.NodeType, value, met, now); ev != nil {
+			events = append(events, *ev)
+		}
+	}
+	return events
+}
+
+// evaluateTimer forgets the nodes silent for longer than the node TTL, checks the silent nodes
+// against the no_data rules, and fires the pending alerts which have met their condition long enough
+func (e *Engine) evaluateTimer(now time.Time) []models.AlertEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	events := e.evictNodes(now)
+	for i := range e.rules {
+		r := &e.rules[i]
+
+		if r.Condition == ConditionNoData {
+			for nodeId, n := range e.nodes {
+				if !r.matches(nodeId, n.nodeType) {
+					continue
+				}
+				value, met := r.checkAge(now.Sub(n.lastSeen))
+				if ev := e.transition(r, nodeId, n.nodeType, value, met, now); ev != nil {
+					events = append(events, *ev)
+				}
+			}
+			continue
+		}
+
+		for key, st := range e.alerts {
+			if key.rule != r.Name || st.firing != nil {
+				continue
+			}
+			if ev := e.transition(r, key.nodeId, e.nodes[key.nodeId].nodeType, st.value, true, now); ev != nil {
+				events = append(events, *ev)
+			}
+		}
+	}
+	return events
+}
+
+// evictNodes forgets the nodes silent for longer than the node TTL, their firing alerts are resolved
+func (e *Engine) evictNodes(now time.Time) []models.AlertEvent {
+
+	var events []models.AlertEvent
+	for nodeId, n := range e.nodes {
+		if now.Sub(n.lastSeen) <= e.nodeTTL {
+			continue
+		}
+		delete(e.nodes, nodeId)
+
+		for _, r := range e.rules {
+			key := alertKey{r.Name, nodeId}
+			st, ok := e.alerts[key]
+			if !ok {
+				continue
+			}
+			delete(e.alerts, key)
+			if st.firing != nil {
+				events = append(events, resolvedEvent(*st.firing, st.value, "node forgotten", now))
+			}
+		}
+	}
+	return events
+}
+
+// transition moves the alert of a rule and a node to its next state,
+// it returns the event to emit if the alert fired or resolved
+func (e *Engine) transition(r *Rule, nodeId string, nodeType receiver.NodeType, value float64, met bool, now time.Time) *models.AlertEvent {
+
+	key := alertKey{r.Name, nodeId}
+	st := e.alerts[key]
+
+	if !met {
+		if st == nil {
+			return nil
+		}
+		delete(e.alerts, key)
+		if st.firing == nil {
+			return nil
+		}
+		ev := resolvedEvent(*st.firing, value, r.describe(value), now)
+		return &ev
+	}
+
+	if st == nil {
+		st = &alertState{pendingSince: now}
+		e.alerts[key] = st
+	}
+	st.value = value
+
+	if st.firing != nil || now.Sub(st.pendingSince) < r.For {
+		return nil
+	}
+
+	st.firing = &models.AlertEvent{
+		Rule:      r.Name,
+		NodeId:    nodeId,
+		NodeType:  nodeType,
+		State:     StateFiring,
+		Value:     value,
+		Threshold: r.Threshold,
+		Message:   r.describe(value),
+		CreatedAt: now,
+	}
+	return st.firing
+}
+
+// emit notifies the events and queues them to be stored, it does not wait for the database
+func (e *Engine) emit(events []models.AlertEvent) {
+	for _, ev := range events {
+		e.notify(ev)
+		select {
+		case e.events <- ev:
+		default:
+			e.logger.Error(fmt.Sprintf("alerting: the event queue is full, the %s event of %s/%s is not stored", ev.State, ev.Rule, ev.NodeId))
+		}
+	}
+}
+
+// storeEvents stores the queued events until the queue is closed
+func (e *Engine) storeEvents(done chan<- struct{}) {
+	defer close(done)
+
+	for ev := range e.events {
+		e.store(ev)
+	}
+}
+
+func (e *Engine) store(ev models.AlertEvent) {
+	if err := e.metrics.AddAlertEvent(&ev); err != nil {
+		e.logger.Error(fmt.Sprintf("alerting: storing the %s event of %s/%s: %v", ev.State, ev.Rule, ev.NodeId, err))
+	}
+}
+
+func (e *Engine) notify(ev models.AlertEvent) {
+	if e.notifier != nil {
+		e.notifier.Notify(ev)
+	}
+}
+
+func (e *Engine) rule(name string) *Rule {
+	for i := range e.rules {
+		if e.rules[i].Name == name {
+			return &e.rules[i]
+		}
+	}
+	return nil
+}
+
+func resolvedEvent(firing models.AlertEvent, value float64, message string, now time.Time) models.AlertEvent {
+	return models.AlertEvent{
+		Rule:      firing.Rule,
+		NodeId:    firing.NodeId,
+		NodeType:  firing.NodeType,
+		State:     StateResolved,
+		Value:     value,
+		Threshold: firing.Threshold,
+		Message:   message,
+		CreatedAt: now,
+	}
+}
@@ -0,0 +1,174 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"go.uber.org/zap"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []models.AlertEvent
+}
+
+func (r *recorder) Notify(ev models.AlertEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func newTestEngine(t *testing.T) (*Engine, *metrics.Metrics, *recorder) {
+	t.Helper()
+
+	store, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "nodelogger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mt := metrics.NewWithStorage(store)
+
+	rec := &recorder{}
+	e, err := NewEngine(mt, []Rule{{Name: "silent", Condition: ConditionNoData, Threshold: 60}}, rec, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, mt, rec
+}
+
+func TestEngineForgetsSilentNodes(t *testing.T) {
+
+	e, mt, rec := newTestEngine(t)
+	e.SetNodeTTL(24 * time.Hour)
+
+	now := time.Now()
+	err := mt.AddNodeDataBatch([]*models.CelestiaNode{
+		{NodeId: "gone", CreatedAt: now.Add(-48 * time.Hour)},
+		{NodeId: "silent", CreatedAt: now.Add(-time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, nodeId := range []string{"gone", "silent"} {
+		if err := mt.AddAlertEvent(&models.AlertEvent{Rule: "silent", NodeId: nodeId, State: StateFiring, CreatedAt: now.Add(-time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the node gone for longer than the TTL is not restored and its alert is resolved
+	if err := e.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.nodes["gone"]; ok || len(e.nodes) != 1 {
+		t.Errorf("nodes restored %v, want the silent one only", e.nodes)
+	}
+	if active := e.Active(); len(active) != 1 || active[0].NodeId != "silent" {
+		t.Errorf("active alerts %+v, want the one of the silent node", active)
+	}
+	if len(rec.events) != 1 || rec.events[0].NodeId != "gone" || rec.events[0].State != StateResolved {
+		t.Errorf("events %+v, want the alert of the gone node resolved", rec.events)
+	}
+	last, err := mt.GetLastAlertEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range last {
+		if ev.NodeId == "gone" && ev.State != StateResolved {
+			t.Errorf("last event of the gone node %+v, want it resolved", ev)
+		}
+	}
+
+	// once silent for longer than the TTL, the node is forgotten and its alert resolved
+	events := e.evaluateTimer(now.Add(25 * time.Hour))
+	if len(events) != 1 || events[0].NodeId != "silent" || events[0].State != StateResolved {
+		t.Errorf("events %+v, want the alert of the silent node resolved", events)
+	}
+	if len(e.nodes) != 0 || len(e.Active()) != 0 {
+		t.Errorf("%d nodes and %d alerts left, want none", len(e.nodes), len(e.Active()))
+	}
+}
+
+func TestEngineStoresEventsAside(t *testing.T) {
+
+	e, mt, rec := newTestEngine(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		e.Run(ctx, time.Hour)
+		close(stopped)
+	}()
+
+	e.emit([]models.AlertEvent{
+		{Rule: "silent", NodeId: "node-1", State: StateFiring},
+		{Rule: "silent", NodeId: "node-2", State: StateFiring},
+	})
+	cancel()
+	<-stopped
+
+	// notified right away, and all stored by the time Run returns
+	if len(rec.events) != 2 {
+		t.Errorf("%d events notified, want 2", len(rec.events))
+	}
+	stored, _, err := mt.GetAlertEvents(metrics.AlertEventFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Errorf("%d events stored, want 2", len(stored))
+	}
+}
+
+// A full insert batch is evaluated sample by sample, the engine is not dropped from the hub on the way
+func TestEngineEvaluatesFullBatches(t *testing.T) {
+
+	e, mt, _ := newTestEngine(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		e.Run(ctx, time.Hour)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	for mt.Hub.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	now := time.Now()
+	for b := 0; b < 2; b++ {
+		batch := make([]*models.CelestiaNode, metrics.DefaultInsertBatchSize)
+		for i := range batch {
+			batch[i] = &models.CelestiaNode{NodeId: fmt.Sprintf("node-%d-%d", b, i), CreatedAt: now}
+		}
+		mt.Hub.Publish(batch)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		e.mu.Lock()
+		seen := len(e.nodes)
+		e.mu.Unlock()
+
+		if seen == 2*metrics.DefaultInsertBatchSize {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d nodes evaluated, want %d", seen, 2*metrics.DefaultInsertBatchSize)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if mt.Hub.Len() != 1 {
+		t.Errorf("%d subscribers, want the engine still subscribed", mt.Hub.Len())
+	}
+}
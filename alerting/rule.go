@@ -0,0 +1,141 @@
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// Condition is what a rule watches on the nodes
+type Condition string
+
+const (
+	ConditionNoData      Condition = "no_data"      // seconds since the last sample of the node
+	ConditionSyncLag     Condition = "sync_lag"     // blocks between the network height and the head of the node
+	ConditionDASLag      Condition = "das_lag"      // blocks between the DAS network head and the sampled chain head
+	ConditionUptimeBelow Condition = "uptime_below" // uptime percentage of the node
+)
+
+// Rule fires an alert for every node which meets its condition for at least the `for` duration
+type Rule struct {
+	Name      string        `mapstructure:"name" yaml:"name" json:"name"`
+	Condition Condition     `mapstructure:"condition" yaml:"condition" json:"condition"`
+	Threshold float64       `mapstructure:"threshold" yaml:"threshold" json:"threshold"`
+	For       time.Duration `mapstructure:"for" yaml:"for" json:"for"`
+	NodeType  string        `mapstructure:"node_type" yaml:"node_type" json:"node_type,omitempty"` // bridge, full or light, empty for all the nodes
+	NodeId    string        `mapstructure:"node_id" yaml:"node_id" json:"node_id,omitempty"`       // empty for all the nodes
+
+	nodeType *receiver.NodeType
+}
+
+// MarshalJSON writes the `for` duration as a string, e.g. 5m0s
+func (r Rule) MarshalJSON() ([]byte, error) {
+	type rule Rule
+	return json.Marshal(struct {
+		rule
+		For string `json:"for"`
+	}{rule(r), r.For.String()})
+}
+
+// ValidateRules checks the rules are well formed and their names are unique
+func ValidateRules(rules []Rule) []string {
+
+	var problems []string
+	names := map[string]struct{}{}
+
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("rule %s: the name is required", name))
+		} else if _, ok := names[name]; ok {
+			problems = append(problems, fmt.Sprintf("rule %s: the name is used twice", name))
+		}
+		names[name] = struct{}{}
+
+		switch r.Condition {
+		case ConditionNoData:
+			if r.Threshold <= 0 {
+				problems = append(problems, fmt.Sprintf("rule %s: the threshold must be positive", name))
+			}
+		case ConditionSyncLag, ConditionDASLag:
+			if r.Threshold < 0 {
+				problems = append(problems, fmt.Sprintf("rule %s: the threshold must not be negative", name))
+			}
+		case ConditionUptimeBelow:
+			if r.Threshold <= 0 || r.Threshold > 100 {
+				problems = append(problems, fmt.Sprintf("rule %s: the threshold must be a percentage in (0, 100]", name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("rule %s: unknown condition %q, expected one of no_data, sync_lag, das_lag, uptime_below", name, r.Condition))
+		}
+
+		if r.For < 0 {
+			problems = append(problems, fmt.Sprintf("rule %s: `for` must not be negative", name))
+		}
+		if r.NodeType != "" {
+			if _, err := metrics.ParseNodeType(r.NodeType); err != nil {
+				problems = append(problems, fmt.Sprintf("rule %s: %v", name, err))
+			}
+		}
+	}
+
+	return problems
+}
+
+// matches tells if the rule watches the node
+func (r *Rule) matches(nodeId string, nodeType receiver.NodeType) bool {
+	if r.NodeId != "" && r.NodeId != nodeId {
+		return false
+	}
+	if r.nodeType != nil && *r.nodeType != nodeType {
+		return false
+	}
+	return true
+}
+
+// check returns the value the rule watches on a sample and if it meets the condition,
+// the no_data rules are checked on the timer with checkAge instead
+func (r *Rule) check(node *models.CelestiaNode) (float64, bool) {
+	switch r.Condition {
+	case ConditionSyncLag:
+		lag := lagOf(node.NetworkHeight, node.Head)
+		return lag, lag > r.Threshold
+	case ConditionDASLag:
+		lag := lagOf(node.DasNetworkHead, node.DasSampledChainHead)
+		return lag, lag > r.Threshold
+	case ConditionUptimeBelow:
+		return float64(node.Uptime), float64(node.Uptime) < r.Threshold
+	}
+	return 0, false
+}
+
+func (r *Rule) checkAge(age time.Duration) (float64, bool) {
+	return age.Seconds(), age.Seconds() > r.Threshold
+}
+
+func (r *Rule) describe(value float64) string {
+	switch r.Condition {
+	case ConditionNoData:
+		return fmt.Sprintf("no sample for %s (threshold %s)", time.Duration(value*float64(time.Second)).Round(time.Second), time.Duration(r.Threshold*float64(time.Second)))
+	case ConditionSyncLag:
+		return fmt.Sprintf("head is %.0f blocks behind the network height (threshold %.0f)", value, r.Threshold)
+	case ConditionDASLag:
+		return fmt.Sprintf("DAS is %.0f blocks behind the network head (threshold %.0f)", value, r.Threshold)
+	case ConditionUptimeBelow:
+		return fmt.Sprintf("uptime is %.2f%% (target %.2f%%)", value, r.Threshold)
+	}
+	return ""
+}
+
+// lagOf returns how far behind is behind of ahead, a node ahead of the network is not lagging
+func lagOf(ahead, behind uint64) float64 {
+	if behind >= ahead {
+		return 0
+	}
+	return float64(ahead - behind)
+}
@@ -0,0 +1,130 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"go.uber.org/zap"
+)
+
+const (
+	webhookQueueSize = 256
+	webhookTimeout   = 10 * time.Second
+	webhookAttempts  = 3
+)
+
+// WebhookPayload is the JSON body posted to the webhook on every transition of an alert
+type WebhookPayload struct {
+	State     string    `json:"state"` // firing or resolved
+	Rule      string    `json:"rule"`
+	NodeId    string    `json:"node_id"`
+	NodeType  string    `json:"node_type"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Message   string    `json:"message"`
+	At        time.Time `json:"at"`
+}
+
+// Webhook posts the alert events to a URL. The events are queued so the engine is never held
+// by a slow receiver, they are dropped when the queue is full.
+type Webhook struct {
+	url    string
+	client *http.Client
+	events chan models.AlertEvent
+	logger *zap.Logger
+}
+
+var _ Notifier = (*Webhook)(nil)
+
+func NewWebhook(url string, logger *zap.Logger) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+		events: make(chan models.AlertEvent, webhookQueueSize),
+		logger: logger,
+	}
+}
+
+func (w *Webhook) Notify(event models.AlertEvent) {
+	select {
+	case w.events <- event:
+	default:
+		w.logger.Error(fmt.Sprintf("alerting webhook: queue full, the %s event of %s/%s is dropped", event.State, event.Rule, event.NodeId))
+	}
+}
+
+// Run posts the queued events until ctx is done
+func (w *Webhook) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-w.events:
+			if err := w.send(ctx, ev); err != nil {
+				w.logger.Error(fmt.Sprintf("alerting webhook: %v", err))
+			}
+		}
+	}
+}
+
+// send posts an event, retrying with a backoff when the receiver fails
+func (w *Webhook) send(ctx context.Context, event models.AlertEvent) error {
+
+	body, err := json.Marshal(WebhookPayload{
+		State:     event.State,
+		Rule:      event.Rule,
+		NodeId:    event.NodeId,
+		NodeType:  event.NodeType.String(),
+		Value:     event.Value,
+		Threshold: event.Threshold,
+		Message:   event.Message,
+		At:        event.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, body)
+		if err == nil || attempt == webhookAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("the %s event of %s/%s is not delivered: %v", event.State, event.Rule, event.NodeId, err)
+	}
+	return nil
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/celestiaorg/nodelogger/alerting"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+)
+
+// SetAlerting sets the engine the active alerts are read from and registers the alert endpoints
+func (a *RESTApiV1) SetAlerting(engine *alerting.Engine) {
+	a.alerts = engine
+
+	a.router.HandleFunc(path("/alerts"), a.GetActiveAlerts).Methods("GET")
+	a.router.HandleFunc(path("/alerts/rules"), a.GetAlertRules).Methods("GET")
+	a.router.HandleFunc(path("/alerts/history"), a.GetAlertHistory).Methods("GET")
+}
+
+// GetActiveAlerts implements GET /alerts
+func (a *RESTApiV1) GetActiveAlerts(resp http.ResponseWriter, req *http.Request) {
+
+	err := sendJSON(resp, a.alerts.Active())
+	a.logger.Info(fmt.Sprintf("api call `GetActiveAlerts` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetActiveAlerts`: %v", err))
+	}
+}
+
+// GetAlertRules implements GET /alerts/rules
+func (a *RESTApiV1) GetAlertRules(resp http.ResponseWriter, req *http.Request) {
+
+	err := sendJSON(resp, a.alerts.Rules())
+	a.logger.Info(fmt.Sprintf("api call `GetAlertRules` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetAlertRules`: %v", err))
+	}
+}
+
+// GetAlertHistory implements GET /alerts/history?rule={name}&node_id={id}&state={firing|resolved}&from={RFC3339}&to={RFC3339}&page={page}
+func (a *RESTApiV1) GetAlertHistory(resp http.ResponseWriter, req *http.Request) {
+
+	from, to, err := getTimeRangeFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetAlertHistory`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := req.URL.Query()
+	state := query.Get("state")
+	if state != "" && state != alerting.StateFiring && state != alerting.StateResolved {
+		err = fmt.Errorf("unknown state %q, expected firing or resolved", state)
+		a.logger.Info(fmt.Sprintf("api `GetAlertHistory`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validNodeId("node_id", query.Get("node_id")); err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetAlertHistory`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := metrics.AlertEventFilter{
+		Rule:   query.Get("rule"),
+		NodeId: query.Get("node_id"),
+		State:  state,
+		From:   from,
+		To:     to,
+	}
+
+	limitOffset := a.getLimitOffsetFromHttpReq(req)
+	rows, totalRows, err := a.metrics.GetAlertEvents(filter, int(limitOffset.Offset), int(limitOffset.Limit))
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetAlertHistory`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"pagination": a.getPagination(uint64(totalRows), limitOffset),
+			"rows":       rows,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetAlertHistory` %v", req.URL.Path))
+	a.logger.Debug(fmt.Sprintf("api call `GetAlertHistory` filter: %#v limitOffset: %#v totalRows: %v", filter, limitOffset, totalRows))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetAlertHistory`: %v", err))
+	}
+}
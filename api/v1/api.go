@@ -1,12 +1,14 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
+	"time"
 
 	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/telemetry"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
@@ -16,25 +18,38 @@ func path(endpoint string) string {
 	return fmt.Sprintf("/api/v1%s", endpoint)
 }
 
+const (
+	DefaultRowsPerPage    = 100
+	DefaultMaxRowsPerPage = 1000 // largest page a client can ask for with ?limit=
+	DefaultUIPath         = "./"
+)
+
 func NewRESTApiV1(mt *metrics.Metrics, logger *zap.Logger) *RESTApiV1 {
 
-	rowsPerPageStr := os.Getenv("API_ROWS_PER_PAGE")
-	rowsPerPage := uint64(100)
-	if rowsPerPageStr == "" {
-		num, err := strconv.ParseUint(rowsPerPageStr, 10, 32)
-		if err == nil {
-			rowsPerPage = num
-		}
-	}
+	streams, stopStreams := context.WithCancel(context.Background())
 
 	api := &RESTApiV1{
-		router:      mux.NewRouter(),
-		logger:      logger,
-		metrics:     mt,
-		rowsPerPage: rowsPerPage,
+		router:         mux.NewRouter(),
+		logger:         logger,
+		metrics:        mt,
+		rowsPerPage:    DefaultRowsPerPage,
+		maxRowsPerPage: DefaultMaxRowsPerPage,
+		startedAt:      time.Now(),
+		uiPath:         DefaultUIPath,
+		streams:        streams,
+		stopStreams:    stopStreams,
+
+		readyMaxQueueDepth: DefaultReadyMaxQueueDepth,
+		readyMaxDataAge:    DefaultReadyMaxDataAge,
 	}
 
+	api.router.Use(api.instrument)
+	api.router.Use(api.checkNodeId)
+
 	api.router.HandleFunc("/", api.IndexPage).Methods("GET")
+	api.router.Handle("/metrics", telemetry.Handler()).Methods("GET") // nodelogger own metrics
+	api.router.HandleFunc("/healthz", api.GetHealthz).Methods("GET")
+	api.router.HandleFunc("/readyz", api.GetReadyz).Methods("GET")
 	// api.router.HandleFunc("/ui", api.UI).Methods("GET")
 
 	api.router.HandleFunc(path("/metrics/nodes"), api.GetAllNodes).Methods("GET")
@@ -42,17 +57,58 @@ func NewRESTApiV1(mt *metrics.Metrics, logger *zap.Logger) *RESTApiV1 {
 	api.router.HandleFunc(path("/metrics/nodes/full"), api.GetFullNodes).Methods("GET")
 	api.router.HandleFunc(path("/metrics/nodes/light"), api.GetLightNodes).Methods("GET")
 	api.router.HandleFunc(path("/metrics/nodes/{id}"), api.GetNodeById).Methods("GET")
+	api.router.HandleFunc(path("/metrics/nodes/{id}/history"), api.GetNodeHistoryById).Methods("GET")
 
 	api.router.HandleFunc(path("/metrics/nodes/{id}/height/{height}"), api.GetNodeByIdAtNetworkHeight).Methods("GET")
 	api.router.HandleFunc(path("/metrics/nodes/{id}/height/{height}/{height_end}"), api.GetNodeByIdAtNetworkHeight).Methods("GET") // Search in a range of height
 
+	api.router.HandleFunc(path("/nodes/{id}/timeline"), api.GetNodeTimeline).Methods("GET")
+
+	api.router.HandleFunc(path("/leaderboard/nodes"), api.GetLeaderboard).Methods("GET")
+
 	api.router.HandleFunc(path("/uptime/nodes/{id}"), api.GetNodeUptimeById).Methods("GET")
+	api.router.HandleFunc(path("/uptime/nodes/{id}/gaps"), api.GetNodeUptimeGapsById).Methods("GET")
 
+	api.router.HandleFunc(path("/versions"), api.GetVersions).Methods("GET")
+	api.router.HandleFunc(path("/versions/adoption"), api.GetVersionAdoption).Methods("GET")
+	api.router.HandleFunc(path("/versions/outdated"), api.GetOutdatedNodes).Methods("GET")
 	api.router.HandleFunc(path("/versions/nodes/{id}"), api.GetNodeVersionsById).Methods("GET")
 
+	api.router.HandleFunc(path("/stream/nodes"), api.StreamNodes).Methods("GET")
+	api.router.HandleFunc(path("/export"), api.GetExport).Methods("GET")
+
+	api.router.HandleFunc(path("/status/insertqueue"), api.GetInsertQueueStatus).Methods("GET")
+	api.router.HandleFunc(path("/status/spool"), api.GetSpoolStatus).Methods("GET")
+
 	return api
 }
 
+// SetRowsPerPage sets the page size of the paginated endpoints
+func (a *RESTApiV1) SetRowsPerPage(rowsPerPage uint64) {
+	if rowsPerPage > 0 {
+		a.rowsPerPage = rowsPerPage
+	}
+}
+
+// SetProductionMode tells the index page whether the service runs in production mode
+func (a *RESTApiV1) SetProductionMode(productionMode bool) {
+	a.productionMode = productionMode
+}
+
+// SetUIPath sets the directory the UI files are served from
+func (a *RESTApiV1) SetUIPath(uiPath string) {
+	if uiPath != "" {
+		a.uiPath = uiPath
+	}
+}
+
+// SetMaxRowsPerPage sets the largest page size the clients can ask for
+func (a *RESTApiV1) SetMaxRowsPerPage(maxRowsPerPage uint64) {
+	if maxRowsPerPage > 0 {
+		a.maxRowsPerPage = maxRowsPerPage
+	}
+}
+
 func (a *RESTApiV1) Serve(addr, originAllowed string) error {
 
 	if addr == "" {
@@ -65,8 +121,35 @@ func (a *RESTApiV1) Serve(addr, originAllowed string) error {
 	originsOk := handlers.AllowedOrigins([]string{originAllowed})
 	methodsOk := handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"})
 
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handlers.CORS(originsOk, headersOk, methodsOk)(a.router),
+	}
+
+	a.serverMu.Lock()
+	a.server = server
+	a.serverMu.Unlock()
+
 	a.logger.Info(fmt.Sprintf("serving on %s", addr))
-	return http.ListenAndServe(addr, handlers.CORS(originsOk, headersOk, methodsOk)(a.router))
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil // Shutdown has been called
+	}
+	return err
+}
+
+// Shutdown stops accepting new connections and waits for the in-flight requests to finish
+func (a *RESTApiV1) Shutdown(ctx context.Context) error {
+	a.serverMu.Lock()
+	server := a.server
+	a.serverMu.Unlock()
+
+	a.stopStreams()
+
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
 
 func (a *RESTApiV1) GetAllAPIs() []string {
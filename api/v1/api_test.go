@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"go.uber.org/zap"
+)
+
+// newTestAPI serves the API on top of a fresh SQLite database
+func newTestAPI(t *testing.T) (*RESTApiV1, *metrics.Metrics) {
+	t.Helper()
+
+	store, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "nodelogger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt := metrics.NewWithStorage(store)
+	mt.InsertQueue.SetFlushInterval(10 * time.Millisecond)
+	if err := mt.InsertQueue.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mt.InsertQueue.Stop)
+
+	return NewRESTApiV1(mt, zap.NewNop()), mt
+}
+
+func (a *RESTApiV1) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+)
+
+// CursorPagination replaces Pagination on the endpoints paginated with a cursor
+type CursorPagination struct {
+	NextCursor string  `json:"next_cursor,omitempty"` // empty on the last page
+	Limit      uint64  `json:"limit"`
+	TotalRows  *uint64 `json:"total_rows,omitempty"` // only with ?count=true
+}
+
+// cursorToken is the content of the opaque cursors handed to the clients
+type cursorToken struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"i"`
+}
+
+func encodeCursor(sort metrics.NodeSort, key metrics.PageKey) string {
+
+	token := cursorToken{Sort: sort.String(), Value: sort.FormatKeyValue(key.Value), ID: key.ID}
+
+	data, _ := json.Marshal(token) // cannot fail
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the key the page starts after, nil for an empty cursor i.e. the first page.
+// A cursor is only valid for the sort it was issued for.
+func decodeCursor(sort metrics.NodeSort, cursor string) (*metrics.PageKey, error) {
+
+	if cursor == "" {
+		return nil, nil
+	}
+
+	errInvalid := fmt.Errorf("invalid `cursor`, it must be the `next_cursor` of a previous page of this endpoint with the same `sort` and `order`")
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalid
+	}
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil || token.Sort != sort.String() {
+		return nil, errInvalid
+	}
+
+	value, err := sort.ParseKeyValue(token.Value)
+	if err != nil {
+		return nil, errInvalid
+	}
+	return &metrics.PageKey{Value: value, ID: token.ID}, nil
+}
+
+// getPageSize reads the `limit` query param, the page size is rowsPerPage by default and at most maxRowsPerPage
+func (a *RESTApiV1) getPageSize(req *http.Request) uint64 {
+
+	limit, _ := strconv.ParseUint(req.URL.Query().Get("limit"), 10, 64)
+	if limit == 0 {
+		limit = a.rowsPerPage
+	}
+	if limit > a.maxRowsPerPage {
+		limit = a.maxRowsPerPage
+	}
+	return limit
+}
+
+// getCountFromHttpReq reads the `count` query param, the total count is only computed when asked
+func getCountFromHttpReq(req *http.Request) (bool, error) {
+
+	countStr := req.URL.Query().Get("count")
+	if countStr == "" {
+		return false, nil
+	}
+	count, err := strconv.ParseBool(countStr)
+	if err != nil {
+		return false, fmt.Errorf("malformed `count` value, true or false expected")
+	}
+	return count, nil
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+
+	node := models.CelestiaNode{
+		NodeId:           "node-é,1",
+		Uptime:           99.9,
+		Head:             1<<63 + 5,
+		LastPfbTimestamp: time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.FixedZone("CET", 3600)),
+	}
+	node.ID = 42
+	node.CreatedAt = time.Date(2024, 1, 2, 3, 4, 5, 999, time.UTC)
+
+	// a column of every kind, in both orders
+	for _, column := range []string{"head", "uptime", "node_id", "created_at", "last_pfb_timestamp"} {
+		for _, desc := range []bool{false, true} {
+			sort := metrics.NodeSort{Column: column, Desc: desc}
+			t.Run(sort.String(), func(t *testing.T) {
+
+				want := sort.KeyOf(node)
+				got, err := decodeCursor(sort, encodeCursor(sort, want))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.ID != want.ID {
+					t.Errorf("id %d, want %d", got.ID, want.ID)
+				}
+				if wantTime, ok := want.Value.(time.Time); ok {
+					if gotTime, ok := got.Value.(time.Time); !ok || !gotTime.Equal(wantTime) {
+						t.Errorf("value %v, want %v", got.Value, wantTime)
+					}
+				} else if got.Value != want.Value {
+					t.Errorf("value %#v, want %#v", got.Value, want.Value)
+				}
+			})
+		}
+	}
+}
+
+func TestCursorRejected(t *testing.T) {
+
+	headDesc := metrics.NodeSort{Column: "head", Desc: true}
+	cursor := encodeCursor(headDesc, metrics.PageKey{Value: uint64(10), ID: 3})
+
+	if key, err := decodeCursor(headDesc, ""); key != nil || err != nil {
+		t.Errorf("empty cursor: %v, %v, want the first page", key, err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		sort   metrics.NodeSort
+		cursor string
+	}{
+		{name: "other order", sort: metrics.NodeSort{Column: "head"}, cursor: cursor},
+		{name: "other column", sort: metrics.NodeSort{Column: "network_height", Desc: true}, cursor: cursor},
+		{name: "not base64", sort: headDesc, cursor: "!!!"},
+		{name: "not json", sort: headDesc, cursor: "bm9wZQ"},
+		{name: "bad value", sort: headDesc, cursor: "eyJzIjoiaGVhZDpkZXNjIiwidiI6Im5vcGUiLCJpIjoxfQ"}, // {"s":"head:desc","v":"nope","i":1}
+	} {
+		if _, err := decodeCursor(tt.sort, tt.cursor); err == nil {
+			t.Errorf("%s: the cursor was accepted", tt.name)
+		}
+	}
+}
+
+type cursorPage struct {
+	Pagination CursorPagination      `json:"pagination"`
+	Rows       []models.CelestiaNode `json:"rows"`
+}
+
+func getCursorPage(t *testing.T, a *RESTApiV1, query url.Values) cursorPage {
+	t.Helper()
+
+	rec := a.serve(httptest.NewRequest("GET", path("/metrics/nodes?"+query.Encode()), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", query.Encode(), rec.Code, rec.Body.String())
+	}
+	var page cursorPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+// addTiedSamples writes samples sharing their sort values, the pages must break the ties by id
+func addTiedSamples(t *testing.T, mt *metrics.Metrics) int {
+	t.Helper()
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	batch := []*models.CelestiaNode{}
+	for i := 0; i < 23; i++ {
+		batch = append(batch, &models.CelestiaNode{
+			NodeId:    fmt.Sprintf("node-%d", i%4),
+			Uptime:    []float32{50, 99.9, 99.9}[i%3],
+			Head:      uint64(i / 5),
+			CreatedAt: at.Add(time.Duration(i/6) * time.Minute),
+		})
+	}
+	if err := mt.AddNodeDataBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	return len(batch)
+}
+
+func TestListNodesCursorPages(t *testing.T) {
+
+	a, mt := newTestAPI(t)
+	total := addTiedSamples(t, mt)
+
+	for _, sort := range []string{"uptime", "head", "node_id", "created_at"} {
+		for _, order := range []string{"asc", "desc"} {
+			t.Run(sort+":"+order, func(t *testing.T) {
+
+				seen := map[uint]bool{}
+				query := url.Values{"sort": {sort}, "order": {order}, "limit": {"4"}}
+				for pages := 0; ; pages++ {
+					if pages > total {
+						t.Fatal("the pages do not end")
+					}
+
+					page := getCursorPage(t, a, query)
+					if len(page.Rows) > 4 {
+						t.Fatalf("%d rows in a page of 4", len(page.Rows))
+					}
+					for _, row := range page.Rows {
+						if seen[row.ID] {
+							t.Fatalf("row %d listed twice", row.ID)
+						}
+						seen[row.ID] = true
+					}
+					if page.Pagination.TotalRows != nil {
+						t.Errorf("total_rows %d sent without count", *page.Pagination.TotalRows)
+					}
+
+					if page.Pagination.NextCursor == "" {
+						break
+					}
+					query.Set("cursor", page.Pagination.NextCursor)
+				}
+
+				if len(seen) != total {
+					t.Errorf("%d rows listed over the pages, want %d", len(seen), total)
+				}
+			})
+		}
+	}
+
+	// a cursor of another sort is refused
+	page := getCursorPage(t, a, url.Values{"sort": {"head"}, "limit": {"4"}})
+	query := url.Values{"sort": {"uptime"}, "cursor": {page.Pagination.NextCursor}}
+	if rec := a.serve(httptest.NewRequest("GET", path("/metrics/nodes?"+query.Encode()), nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("cursor of another sort: status %d, want 400", rec.Code)
+	}
+}
+
+func TestListNodesCount(t *testing.T) {
+
+	a, mt := newTestAPI(t)
+	total := addTiedSamples(t, mt)
+
+	page := getCursorPage(t, a, url.Values{"limit": {"4"}, "count": {"true"}})
+	if page.Pagination.TotalRows == nil || *page.Pagination.TotalRows != uint64(total) {
+		t.Errorf("total_rows %v, want %d", page.Pagination.TotalRows, total)
+	}
+
+	// the count is of the filtered rows, whatever the page
+	page = getCursorPage(t, a, url.Values{"limit": {"2"}, "count": {"1"}, "min_head": {"3"}, "cursor": {page.Pagination.NextCursor}})
+	if page.Pagination.TotalRows == nil || *page.Pagination.TotalRows != 8 {
+		t.Errorf("total_rows %v, want the 8 samples with a head of at least 3", page.Pagination.TotalRows)
+	}
+
+	page = getCursorPage(t, a, url.Values{"count": {"false"}})
+	if page.Pagination.TotalRows != nil {
+		t.Errorf("total_rows %d with count=false", *page.Pagination.TotalRows)
+	}
+
+	if rec := a.serve(httptest.NewRequest("GET", path("/metrics/nodes?count=maybe"), nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("count=maybe: status %d, want 400", rec.Code)
+	}
+}
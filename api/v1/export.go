@@ -0,0 +1,94 @@
+package api
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/export"
+)
+
+// GetExport implements GET /export?format={csv|ndjson|parquet}&node_id={id} with the filters of the node listings.
+// The samples are streamed from the database, gzipped if the client accepts it.
+func (a *RESTApiV1) GetExport(resp http.ResponseWriter, req *http.Request) {
+
+	format, err := export.ParseFormat(req.URL.Query().Get("format"))
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetExport`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := getNodeFilterFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetExport`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.NodeId = req.URL.Query().Get("node_id")
+	if err := validNodeId("node_id", filter.NodeId); err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetExport`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Allow CORS here By *
+	resp.Header().Set("Access-Control-Allow-Origin", "*")
+	resp.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	resp.Header().Set("Content-Type", format.ContentType())
+	resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="nodes%s"`, format.Extension()))
+	resp.Header().Add("Vary", "Accept-Encoding")
+
+	var out io.Writer = resp
+	var gz *gzip.Writer
+	if acceptsGzip(req) {
+		resp.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(resp)
+		out = gz
+	}
+
+	// nothing is written before the first sample, so a failing query can still be answered with an error
+	w := export.NewWriter(format, out)
+	rows := 0
+	err = a.metrics.ExportNodes(req.Context(), filter, func(node *models.CelestiaNode) error {
+		rows++
+		return w.Write(node)
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+
+	if err != nil && rows == 0 {
+		resp.Header().Del("Content-Encoding")
+		resp.Header().Del("Content-Disposition")
+		a.logger.Error(fmt.Sprintf("api `GetExport`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// the response has started, it is cut so the client does not take a truncated export for a complete one
+		a.logger.Error(fmt.Sprintf("api `GetExport`: %v after %d rows", err, rows))
+		panic(http.ErrAbortHandler)
+	}
+
+	a.logger.Info(fmt.Sprintf("api call `GetExport` %v ", req.URL.Path))
+	a.logger.Debug(fmt.Sprintf("api call `GetExport` format: %v filter: %#v rows: %v gzip: %v", format, filter, rows, gz != nil))
+}
+
+// acceptsGzip tells if the client accepts a gzipped response
+func acceptsGzip(req *http.Request) bool {
+	for _, enc := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(strings.TrimSpace(q), " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
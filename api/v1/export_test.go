@@ -0,0 +1,91 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func addExportSamples(t *testing.T, a *RESTApiV1, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := a.metrics.InsertQueue.Add(&models.CelestiaNode{NodeId: fmt.Sprintf("node-%d", i), CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForRows(t, a.metrics, uint64(n))
+}
+
+func TestExportGzip(t *testing.T) {
+
+	a, _ := newTestAPI(t)
+	addExportSamples(t, a, 3)
+
+	for _, tt := range []struct {
+		accept string
+		gzip   bool
+	}{
+		{accept: "", gzip: false},
+		{accept: "gzip", gzip: true},
+		{accept: "deflate, gzip;q=0.5", gzip: true},
+		{accept: "gzip; q=0", gzip: false},
+	} {
+		t.Run(tt.accept, func(t *testing.T) {
+
+			req := httptest.NewRequest("GET", path("/export"), nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			rec := a.serve(req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Encoding") == "gzip"; got != tt.gzip {
+				t.Fatalf("gzipped %v, want %v", got, tt.gzip)
+			}
+
+			body := csv.NewReader(rec.Body)
+			if tt.gzip {
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = csv.NewReader(gz)
+			}
+			records, err := body.ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 4 || records[1][2] != "node-0" || records[3][2] != "node-2" {
+				t.Errorf("records %q, want the header and the 3 samples", records)
+			}
+		})
+	}
+}
+
+// A query failing before the first sample is answered with an error, not an empty export
+func TestExportErrorBeforeFirstRow(t *testing.T) {
+
+	a, _ := newTestAPI(t)
+	addExportSamples(t, a, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest("GET", path("/export?format=parquet"), nil).WithContext(ctx)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := a.serve(req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", rec.Code)
+	}
+	if enc, disp := rec.Header().Get("Content-Encoding"), rec.Header().Get("Content-Disposition"); enc != "" || disp != "" {
+		t.Errorf("Content-Encoding %q and Content-Disposition %q left on the error", enc, disp)
+	}
+}
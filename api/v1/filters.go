@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+)
+
+// getNodeFilterFromHttpReq reads the filters of the node list endpoints:
+// type, version (exact or a range e.g. >=v0.9.0,<v0.10.0), node_id_prefix, min_uptime, max_uptime,
+// from and to on created_at, min_head and min_network_height
+func getNodeFilterFromHttpReq(req *http.Request) (metrics.NodeFilter, error) {
+
+	var filter metrics.NodeFilter
+	var err error
+
+	query := req.URL.Query()
+
+	if typeStr := query.Get("type"); typeStr != "" {
+		t, err := metrics.ParseNodeType(typeStr)
+		if err != nil {
+			return filter, err
+		}
+		filter.NodeType = &t
+	}
+
+	if version := query.Get("version"); metrics.IsVersionRange(version) {
+		filter.VersionRange, err = metrics.ParseVersionRange(version)
+		if err != nil {
+			return filter, err
+		}
+	} else {
+		filter.Version = version
+	}
+
+	filter.NodeIdPrefix = query.Get("node_id_prefix")
+	if err := validNodeId("node_id_prefix", filter.NodeIdPrefix); err != nil {
+		return filter, err
+	}
+
+	if filter.MinUptime, err = getFloatParam(req, "min_uptime"); err != nil {
+		return filter, err
+	}
+	if filter.MaxUptime, err = getFloatParam(req, "max_uptime"); err != nil {
+		return filter, err
+	}
+
+	if filter.From, filter.To, err = getTimeRangeFromHttpReq(req); err != nil {
+		return filter, err
+	}
+
+	if filter.MinHead, err = getUintParam(req, "min_head"); err != nil {
+		return filter, err
+	}
+	if filter.MinNetworkHeight, err = getUintParam(req, "min_network_height"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// getNodeSortFromHttpReq reads the `sort` column and the `order`, asc or desc, def is used without `sort`
+func getNodeSortFromHttpReq(req *http.Request, def metrics.NodeSort) (metrics.NodeSort, error) {
+
+	column := req.URL.Query().Get("sort")
+	order := req.URL.Query().Get("order")
+	if column == "" {
+		if order == "" {
+			return def, nil
+		}
+		column = def.Column
+	}
+	return metrics.ParseNodeSort(column, order)
+}
+
+func getFloatParam(req *http.Request, name string) (*float64, error) {
+
+	str := req.URL.Query().Get(name)
+	if str == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed `%s` value, a number expected", name)
+	}
+	return &v, nil
+}
+
+func getUintParam(req *http.Request, name string) (uint64, error) {
+
+	str := req.URL.Query().Get(name)
+	if str == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed `%s` value, a positive integer expected", name)
+	}
+	return v, nil
+}
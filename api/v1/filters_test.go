@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+)
+
+func TestNodeFilterFromHttpReq(t *testing.T) {
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query string
+		check func(f metrics.NodeFilter) bool
+		err   bool
+	}{
+		{query: "", check: func(f metrics.NodeFilter) bool {
+			return f.NodeType == nil && f.Version == "" && f.VersionRange == nil && f.MinUptime == nil && f.From.IsZero() && f.To.IsZero()
+		}},
+		{query: "type=Light", check: func(f metrics.NodeFilter) bool {
+			return f.NodeType != nil && *f.NodeType == receiver.LightNodeType
+		}},
+		{query: "type=validator", err: true},
+		{query: "version=v0.10.0", check: func(f metrics.NodeFilter) bool {
+			return f.Version == "v0.10.0" && f.VersionRange == nil
+		}},
+		{query: "version=%3E%3Dv0.9.0,%3Cv0.10.0", check: func(f metrics.NodeFilter) bool {
+			v9, _ := metrics.ParseVersion("v0.9.5")
+			v10, _ := metrics.ParseVersion("v0.10.0")
+			return f.Version == "" && f.VersionRange.Contains(v9) && !f.VersionRange.Contains(v10)
+		}},
+		{query: "version=%3E%3Dnope", err: true},
+		{query: "node_id_prefix=light", check: func(f metrics.NodeFilter) bool { return f.NodeIdPrefix == "light" }},
+		{query: "node_id_prefix=%01", err: true},
+		{query: "min_uptime=50&max_uptime=99.5", check: func(f metrics.NodeFilter) bool {
+			return f.MinUptime != nil && *f.MinUptime == 50 && f.MaxUptime != nil && *f.MaxUptime == 99.5
+		}},
+		{query: "min_uptime=high", err: true},
+		{query: "from=2024-01-01T00:00:00Z&to=2024-01-02T00:00:00Z", check: func(f metrics.NodeFilter) bool {
+			return f.From.Equal(from) && f.To.Equal(from.Add(24*time.Hour))
+		}},
+		{query: "from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z", err: true},
+		{query: "from=yesterday", err: true},
+		{query: "min_head=10&min_network_height=20", check: func(f metrics.NodeFilter) bool {
+			return f.MinHead == 10 && f.MinNetworkHeight == 20
+		}},
+		{query: "min_head=-1", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+
+			filter, err := getNodeFilterFromHttpReq(httptest.NewRequest("GET", "/?"+tt.query, nil))
+			if (err != nil) != tt.err {
+				t.Fatalf("error %v, want an error %v", err, tt.err)
+			}
+			if !tt.err && !tt.check(filter) {
+				t.Errorf("filter %+v", filter)
+			}
+		})
+	}
+}
+
+func TestNodeSortFromHttpReq(t *testing.T) {
+
+	tests := []struct {
+		query string
+		want  metrics.NodeSort
+		err   bool
+	}{
+		{query: "", want: metrics.DefaultNodeSort},
+		{query: "order=asc", want: metrics.NodeSort{Column: metrics.DefaultNodeSort.Column}},
+		{query: "sort=head", want: metrics.NodeSort{Column: "head", Desc: true}},
+		{query: "sort=head&order=asc", want: metrics.NodeSort{Column: "head"}},
+		{query: "sort=secret", err: true},
+		{query: "sort=head&order=sideways", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := getNodeSortFromHttpReq(httptest.NewRequest("GET", "/?"+tt.query, nil), metrics.DefaultNodeSort)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: error %v, want an error %v", tt.query, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("%q: sort %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
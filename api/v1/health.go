@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultReadyMaxQueueDepth = 10000
+	DefaultReadyMaxDataAge    = 5 * time.Minute
+
+	readyCheckTimeout = 2 * time.Second
+)
+
+type HealthCheck struct {
+	Status string `json:"status"` // ok or fail
+	Detail string `json:"detail,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                 `json:"status"` // ok or fail
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+// SetReadinessLimits sets the insert queue backlog and the age of the newest sample
+// the service is not ready beyond, a zero max data age turns the freshness check off
+func (a *RESTApiV1) SetReadinessLimits(maxQueueDepth int64, maxDataAge time.Duration) {
+	a.readyMaxQueueDepth = maxQueueDepth
+	a.readyMaxDataAge = maxDataAge
+}
+
+// GetHealthz implements GET /healthz, the process is up and serving
+func (a *RESTApiV1) GetHealthz(resp http.ResponseWriter, req *http.Request) {
+
+	err := sendJSON(resp, HealthReport{Status: "ok", Checks: map[string]HealthCheck{}})
+	a.logger.Debug(fmt.Sprintf("api call `GetHealthz` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetHealthz`: %v", err))
+	}
+}
+
+// GetReadyz implements GET /readyz, the database is reachable, the insert queue keeps up
+// and the node metrics keep coming
+func (a *RESTApiV1) GetReadyz(resp http.ResponseWriter, req *http.Request) {
+
+	ctx, cancel := context.WithTimeout(req.Context(), readyCheckTimeout)
+	defer cancel()
+
+	report := HealthReport{Status: "ok", Checks: map[string]HealthCheck{}}
+	check := func(name string, detail string, ok bool) {
+		c := HealthCheck{Status: "ok", Detail: detail}
+		if !ok {
+			c.Status = "fail"
+			report.Status = "fail"
+		}
+		report.Checks[name] = c
+	}
+
+	if err := a.metrics.Ping(ctx); err != nil {
+		check("database", err.Error(), false)
+	} else {
+		check("database", "", true)
+	}
+
+	depth := a.metrics.InsertQueue.Len()
+	check("insert_queue", fmt.Sprintf("%d samples queued, at most %d", depth, a.readyMaxQueueDepth), depth <= a.readyMaxQueueDepth)
+
+	if a.readyMaxDataAge > 0 {
+		latest, err := a.metrics.GetLatestSampleTime(ctx)
+		switch {
+		case err != nil:
+			check("freshness", err.Error(), false)
+		case latest.IsZero():
+			// a new deployment gets the max data age to receive its first samples
+			age := time.Since(a.startedAt)
+			check("freshness", fmt.Sprintf("no sample yet, started %v ago", age.Round(time.Second)), age <= a.readyMaxDataAge)
+		default:
+			age := time.Since(latest)
+			check("freshness", fmt.Sprintf("newest sample is %v old, at most %v", age.Round(time.Second), a.readyMaxDataAge), age <= a.readyMaxDataAge)
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+
+	err := sendJSONWithStatus(resp, status, report)
+	a.logger.Debug(fmt.Sprintf("api call `GetReadyz` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetReadyz`: %v", err))
+	}
+}
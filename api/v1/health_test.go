@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"go.uber.org/zap"
+)
+
+// newIdleTestAPI leaves the insert queue stopped, the queued samples stay queued
+func newIdleTestAPI(t *testing.T) (*RESTApiV1, *metrics.Metrics, *storage.SQLite) {
+	t.Helper()
+
+	store, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "nodelogger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mt := metrics.NewWithStorage(store)
+	return NewRESTApiV1(mt, zap.NewNop()), mt, store
+}
+
+func getReadyz(t *testing.T, a *RESTApiV1) (int, HealthReport) {
+	t.Helper()
+
+	rec := a.serve(httptest.NewRequest("GET", "/readyz", nil))
+	var report HealthReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	return rec.Code, report
+}
+
+func TestHealthz(t *testing.T) {
+
+	a, _, store := newIdleTestAPI(t)
+
+	// the process is up even though the database is not
+	sqlDB, err := store.DB().DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+
+	if rec := a.serve(httptest.NewRequest("GET", "/healthz", nil)); rec.Code != http.StatusOK {
+		t.Errorf("status %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReadyz(t *testing.T) {
+
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite)
+		checks map[string]string // the status of the checks
+	}{
+		{
+			name:   "fresh sample",
+			setup:  func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) { addReadyzSample(t, mt, now) },
+			checks: map[string]string{"database": "ok", "insert_queue": "ok", "freshness": "ok"},
+		},
+		{
+			name:   "stale sample",
+			setup:  func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) { addReadyzSample(t, mt, now.Add(-time.Hour)) },
+			checks: map[string]string{"database": "ok", "insert_queue": "ok", "freshness": "fail"},
+		},
+		{
+			name:   "no sample, just started",
+			setup:  func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) {},
+			checks: map[string]string{"freshness": "ok"},
+		},
+		{
+			name: "no sample, started long ago",
+			setup: func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) {
+				a.startedAt = now.Add(-time.Hour)
+			},
+			checks: map[string]string{"freshness": "fail"},
+		},
+		{
+			name: "stale sample, freshness off",
+			setup: func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) {
+				addReadyzSample(t, mt, now.Add(-time.Hour))
+				a.SetReadinessLimits(DefaultReadyMaxQueueDepth, 0)
+			},
+			checks: map[string]string{"database": "ok", "insert_queue": "ok"},
+		},
+		{
+			name: "queue at the limit",
+			setup: func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) {
+				addReadyzSample(t, mt, now)
+				queueReadyzSamples(t, mt, 3)
+				a.SetReadinessLimits(3, DefaultReadyMaxDataAge)
+			},
+			checks: map[string]string{"insert_queue": "ok", "freshness": "ok"},
+		},
+		{
+			name: "queue over the limit",
+			setup: func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) {
+				addReadyzSample(t, mt, now)
+				queueReadyzSamples(t, mt, 3)
+				a.SetReadinessLimits(2, DefaultReadyMaxDataAge)
+			},
+			checks: map[string]string{"database": "ok", "insert_queue": "fail", "freshness": "ok"},
+		},
+		{
+			name: "database down",
+			setup: func(t *testing.T, a *RESTApiV1, mt *metrics.Metrics, store *storage.SQLite) {
+				sqlDB, err := store.DB().DB()
+				if err != nil {
+					t.Fatal(err)
+				}
+				sqlDB.Close()
+			},
+			checks: map[string]string{"database": "fail", "insert_queue": "ok", "freshness": "fail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			a, mt, store := newIdleTestAPI(t)
+			tt.setup(t, a, mt, store)
+
+			code, report := getReadyz(t, a)
+
+			wantStatus, wantCode := "ok", http.StatusOK
+			for _, status := range tt.checks {
+				if status != "ok" {
+					wantStatus, wantCode = "fail", http.StatusServiceUnavailable
+				}
+			}
+			if code != wantCode || report.Status != wantStatus {
+				t.Errorf("status %d %q, want %d %q: %+v", code, report.Status, wantCode, wantStatus, report.Checks)
+			}
+
+			for name, want := range tt.checks {
+				if got := report.Checks[name]; got.Status != want {
+					t.Errorf("check %s %q (%s), want %q", name, got.Status, got.Detail, want)
+				}
+			}
+			if _, ok := report.Checks["freshness"]; ok != (a.readyMaxDataAge > 0) {
+				t.Errorf("freshness checked %v with the max data age %v", ok, a.readyMaxDataAge)
+			}
+		})
+	}
+}
+
+// addReadyzSample writes a sample taken at the given time straight into the database
+func addReadyzSample(t *testing.T, mt *metrics.Metrics, at time.Time) {
+	t.Helper()
+
+	if err := mt.AddNodeDataBatch([]*models.CelestiaNode{{NodeId: "node-1", CreatedAt: at}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// queueReadyzSamples leaves samples waiting in the stopped insert queue
+func queueReadyzSamples(t *testing.T, mt *metrics.Metrics, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := mt.InsertQueue.Add(&models.CelestiaNode{NodeId: "node-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if depth := mt.InsertQueue.Len(); depth != int64(n) {
+		t.Fatalf("%d samples queued, want %d", depth, n)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/gorilla/mux"
+)
+
+// GetNodeHistoryById implements GET /metrics/nodes/{id}/history?from={RFC3339}&to={RFC3339}&resolution={auto|raw|hour|day}
+func (a *RESTApiV1) GetNodeHistoryById(resp http.ResponseWriter, req *http.Request) {
+
+	id := mux.Vars(req)["id"]
+
+	from, to, err := getTimeRangeFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetNodeHistoryById`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	if !from.Before(to) {
+		err = fmt.Errorf("`from` must be before `to`")
+		a.logger.Info(fmt.Sprintf("api `GetNodeHistoryById`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resolution := metrics.PickResolution(from, to)
+	if resolutionStr := req.URL.Query().Get("resolution"); resolutionStr != "" && resolutionStr != "auto" {
+		resolution, err = metrics.ParseResolution(resolutionStr)
+		if err != nil {
+			a.logger.Info(fmt.Sprintf("api `GetNodeHistoryById`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var rows interface{}
+	if resolution == metrics.ResolutionRaw {
+		rows, err = a.metrics.GetNodeRawHistory(id, from, to)
+	} else {
+		rows, err = a.metrics.GetNodeRollups(id, resolution, from, to)
+	}
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetNodeHistoryById`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"node_id":    id,
+			"from":       from,
+			"to":         to,
+			"resolution": resolution,
+			"rows":       rows,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetNodeHistoryById` %v id: %v", req.URL.Path, id))
+	a.logger.Debug(fmt.Sprintf("api call `GetNodeHistoryById` from: %v to: %v resolution: %v", from, to, resolution))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetNodeHistoryById`: %v", err))
+	}
+}
@@ -3,7 +3,6 @@ package api
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"runtime/debug"
 	"strings"
 )
@@ -49,7 +48,7 @@ func (a *RESTApiV1) IndexPage(resp http.ResponseWriter, req *http.Request) {
 		html += fmt.Sprintf(`<a href="%s">%s</a><br />`, href, a)
 	}
 
-	html += fmt.Sprintf("<br />Production Mode: %v", os.Getenv("PRODUCTION_MODE"))
+	html += fmt.Sprintf("<br />Production Mode: %v", a.productionMode)
 	html += buildInfo
 
 	resp.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -57,11 +56,5 @@ func (a *RESTApiV1) IndexPage(resp http.ResponseWriter, req *http.Request) {
 }
 
 func (a *RESTApiV1) UI(resp http.ResponseWriter, req *http.Request) {
-
-	rootPath := os.Getenv("EXEC_PATH")
-	if rootPath == "" {
-		rootPath = "./"
-	}
-
-	http.FileServer(http.Dir(rootPath)).ServeHTTP(resp, req)
+	http.FileServer(http.Dir(a.uiPath)).ServeHTTP(resp, req)
 }
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexAndUI(t *testing.T) {
+
+	a, _ := newTestAPI(t)
+
+	rec := a.serve(httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), "Production Mode: false") {
+		t.Errorf("index page without the production mode: %s", rec.Body.String())
+	}
+
+	a.SetProductionMode(true)
+	rec = a.serve(httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), "Production Mode: true") {
+		t.Errorf("index page without the production mode set: %s", rec.Body.String())
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("ui"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a.SetUIPath(dir)
+
+	rec = httptest.NewRecorder()
+	a.UI(rec, httptest.NewRequest("GET", "/app.js", nil))
+	if rec.Code != 200 || rec.Body.String() != "ui" {
+		t.Errorf("UI file: %d %q, want the file of the UI path", rec.Code, rec.Body.String())
+	}
+}
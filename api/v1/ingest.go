@@ -0,0 +1,189 @@
+package api
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/celestiaorg/nodelogger/ingest"
+	"github.com/celestiaorg/nodelogger/telemetry"
+)
+
+const (
+	// Max size of a pushed request body, compressed
+	maxIngestBodySize = 32 << 20
+
+	// Max size of a gzip compressed body once decompressed
+	maxIngestDecodedSize = 4 * maxIngestBodySize
+)
+
+var errIngestBodyTooLarge = errors.New("the request body is too large")
+
+// SetIngest sets where the pushed metrics go, the pushers must send token as a bearer token.
+// The push endpoints are registered by EnableRemoteWrite and EnableOTLP.
+func (a *RESTApiV1) SetIngest(assembler *ingest.Assembler, token string) {
+	a.ingest = assembler
+	a.ingestToken = token
+}
+
+func (a *RESTApiV1) EnableRemoteWrite() {
+	a.router.HandleFunc(path("/write"), a.PushRemoteWrite).Methods("POST")
+}
+
+// EnableOTLP registers the OTLP/HTTP metrics endpoint on its standard path,
+// so the exporters only need the address of the API
+func (a *RESTApiV1) EnableOTLP() {
+	a.router.HandleFunc("/v1/metrics", a.PushOTLPMetrics).Methods("POST")
+}
+
+// checkIngestToken answers 401 and returns false if the request does not carry the ingest token,
+// without a token set every push is refused
+func (a *RESTApiV1) checkIngestToken(resp http.ResponseWriter, req *http.Request) bool {
+
+	auth := req.Header.Get("Authorization")
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if token == auth || a.ingestToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.ingestToken)) != 1 {
+		http.Error(resp, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// ingestSeries hands the decoded series to the assembler and answers the request
+func (a *RESTApiV1) ingestSeries(resp http.ResponseWriter, apiName string, series []ingest.Series, success func()) {
+
+	used, rejected, err := a.ingest.Ingest(series)
+	if err != nil {
+		if errors.Is(err, ingest.ErrAssemblerClosed) {
+			http.Error(resp, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		a.logger.Error(fmt.Sprintf("api `%s`: %v", apiName, err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if rejected > 0 {
+		a.logger.Warn(fmt.Sprintf("api `%s`: %d series dropped, their node id or version can not be stored", apiName, rejected))
+	}
+	a.logger.Debug(fmt.Sprintf("api `%s`: %d series received, %d data points used", apiName, len(series), used))
+	if used > 0 {
+		telemetry.MarkSync()
+	}
+	success()
+}
+
+// PushRemoteWrite implements POST /write, the Prometheus remote-write protocol
+func (a *RESTApiV1) PushRemoteWrite(resp http.ResponseWriter, req *http.Request) {
+
+	a.logger.Debug(fmt.Sprintf("api call `PushRemoteWrite` %v", req.URL.Path))
+
+	if !a.checkIngestToken(resp, req) {
+		return
+	}
+
+	body, err := readIngestBody(resp, req, false)
+	if err != nil {
+		writeIngestBodyError(resp, err)
+		return
+	}
+
+	series, err := ingest.DecodeRemoteWrite(body)
+	if err != nil {
+		http.Error(resp, fmt.Sprintf("malformed remote-write request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	a.ingestSeries(resp, "PushRemoteWrite", series, func() {
+		resp.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// PushOTLPMetrics implements POST /v1/metrics, an OTLP/HTTP metrics export in protobuf or JSON
+func (a *RESTApiV1) PushOTLPMetrics(resp http.ResponseWriter, req *http.Request) {
+
+	a.logger.Debug(fmt.Sprintf("api call `PushOTLPMetrics` %v", req.URL.Path))
+
+	if !a.checkIngestToken(resp, req) {
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	var decode func([]byte) ([]ingest.Series, error)
+	switch contentType {
+	case "application/x-protobuf":
+		decode = ingest.DecodeOTLPProtobuf
+	case "application/json":
+		decode = ingest.DecodeOTLPJSON
+	default:
+		http.Error(resp, "unsupported content type, application/x-protobuf or application/json expected", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := readIngestBody(resp, req, true)
+	if err != nil {
+		writeIngestBodyError(resp, err)
+		return
+	}
+
+	series, err := decode(data)
+	if err != nil {
+		http.Error(resp, fmt.Sprintf("malformed OTLP request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	a.ingestSeries(resp, "PushOTLPMetrics", series, func() {
+		// an empty ExportMetricsServiceResponse means all the points were accepted
+		resp.Header().Set("Content-Type", contentType)
+		if contentType == "application/json" {
+			resp.Write([]byte("{}"))
+		}
+	})
+}
+
+// readIngestBody reads a pushed body, decompressing it if it is gzip encoded and gunzip is set.
+// It returns errIngestBodyTooLarge if the body is over the limits, compressed or not.
+func readIngestBody(resp http.ResponseWriter, req *http.Request, gunzip bool) ([]byte, error) {
+
+	var body io.Reader = http.MaxBytesReader(resp, req.Body, maxIngestBodySize)
+	limit := int64(maxIngestBodySize)
+	if gunzip && req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, checkBodyTooLarge(err)
+		}
+		defer gz.Close()
+		body, limit = gz, maxIngestDecodedSize
+	}
+
+	// one byte more than the limit tells an oversized body from one exactly at the limit
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, checkBodyTooLarge(err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errIngestBodyTooLarge
+	}
+	return data, nil
+}
+
+func checkBodyTooLarge(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return errIngestBodyTooLarge
+	}
+	return err
+}
+
+func writeIngestBodyError(resp http.ResponseWriter, err error) {
+	if errors.Is(err, errIngestBodyTooLarge) {
+		http.Error(resp, fmt.Sprintf("the request body is over %d bytes, or %d once decompressed", maxIngestBodySize, maxIngestDecodedSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(resp, fmt.Sprintf("reading the body: %v", err), http.StatusBadRequest)
+}
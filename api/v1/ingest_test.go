@@ -0,0 +1,106 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/ingest"
+	"github.com/golang/snappy"
+)
+
+func TestIngestToken(t *testing.T) {
+
+	body := snappy.Encode(nil, nil) // an empty remote-write request
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{name: "no token set", token: "", header: "", want: http.StatusUnauthorized},
+		{name: "no token set, empty bearer", token: "", header: "Bearer ", want: http.StatusUnauthorized},
+		{name: "missing", token: "secret", header: "", want: http.StatusUnauthorized},
+		{name: "wrong", token: "secret", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "not a bearer", token: "secret", header: "secret", want: http.StatusUnauthorized},
+		{name: "valid", token: "secret", header: "Bearer secret", want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			a, _ := newTestAPI(t)
+			a.SetIngest(ingest.NewAssembler(func(*models.CelestiaNode) error { return nil }, nil, "", time.Second), tt.token)
+			a.EnableRemoteWrite()
+
+			req := httptest.NewRequest("POST", path("/write"), bytes.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if rec := a.serve(req); rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+// The bodies over the limits get a 413, not a 400 telling they are malformed
+func TestIngestBodyTooLarge(t *testing.T) {
+
+	var zipped bytes.Buffer
+	gz := gzip.NewWriter(&zipped)
+	if _, err := io.CopyN(gz, zeros{}, maxIngestDecodedSize+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		body     []byte
+		encoding string
+		want     int
+	}{
+		{name: "remote write", path: path("/write"), body: make([]byte, maxIngestBodySize+1), want: http.StatusRequestEntityTooLarge},
+		{name: "otlp", path: "/v1/metrics", body: make([]byte, maxIngestBodySize+1), want: http.StatusRequestEntityTooLarge},
+		{name: "otlp decompressed", path: "/v1/metrics", body: zipped.Bytes(), encoding: "gzip", want: http.StatusRequestEntityTooLarge},
+		{name: "otlp not gzip", path: "/v1/metrics", body: []byte("nope"), encoding: "gzip", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			a, _ := newTestAPI(t)
+			a.SetIngest(ingest.NewAssembler(func(*models.CelestiaNode) error { return nil }, nil, "", time.Second), "secret")
+			a.EnableRemoteWrite()
+			a.EnableOTLP()
+
+			req := httptest.NewRequest("POST", tt.path, bytes.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			req.Header.Set("Content-Type", "application/x-protobuf")
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			if rec := a.serve(req); rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
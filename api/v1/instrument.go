@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/telemetry"
+	"github.com/gorilla/mux"
+)
+
+// statusRecorder keeps the status code a handler answered with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrument records the latency of the requests by route template, so the node ids do not make new series
+func (a *RESTApiV1) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+
+		route := "unknown"
+		if r := mux.CurrentRoute(req); r != nil {
+			if tpl, err := r.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		begin := time.Now()
+		rec := &statusRecorder{ResponseWriter: resp, status: http.StatusOK}
+		next.ServeHTTP(rec, req)
+
+		telemetry.HTTPRequestDuration.
+			WithLabelValues(route, req.Method, strconv.Itoa(rec.status)).
+			Observe(time.Since(begin).Seconds())
+	})
+}
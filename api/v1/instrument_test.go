@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// The request latencies are labeled by route template, the node ids do not make new series
+func TestInstrumentRouteLabels(t *testing.T) {
+
+	a, _ := newTestAPI(t)
+
+	for _, id := range []string{"instrumented-node-1", "instrumented-node-2"} {
+		a.serve(httptest.NewRequest("GET", path("/metrics/nodes/"+id), nil))
+		a.serve(httptest.NewRequest("GET", path("/uptime/nodes/"+id+"/gaps"), nil))
+	}
+	a.serve(httptest.NewRequest("GET", "/healthz", nil))
+
+	rec := a.serve(httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`route="/api/v1/metrics/nodes/{id}"`,
+		`route="/api/v1/uptime/nodes/{id}/gaps"`,
+		`nodelogger_http_request_duration_seconds_count{code="200",method="GET",route="/healthz"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("%s is not in the metrics", want)
+		}
+	}
+	if strings.Contains(body, "instrumented-node") {
+		t.Error("a node id is in the route labels")
+	}
+}
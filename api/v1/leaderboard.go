@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+)
+
+// GetLeaderboard implements GET /leaderboard/nodes?type={bridge|full|light}
+func (a *RESTApiV1) GetLeaderboard(resp http.ResponseWriter, req *http.Request) {
+
+	var nType *receiver.NodeType
+	if typeStr := req.URL.Query().Get("type"); typeStr != "" {
+		t, err := metrics.ParseNodeType(typeStr)
+		if err != nil {
+			a.logger.Info(fmt.Sprintf("api `GetLeaderboard`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		nType = &t
+	}
+
+	limitOffset := a.getLimitOffsetFromHttpReq(req)
+
+	rows, totalRows, err := a.metrics.GetLeaderboard(nType, int(limitOffset.Offset), int(limitOffset.Limit))
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetLeaderboard`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"pagination": a.getPagination(uint64(totalRows), limitOffset),
+			"rows":       rows,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetLeaderboard` %v ", req.URL.Path))
+	a.logger.Debug(fmt.Sprintf("api call `GetLeaderboard` limitOffset: %#v totalRows: %v", limitOffset, totalRows))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetLeaderboard`: %v", err))
+	}
+}
@@ -6,119 +6,32 @@ import (
 	"strconv"
 
 	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/metrics"
 	"github.com/celestiaorg/nodelogger/database/models"
 	"github.com/gorilla/mux"
 )
 
 // GetBridgeNodes implements GET /metrics/nodes/bridge
 func (a *RESTApiV1) GetBridgeNodes(resp http.ResponseWriter, req *http.Request) {
-
-	limitOffset := a.getLimitOffsetFromHttpReq(req)
-
-	nodesList, totalRows, err := a.metrics.GetNodesByType(receiver.BridgeNodeType, int(limitOffset.Offset), int(limitOffset.Limit))
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("api `GetBridgeNodes`: %v", err))
-		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	err = sendJSON(resp,
-		map[string]interface{}{
-			"pagination": a.getPagination(uint64(totalRows), limitOffset.Page),
-			"rows":       nodesList,
-		},
-	)
-	a.logger.Info(fmt.Sprintf("api call `GetBridgeNodes` %v ", req.URL.Path))
-	a.logger.Debug(fmt.Sprintf("api call `GetBridgeNodes` limitOffset: %#v totalRows: %v", limitOffset, totalRows))
-
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("sendJSON `GetBridgeNodes`: %v", err))
-	}
+	nType := receiver.BridgeNodeType
+	a.listNodes(resp, req, "GetBridgeNodes", metrics.NodeFilter{NodeType: &nType}, metrics.DefaultNodeSort)
 }
 
 // GetFullNodes implements GET /metrics/nodes/full
 func (a *RESTApiV1) GetFullNodes(resp http.ResponseWriter, req *http.Request) {
-
-	limitOffset := a.getLimitOffsetFromHttpReq(req)
-
-	nodesList, totalRows, err := a.metrics.GetNodesByType(receiver.FullNodeType, int(limitOffset.Offset), int(limitOffset.Limit))
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("api `GetFullNodes`: %v", err))
-		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	err = sendJSON(resp,
-		map[string]interface{}{
-			"pagination": a.getPagination(uint64(totalRows), limitOffset.Page),
-			"rows":       nodesList,
-		},
-	)
-	a.logger.Info(fmt.Sprintf("api call `GetFullNodes` %v ", req.URL.Path))
-	a.logger.Debug(fmt.Sprintf("api call `GetFullNodes` limitOffset: %#v totalRows: %v", limitOffset, totalRows))
-
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("sendJSON `GetFullNodes`: %v", err))
-	}
+	nType := receiver.FullNodeType
+	a.listNodes(resp, req, "GetFullNodes", metrics.NodeFilter{NodeType: &nType}, metrics.DefaultNodeSort)
 }
 
 // GetLightNodes implements GET /metrics/nodes/light
 func (a *RESTApiV1) GetLightNodes(resp http.ResponseWriter, req *http.Request) {
-
-	limitOffset := a.getLimitOffsetFromHttpReq(req)
-
-	nodesList, totalRows, err := a.metrics.GetNodesByType(receiver.LightNodeType, int(limitOffset.Offset), int(limitOffset.Limit))
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("api `GetLightNodes`: %v", err))
-		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	err = sendJSON(resp,
-		map[string]interface{}{
-			"pagination": a.getPagination(uint64(totalRows), limitOffset.Page),
-			"rows":       nodesList,
-		},
-	)
-	a.logger.Info(fmt.Sprintf("api call `GetLightNodes` %v ", req.URL.Path))
-	a.logger.Debug(fmt.Sprintf("api call `GetLightNodes` limitOffset: %#v totalRows: %v", limitOffset, totalRows))
-
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("sendJSON `GetLightNodes`: %v", err))
-	}
+	nType := receiver.LightNodeType
+	a.listNodes(resp, req, "GetLightNodes", metrics.NodeFilter{NodeType: &nType}, metrics.DefaultNodeSort)
 }
 
 // GetNodeById implements GET /metrics/nodes/{id}
 func (a *RESTApiV1) GetNodeById(resp http.ResponseWriter, req *http.Request) {
-
-	id := mux.Vars(req)["id"]
-
-	limitOffset := a.getLimitOffsetFromHttpReq(req)
-
-	rows, totalRows, err := a.metrics.FindByNodeId(id, int(limitOffset.Offset), int(limitOffset.Limit))
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("api `GetNodeById`: %v", err))
-		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	if totalRows == 0 {
-		http.Error(resp, fmt.Sprintf("no data found for node id `%s`", id), http.StatusNotFound)
-		return
-	}
-
-	err = sendJSON(resp,
-		map[string]interface{}{
-			"pagination": a.getPagination(uint64(totalRows), limitOffset.Page),
-			"rows":       rows,
-		},
-	)
-	a.logger.Info(fmt.Sprintf("api call `GetNodeById` %v id: %v", req.URL.Path, id))
-	a.logger.Debug(fmt.Sprintf("api call `GetNodeById` limitOffset: %#v totalRows: %v", limitOffset, totalRows))
-
-	if err != nil {
-		a.logger.Error(fmt.Sprintf("sendJSON `GetNodeById`: %v", err))
-	}
+	a.listNodes(resp, req, "GetNodeById", metrics.NodeFilter{NodeId: mux.Vars(req)["id"]}, metrics.DefaultNodeIdSort)
 }
 
 // GetNodeByIdAtNetworkHeight implements GET /metrics/nodes/{id}/height/{height}
@@ -181,26 +94,147 @@ func (a *RESTApiV1) GetNodeByIdAtNetworkHeight(resp http.ResponseWriter, req *ht
 
 // GetAllNodes implements GET /metrics/nodes
 func (a *RESTApiV1) GetAllNodes(resp http.ResponseWriter, req *http.Request) {
+	a.listNodes(resp, req, "GetAllNodes", metrics.NodeFilter{}, metrics.DefaultNodeSort)
+}
+
+// listNodes is the generic handler of the node list endpoints, they are presets of it: the node type
+// or the node id set by the route override the query. The samples are filtered and sorted by the query,
+// and paginated with ?cursor= by default, or with ?page= for the clients sending it.
+func (a *RESTApiV1) listNodes(resp http.ResponseWriter, req *http.Request, apiName string, preset metrics.NodeFilter, defaultSort metrics.NodeSort) {
+
+	filter, err := getNodeFilterFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `%s`: %v", apiName, err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if preset.NodeType != nil {
+		filter.NodeType = preset.NodeType
+	}
+	if preset.NodeId != "" {
+		filter.NodeId = preset.NodeId
+	}
+
+	sort, err := getNodeSortFromHttpReq(req, defaultSort)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `%s`: %v", apiName, err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.URL.Query().Has("page") {
+		a.listNodesByPage(resp, req, apiName, filter, sort)
+		return
+	}
+	a.listNodesByCursor(resp, req, apiName, filter, sort)
+}
+
+func (a *RESTApiV1) listNodesByPage(resp http.ResponseWriter, req *http.Request, apiName string, filter metrics.NodeFilter, sort metrics.NodeSort) {
 
 	limitOffset := a.getLimitOffsetFromHttpReq(req)
 
-	nodesList, totalRows, err := a.metrics.GetAllNodes(int(limitOffset.Offset), int(limitOffset.Limit))
+	rows, totalRows, err := a.metrics.ListNodesByOffset(filter, sort, int(limitOffset.Offset), int(limitOffset.Limit))
 	if err != nil {
-		a.logger.Error(fmt.Sprintf("api `GetAllNodes`: %v", err))
+		a.logger.Error(fmt.Sprintf("api `%s`: %v", apiName, err))
 		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	if totalRows == 0 && a.nodeNotFound(resp, apiName, filter.NodeId) {
+		return
+	}
+
 	err = sendJSON(resp,
 		map[string]interface{}{
-			"pagination": a.getPagination(uint64(totalRows), limitOffset.Page),
-			"rows":       nodesList,
+			"pagination": a.getPagination(uint64(totalRows), limitOffset),
+			"rows":       rows,
 		},
 	)
-	a.logger.Info(fmt.Sprintf("api call `GetAllNodes` %v ", req.URL.Path))
-	a.logger.Debug(fmt.Sprintf("api call `GetAllNodes` limitOffset: %#v totalRows: %v", limitOffset, totalRows))
+	a.logger.Info(fmt.Sprintf("api call `%s` %v ", apiName, req.URL.Path))
+	a.logger.Debug(fmt.Sprintf("api call `%s` filter: %#v sort: %v limitOffset: %#v totalRows: %v", apiName, filter, sort, limitOffset, totalRows))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `%s`: %v", apiName, err))
+	}
+}
 
+func (a *RESTApiV1) listNodesByCursor(resp http.ResponseWriter, req *http.Request, apiName string, filter metrics.NodeFilter, sort metrics.NodeSort) {
+
+	after, err := decodeCursor(sort, req.URL.Query().Get("cursor"))
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `%s`: %v", apiName, err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	withCount, err := getCountFromHttpReq(req)
 	if err != nil {
-		a.logger.Error(fmt.Sprintf("sendJSON `GetAllNodes`: %v", err))
+		a.logger.Info(fmt.Sprintf("api `%s`: %v", apiName, err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	limit := a.getPageSize(req)
+
+	// one more row tells if there is a next page
+	rows, err := a.metrics.ListNodes(filter, sort, after, int(limit)+1)
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `%s`: %v", apiName, err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if after == nil && len(rows) == 0 && a.nodeNotFound(resp, apiName, filter.NodeId) {
+		return
+	}
+
+	pagination := CursorPagination{Limit: limit}
+	if uint64(len(rows)) > limit {
+		rows = rows[:limit]
+		pagination.NextCursor = encodeCursor(sort, sort.KeyOf(rows[len(rows)-1]))
+	}
+
+	if withCount {
+		totalRows, err := a.metrics.CountNodes(filter)
+		if err != nil {
+			a.logger.Error(fmt.Sprintf("api `%s`: %v", apiName, err))
+			http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		total := uint64(totalRows)
+		pagination.TotalRows = &total
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"pagination": pagination,
+			"rows":       rows,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `%s` %v ", apiName, req.URL.Path))
+	a.logger.Debug(fmt.Sprintf("api call `%s` filter: %#v sort: %v limit: %v rows: %v next: %v", apiName, filter, sort, limit, len(rows), pagination.NextCursor != ""))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `%s`: %v", apiName, err))
+	}
+}
+
+// nodeNotFound answers 404 if nodeId is set and the node has no samples at all, an empty page of an existing node is not an error
+func (a *RESTApiV1) nodeNotFound(resp http.ResponseWriter, apiName, nodeId string) bool {
+
+	if nodeId == "" {
+		return false
+	}
+
+	rows, err := a.metrics.ListNodes(metrics.NodeFilter{NodeId: nodeId}, metrics.DefaultNodeIdSort, nil, 1)
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `%s`: %v", apiName, err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return true
+	}
+	if len(rows) > 0 {
+		return false
+	}
+
+	http.Error(resp, fmt.Sprintf("no data found for node id `%s`", nodeId), http.StatusNotFound)
+	return true
 }
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// the routes taking a node {id}, %s is replaced by the escaped id
+var nodeIdRoutes = []string{
+	"/metrics/nodes/%s",
+	"/metrics/nodes/%s/history",
+	"/metrics/nodes/%s/height/12",
+	"/metrics/nodes/%s/height/1/20",
+	"/nodes/%s/timeline",
+	"/uptime/nodes/%s",
+	"/uptime/nodes/%s/gaps",
+	"/versions/nodes/%s",
+}
+
+func TestHostileNodeIds(t *testing.T) {
+
+	a, mt := newTestAPI(t)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		err := mt.InsertQueue.Add(&models.CelestiaNode{
+			NodeId:        "node-1",
+			Version:       "v0.9.1",
+			Head:          uint64(10 + i),
+			NetworkHeight: 12,
+			CreatedAt:     now.Add(time.Duration(i-3) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForRows(t, mt, 3)
+
+	tests := []struct {
+		name      string
+		id        string
+		malformed bool
+	}{
+		{name: "quote", id: "x' OR '1'='1"},
+		{name: "statement", id: `"; DROP TABLE celestia_nodes; --`},
+		{name: "like wildcards", id: "%_"},
+		{name: "unicode", id: "nœud-ü-节点"},
+		{name: "NUL byte", id: "node-1\x00", malformed: true},
+		{name: "invalid UTF-8", id: "node-\xff", malformed: true},
+		{name: "too long", id: strings.Repeat("a", maxNodeIdLength+1), malformed: true},
+	}
+
+	for _, tt := range tests {
+		for _, route := range nodeIdRoutes {
+			target := path(strings.Replace(route, "%s", url.PathEscape(tt.id), 1))
+
+			t.Run(tt.name+" "+route, func(t *testing.T) {
+				rec := a.serve(httptest.NewRequest("GET", target, nil))
+
+				switch {
+				case tt.malformed && rec.Code != http.StatusBadRequest:
+					t.Errorf("status %d, want 400", rec.Code)
+				case rec.Code >= 500:
+					t.Errorf("status %d: %s", rec.Code, rec.Body.String())
+				}
+				if strings.Contains(rec.Body.String(), `"node-1"`) {
+					t.Errorf("the samples of another node are returned: %s", rec.Body.String())
+				}
+			})
+		}
+	}
+
+	// the table is still there with all its samples
+	if count, err := mt.CountNodes(metrics.NodeFilter{NodeId: "node-1"}); err != nil || count != 3 {
+		t.Errorf("%d samples of node-1 left, want 3 (err: %v)", count, err)
+	}
+}
+
+func TestHostileNodeIdParams(t *testing.T) {
+
+	a, _ := newTestAPI(t)
+
+	for _, target := range []string{
+		path("/metrics/nodes?node_id_prefix=" + url.QueryEscape("x' OR '1'='1")),
+		path("/export?node_id=" + url.QueryEscape(`"; DROP TABLE celestia_nodes; --`)),
+	} {
+		if rec := a.serve(httptest.NewRequest("GET", target, nil)); rec.Code != http.StatusOK && rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d: %s", target, rec.Code, rec.Body.String())
+		}
+	}
+
+	for _, target := range []string{
+		path("/metrics/nodes?node_id_prefix=%00"),
+		path("/export?node_id=%ff"),
+	} {
+		if rec := a.serve(httptest.NewRequest("GET", target, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+// waitForRows waits for the insert queue to write n samples
+func waitForRows(t *testing.T, mt *metrics.Metrics, n uint64) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for mt.InsertQueue.Stats().InsertedRows < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d samples written, want %d", mt.InsertQueue.Stats().InsertedRows, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
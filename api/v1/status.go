@@ -0,0 +1,34 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// GetInsertQueueStatus implements GET /status/insertqueue
+func (a *RESTApiV1) GetInsertQueueStatus(resp http.ResponseWriter, req *http.Request) {
+
+	err := sendJSON(resp, a.metrics.InsertQueue.Stats())
+	a.logger.Debug(fmt.Sprintf("api call `GetInsertQueueStatus` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetInsertQueueStatus`: %v", err))
+	}
+}
+
+// GetSpoolStatus implements GET /status/spool
+func (a *RESTApiV1) GetSpoolStatus(resp http.ResponseWriter, req *http.Request) {
+
+	spool := a.metrics.InsertQueue.Spool()
+	if spool == nil {
+		http.Error(resp, "spool is not enabled", http.StatusNotFound)
+		return
+	}
+
+	err := sendJSON(resp, spool.Stats())
+	a.logger.Debug(fmt.Sprintf("api call `GetSpoolStatus` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetSpoolStatus`: %v", err))
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+)
+
+// Comments are sent this often on the idle streams, so the proxies do not close them
+const streamKeepAliveInterval = 15 * time.Second
+
+// StreamNodes implements GET /stream/nodes?id={node id}&type={bridge|full|light} as Server-Sent Events.
+// Every sample written into the database is sent as a `sample` event, all the nodes are streamed if no filter is set.
+// A client not keeping up gets a `dropped` event and the stream ends.
+func (a *RESTApiV1) StreamNodes(resp http.ResponseWriter, req *http.Request) {
+
+	filter := metrics.SampleFilter{NodeId: req.URL.Query().Get("id")}
+	if typeStr := req.URL.Query().Get("type"); typeStr != "" {
+		t, err := metrics.ParseNodeType(typeStr)
+		if err != nil {
+			a.logger.Info(fmt.Sprintf("api `StreamNodes`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.NodeType = &t
+	}
+
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		http.Error(resp, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	a.logger.Info(fmt.Sprintf("api call `StreamNodes` %v %v", req.URL.Path, req.URL.RawQuery))
+
+	sub := a.metrics.Hub.Subscribe(filter)
+	defer a.metrics.Hub.Unsubscribe(sub)
+
+	resp.Header().Set("Access-Control-Allow-Origin", "*")
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("X-Accel-Buffering", "no") // no buffering by nginx
+	resp.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+
+		case <-a.streams.Done():
+			fmt.Fprint(resp, "event: shutdown\ndata: {}\n\n")
+			flusher.Flush()
+			return
+
+		case <-keepAlive.C:
+			fmt.Fprint(resp, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case node, ok := <-sub.Samples():
+			if !ok {
+				if sub.Dropped() {
+					a.logger.Info(fmt.Sprintf("api `StreamNodes`: subscriber %v dropped for being too slow", req.RemoteAddr))
+					fmt.Fprint(resp, "event: dropped\ndata: {\"reason\": \"the client did not keep up\"}\n\n")
+					flusher.Flush()
+				}
+				return
+			}
+
+			data, err := json.Marshal(node)
+			if err != nil {
+				a.logger.Error(fmt.Sprintf("api `StreamNodes`: %v", err))
+				continue
+			}
+			if _, err := fmt.Fprintf(resp, "id: %d\nevent: sample\ndata: %s\n\n", node.ID, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Longest window a timeline can be requested for
+const maxTimelineWindow = 31 * 24 * time.Hour
+
+// GetNodeTimeline implements GET /nodes/{id}/timeline?from={RFC3339}&to={RFC3339}&stall_threshold={duration}
+func (a *RESTApiV1) GetNodeTimeline(resp http.ResponseWriter, req *http.Request) {
+
+	id := mux.Vars(req)["id"]
+
+	from, to, err := getTimeRangeFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetNodeTimeline`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	timelineTo := to // zero up to now, so the gap or stall still going on is reported
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		from = to.Add(-24 * time.Hour)
+	}
+	if !from.Before(to) || to.Sub(from) > maxTimelineWindow {
+		err = fmt.Errorf("`from` must be before `to`, and at most %v apart", maxTimelineWindow)
+		a.logger.Info(fmt.Sprintf("api `GetNodeTimeline`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var stallThreshold time.Duration
+	if stallStr := req.URL.Query().Get("stall_threshold"); stallStr != "" {
+		stallThreshold, err = time.ParseDuration(stallStr)
+		if err != nil || stallThreshold <= 0 {
+			err = fmt.Errorf("malformed `stall_threshold` value, a positive duration expected, e.g. 5m")
+			a.logger.Info(fmt.Sprintf("api `GetNodeTimeline`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	nodeRecords, _, err := a.metrics.FindByNodeId(id, 0, 1)
+	if err == nil && len(nodeRecords) == 0 {
+		err = fmt.Errorf("node data not found")
+		a.logger.Info(fmt.Sprintf("api `GetNodeTimeline`: %v", err))
+		http.Error(resp, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetNodeTimeline`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	events, err := a.metrics.GetNodeTimeline(id, from, timelineTo, stallThreshold)
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetNodeTimeline`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"node_id":   id,
+			"node_type": nodeRecords[0].NodeType.String(),
+			"from":      from,
+			"to":        to,
+			"events":    events,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetNodeTimeline` %v id: %v", req.URL.Path, id))
+	a.logger.Debug(fmt.Sprintf("api call `GetNodeTimeline` from: %v to: %v events: %v", from, to, len(events)))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetNodeTimeline`: %v", err))
+	}
+}
@@ -2,15 +2,56 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"math"
 	"net/http"
 	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
 )
 
-func (a *RESTApiV1) getPagination(totalRows, pageNumber uint64) Pagination {
-	totalPages := uint64(math.Ceil(float64(totalRows) / float64(a.rowsPerPage)))
+// Longest node id accepted, the libp2p peer ids are about 52 characters
+const maxNodeIdLength = 256
+
+// validNodeId rejects the node ids no node can have, the invalid UTF-8 and the NUL bytes would
+// make the database fail the query instead of finding nothing
+func validNodeId(name, id string) error {
+	if len(id) > maxNodeIdLength {
+		return fmt.Errorf("malformed `%s` value, at most %d bytes expected", name, maxNodeIdLength)
+	}
+	if !utf8.ValidString(id) {
+		return fmt.Errorf("malformed `%s` value, UTF-8 expected", name)
+	}
+	for _, r := range id {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("malformed `%s` value, control characters are not allowed", name)
+		}
+	}
+	return nil
+}
+
+// checkNodeId answers 400 to the requests of the routes taking a node {id} with a malformed one
+func (a *RESTApiV1) checkNodeId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+
+		if id, ok := mux.Vars(req)["id"]; ok {
+			if err := validNodeId("id", id); err != nil {
+				a.logger.Info(fmt.Sprintf("api %v: %v", req.URL.Path, err))
+				http.Error(resp, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		next.ServeHTTP(resp, req)
+	})
+}
+
+func (a *RESTApiV1) getPagination(totalRows uint64, limitOffset LimitOffset) Pagination {
+	totalPages := uint64(math.Ceil(float64(totalRows) / float64(limitOffset.Limit)))
 	return Pagination{
-		CurrentPage: pageNumber,
+		CurrentPage: limitOffset.Page,
 		TotalPages:  totalPages,
 		TotalRows:   totalRows,
 	}
@@ -23,16 +64,49 @@ func (a *RESTApiV1) getLimitOffsetFromHttpReq(req *http.Request) LimitOffset {
 		page = 1
 	}
 
-	offset := (page - 1) * a.rowsPerPage
+	limit := a.getPageSize(req)
+	offset := (page - 1) * limit
 
 	return LimitOffset{
-		Limit:  a.rowsPerPage,
+		Limit:  limit,
 		Offset: offset,
 		Page:   page,
 	}
 }
 
+// getTimeRangeFromHttpReq reads the optional `from` and `to` RFC3339 query params,
+// the missing ones are returned as zero times
+func getTimeRangeFromHttpReq(req *http.Request) (time.Time, time.Time, error) {
+
+	var from, to time.Time
+	var err error
+
+	if fromStr := req.URL.Query().Get("from"); fromStr != "" {
+		from, err = time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return from, to, fmt.Errorf("malformed `from` value, RFC3339 expected")
+		}
+	}
+
+	if toStr := req.URL.Query().Get("to"); toStr != "" {
+		to, err = time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return from, to, fmt.Errorf("malformed `to` value, RFC3339 expected")
+		}
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("`from` must be before `to`")
+	}
+
+	return from, to, nil
+}
+
 func sendJSON(resp http.ResponseWriter, obj interface{}) error {
+	return sendJSONWithStatus(resp, http.StatusOK, obj)
+}
+
+func sendJSONWithStatus(resp http.ResponseWriter, status int, obj interface{}) error {
 
 	data, err := json.MarshalIndent(obj, "", "  ")
 	if err != nil {
@@ -45,6 +119,7 @@ func sendJSON(resp http.ResponseWriter, obj interface{}) error {
 	resp.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
 	resp.Write(data)
 
 	return nil
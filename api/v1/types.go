@@ -1,7 +1,14 @@
 package api
 
 import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/alerting"
 	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/ingest"
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
@@ -10,8 +17,31 @@ type RESTApiV1 struct {
 	router *mux.Router
 	logger *zap.Logger
 
-	metrics     *metrics.Metrics
-	rowsPerPage uint64
+	serverMu sync.Mutex
+	server   *http.Server
+
+	// done on shutdown, so the streams end and do not hold the server
+	streams     context.Context
+	stopStreams context.CancelFunc
+
+	metrics        *metrics.Metrics
+	rowsPerPage    uint64
+	maxRowsPerPage uint64
+	startedAt      time.Time
+	productionMode bool
+	uiPath         string
+
+	readyMaxQueueDepth int64
+	readyMaxDataAge    time.Duration
+
+	minNodeVersion string // the nodes below are outdated
+
+	// set when the nodes metrics are pushed to the API instead of being polled
+	ingest      *ingest.Assembler
+	ingestToken string
+
+	// set when the alerting is enabled
+	alerts *alerting.Engine
 }
 
 type Pagination struct {
@@ -4,16 +4,25 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/celestiaorg/nodelogger/database/metrics"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 )
 
-// GetNodeUptimeById implements GET /uptime/nodes/{id}
+// GetNodeUptimeById implements GET /uptime/nodes/{id}?from={RFC3339}&to={RFC3339}&bucket={day|week|month}
 func (a *RESTApiV1) GetNodeUptimeById(resp http.ResponseWriter, req *http.Request) {
 
 	id := mux.Vars(req)["id"]
 
+	query := req.URL.Query()
+	if query.Has("from") || query.Has("to") || query.Has("bucket") {
+		a.getNodeUptimeInWindow(resp, req, id)
+		return
+	}
+
 	uptime, err := a.metrics.GetNodeUpTime(id)
 	if err != nil {
 		if errors.Is(gorm.ErrRecordNotFound, err) {
@@ -42,6 +51,7 @@ func (a *RESTApiV1) GetNodeUptimeById(resp http.ResponseWriter, req *http.Reques
 	err = sendJSON(resp,
 		map[string]interface{}{
 			"uptime":    uptime,
+			"scorer":    a.metrics.UptimeScorer().Name(),
 			"node_id":   id,
 			"node_type": nodeRecords[0].NodeType.String(),
 		},
@@ -52,3 +62,145 @@ func (a *RESTApiV1) GetNodeUptimeById(resp http.ResponseWriter, req *http.Reques
 		a.logger.Error(fmt.Sprintf("sendJSON `GetNodeUptimeById`: %v", err))
 	}
 }
+
+// getNodeUptimeInWindow serves the uptime of a node in the requested window,
+// or per bucket if a bucket is given
+func (a *RESTApiV1) getNodeUptimeInWindow(resp http.ResponseWriter, req *http.Request, id string) {
+
+	from, to, err := getTimeRangeFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var bucket metrics.UptimeBucket
+	if bucketStr := req.URL.Query().Get("bucket"); bucketStr != "" {
+		bucket, err = metrics.ParseUptimeBucket(bucketStr)
+		if err != nil {
+			a.logger.Info(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	if from.IsZero() {
+		switch bucket {
+		case metrics.UptimeBucketWeek:
+			from = to.AddDate(0, 0, -7*12)
+		case metrics.UptimeBucketMonth:
+			from = to.AddDate(0, -12, 0)
+		default:
+			from = to.AddDate(0, 0, -30)
+		}
+	}
+	if !from.Before(to) {
+		err = fmt.Errorf("`from` must be before `to`")
+		a.logger.Info(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nodeRecords, _, err := a.metrics.FindByNodeId(id, 0, 1)
+	if err == nil && len(nodeRecords) == 0 {
+		err = fmt.Errorf("node data not found")
+		a.logger.Info(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+		http.Error(resp, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	output := map[string]interface{}{
+		"node_id":   id,
+		"node_type": nodeRecords[0].NodeType.String(),
+		"scorer":    a.metrics.UptimeScorer().Name(),
+	}
+
+	if bucket == "" {
+		uptime, err := a.metrics.GetNodeUptimeInWindow(id, from, to)
+		if err != nil {
+			a.logger.Error(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+			http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		output["from"] = uptime.From
+		output["to"] = uptime.To
+		output["uptime"] = uptime.Uptime
+		output["runtime_seconds"] = uptime.Runtime
+		output["samples"] = uptime.Samples
+	} else {
+		buckets, err := a.metrics.GetNodeUptimeBuckets(id, bucket, from, to)
+		if err != nil {
+			if errors.Is(err, metrics.ErrTooManyBuckets) {
+				a.logger.Info(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+				http.Error(resp, err.Error(), http.StatusBadRequest)
+				return
+			}
+			a.logger.Error(fmt.Sprintf("api `GetNodeUptimeById`: %v", err))
+			http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		output["from"] = from
+		output["to"] = to
+		output["bucket"] = bucket
+		output["buckets"] = buckets
+	}
+
+	err = sendJSON(resp, output)
+	a.logger.Info(fmt.Sprintf("api call `GetNodeUptimeById` %v id: %v", req.URL.Path, id))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetNodeUptimeById`: %v", err))
+	}
+}
+
+// GetNodeUptimeGapsById implements GET /uptime/nodes/{id}/gaps?from={RFC3339}&to={RFC3339}
+func (a *RESTApiV1) GetNodeUptimeGapsById(resp http.ResponseWriter, req *http.Request) {
+
+	id := mux.Vars(req)["id"]
+
+	from, to, err := getTimeRangeFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetNodeUptimeGapsById`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	gaps, err := a.metrics.GetNodeGaps(id, from, to)
+	if err != nil {
+		if strings.Contains(err.Error(), "node not found") {
+			a.logger.Info(fmt.Sprintf("api `GetNodeUptimeGapsById`: %v", err))
+			http.Error(resp, err.Error(), http.StatusNotFound)
+			return
+		}
+		a.logger.Error(fmt.Sprintf("api `GetNodeUptimeGapsById`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	totalDowntime := float64(0)
+	for _, g := range gaps {
+		totalDowntime += g.DurationSeconds
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"node_id":                id,
+			"gap_threshold_seconds":  a.metrics.HeartbeatGapThreshold().Seconds(),
+			"total_downtime_seconds": totalDowntime,
+			"gaps":                   gaps,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetNodeUptimeGapsById` %v id: %v", req.URL.Path, id))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetNodeUptimeGapsById`: %v", err))
+	}
+}
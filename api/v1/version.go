@@ -1,9 +1,13 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/metrics"
 	"github.com/gorilla/mux"
 )
 
@@ -32,3 +36,210 @@ func (a *RESTApiV1) GetNodeVersionsById(resp http.ResponseWriter, req *http.Requ
 		a.logger.Error(fmt.Sprintf("sendJSON `GetNodeVersionsById`: %v", err))
 	}
 }
+
+// SetMinNodeVersion sets the version the nodes are considered outdated below, when none is asked
+func (a *RESTApiV1) SetMinNodeVersion(minVersion string) {
+	a.minNodeVersion = minVersion
+}
+
+// getVersionQuery reads the `type` and `active` query params shared by the version analytics
+func getVersionQuery(req *http.Request) (*receiver.NodeType, time.Duration, error) {
+
+	var nType *receiver.NodeType
+	if typeStr := req.URL.Query().Get("type"); typeStr != "" {
+		t, err := metrics.ParseNodeType(typeStr)
+		if err != nil {
+			return nil, 0, err
+		}
+		nType = &t
+	}
+
+	active := metrics.DefaultVersionActiveWindow
+	if activeStr := req.URL.Query().Get("active"); activeStr != "" {
+		var err error
+		active, err = time.ParseDuration(activeStr)
+		if err != nil || active <= 0 {
+			return nil, 0, fmt.Errorf("malformed `active` value, a positive duration expected, e.g. 24h")
+		}
+	}
+
+	return nType, active, nil
+}
+
+// GetVersions implements GET /versions?at={RFC3339}&type={bridge|full|light}&active={duration}
+// the nodes seen in the `active` window before `at` are counted per version and node type
+func (a *RESTApiV1) GetVersions(resp http.ResponseWriter, req *http.Request) {
+
+	nType, active, err := getVersionQuery(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetVersions`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	at := time.Now().UTC()
+	if atStr := req.URL.Query().Get("at"); atStr != "" {
+		at, err = time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			err = fmt.Errorf("malformed `at` value, RFC3339 expected")
+			a.logger.Info(fmt.Sprintf("api `GetVersions`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	counts, err := a.metrics.GetVersionCounts(at.Add(-active), at, nType)
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetVersions`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var total int64
+	for _, c := range counts {
+		total += c.Nodes
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"at":       at,
+			"active":   active.String(),
+			"nodes":    total,
+			"versions": counts,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetVersions` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetVersions`: %v", err))
+	}
+}
+
+// GetVersionAdoption implements GET /versions/adoption?from={RFC3339}&to={RFC3339}&bucket={day|week|month}&type={bridge|full|light}
+func (a *RESTApiV1) GetVersionAdoption(resp http.ResponseWriter, req *http.Request) {
+
+	nType, _, err := getVersionQuery(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetVersionAdoption`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := getTimeRangeFromHttpReq(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetVersionAdoption`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+
+	bucket := metrics.UptimeBucketDay
+	if bucketStr := req.URL.Query().Get("bucket"); bucketStr != "" {
+		bucket, err = metrics.ParseUptimeBucket(bucketStr)
+		if err != nil {
+			a.logger.Info(fmt.Sprintf("api `GetVersionAdoption`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	releases, err := a.metrics.GetVersionReleases(nType)
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetVersionAdoption`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// the series starts when the oldest version appeared
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -30)
+		if len(releases) > 0 && releases[0].FirstSeen.After(from) {
+			from = releases[0].FirstSeen
+		}
+	}
+	if !from.Before(to) {
+		err = fmt.Errorf("`from` must be before `to`")
+		a.logger.Info(fmt.Sprintf("api `GetVersionAdoption`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	series, err := a.metrics.GetVersionAdoption(bucket, from, to, nType)
+	if err != nil {
+		if errors.Is(err, metrics.ErrTooManyBuckets) {
+			a.logger.Info(fmt.Sprintf("api `GetVersionAdoption`: %v", err))
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		a.logger.Error(fmt.Sprintf("api `GetVersionAdoption`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"from":     from,
+			"to":       to,
+			"bucket":   bucket,
+			"releases": releases,
+			"series":   series,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetVersionAdoption` %v", req.URL.Path))
+	a.logger.Debug(fmt.Sprintf("api call `GetVersionAdoption` from: %v to: %v bucket: %v", from, to, bucket))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetVersionAdoption`: %v", err))
+	}
+}
+
+// GetOutdatedNodes implements GET /versions/outdated?min_version={version}&type={bridge|full|light}&active={duration}
+func (a *RESTApiV1) GetOutdatedNodes(resp http.ResponseWriter, req *http.Request) {
+
+	nType, active, err := getVersionQuery(req)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetOutdatedNodes`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	minVersionStr := req.URL.Query().Get("min_version")
+	if minVersionStr == "" {
+		minVersionStr = a.minNodeVersion
+	}
+	if minVersionStr == "" {
+		err = fmt.Errorf("`min_version` is required, no minimum version is configured")
+		a.logger.Info(fmt.Sprintf("api `GetOutdatedNodes`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	minVersion, err := metrics.ParseVersion(minVersionStr)
+	if err != nil {
+		a.logger.Info(fmt.Sprintf("api `GetOutdatedNodes`: %v", err))
+		http.Error(resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := a.metrics.GetOutdatedNodes(minVersion, time.Now().Add(-active), nType)
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("api `GetOutdatedNodes`: %v", err))
+		http.Error(resp, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = sendJSON(resp,
+		map[string]interface{}{
+			"min_version": minVersion.String(),
+			"active":      active.String(),
+			"nodes":       len(rows),
+			"rows":        rows,
+		},
+	)
+	a.logger.Info(fmt.Sprintf("api call `GetOutdatedNodes` %v", req.URL.Path))
+
+	if err != nil {
+		a.logger.Error(fmt.Sprintf("sendJSON `GetOutdatedNodes`: %v", err))
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configPrintCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "configuration commands",
+	// the config is only loaded here, so the commands can show what is wrong with it
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig(cmd)
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check the effective configuration is valid for the start command",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if err := cfg.ValidateStart(); err != nil {
+			return err
+		}
+
+		fmt.Println("config is valid")
+		return nil
+	},
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "print the effective configuration as YAML, with the secrets redacted",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		defer enc.Close()
+
+		return enc.Encode(cfg.Redacted())
+	},
+}
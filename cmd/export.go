@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/export"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().String("format", "csv", "csv, ndjson or parquet")
+	exportCmd.Flags().StringP("output", "o", "-", "file the samples are written to, - for stdout")
+	exportCmd.Flags().Bool("gzip", false, "gzip the output")
+
+	exportCmd.Flags().String("node-id", "", "only the samples of this node")
+	exportCmd.Flags().String("node-id-prefix", "", "only the nodes whose id starts with this prefix")
+	exportCmd.Flags().String("type", "", "only this node type, bridge, full or light")
+	exportCmd.Flags().String("version", "", "only this version, or a version range e.g. >=v0.9.0,<v0.10.0")
+	exportCmd.Flags().Float64("min-uptime", 0, "only the samples with at least this uptime")
+	exportCmd.Flags().Float64("max-uptime", 0, "only the samples with at most this uptime")
+	exportCmd.Flags().String("from", "", "RFC3339, only the samples written from this time")
+	exportCmd.Flags().String("to", "", "RFC3339, only the samples written before this time")
+	exportCmd.Flags().Uint64("min-head", 0, "only the samples with at least this head")
+	exportCmd.Flags().Uint64("min-network-height", 0, "only the samples with at least this network height")
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export the node samples as CSV, newline-delimited JSON or Parquet, the filters are the ones of the API",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		flags := cmd.Flags()
+
+		formatStr, _ := flags.GetString("format")
+		format, err := export.ParseFormat(formatStr)
+		if err != nil {
+			return err
+		}
+
+		filter, err := getExportFilter(flags)
+		if err != nil {
+			return err
+		}
+
+		output, _ := flags.GetString("output")
+		gzipped, _ := flags.GetBool("gzip")
+
+		// the logs must not end up in the export
+		logger, err := getLoggerTo("stderr")
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		mt := metrics.NewWithStorage(getStorage(logger))
+
+		/*------*/
+
+		var out io.Writer = os.Stdout
+		if output != "-" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+
+		var gz *gzip.Writer
+		if gzipped {
+			gz = gzip.NewWriter(out)
+			out = gz
+		}
+
+		begin := time.Now()
+		w := export.NewWriter(format, out)
+		rows := 0
+		err = mt.ExportNodes(context.Background(), filter, func(node *models.CelestiaNode) error {
+			rows++
+			return w.Write(node)
+		})
+		if err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Close(); err != nil {
+				return err
+			}
+		}
+
+		if output != "-" {
+			fmt.Printf("%d samples exported to %q in %v\n", rows, output, time.Since(begin).Round(time.Millisecond))
+		}
+
+		return nil
+	},
+}
+
+// getExportFilter reads the filters of the export command, they match the query params of the API
+func getExportFilter(flags *pflag.FlagSet) (metrics.NodeFilter, error) {
+
+	var filter metrics.NodeFilter
+	var err error
+
+	filter.NodeId, _ = flags.GetString("node-id")
+	filter.NodeIdPrefix, _ = flags.GetString("node-id-prefix")
+
+	if typeStr, _ := flags.GetString("type"); typeStr != "" {
+		t, err := metrics.ParseNodeType(typeStr)
+		if err != nil {
+			return filter, err
+		}
+		filter.NodeType = &t
+	}
+
+	if version, _ := flags.GetString("version"); metrics.IsVersionRange(version) {
+		if filter.VersionRange, err = metrics.ParseVersionRange(version); err != nil {
+			return filter, err
+		}
+	} else {
+		filter.Version = version
+	}
+
+	if flags.Changed("min-uptime") {
+		v, _ := flags.GetFloat64("min-uptime")
+		filter.MinUptime = &v
+	}
+	if flags.Changed("max-uptime") {
+		v, _ := flags.GetFloat64("max-uptime")
+		filter.MaxUptime = &v
+	}
+
+	if fromStr, _ := flags.GetString("from"); fromStr != "" {
+		if filter.From, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			return filter, fmt.Errorf("malformed --from value, RFC3339 expected")
+		}
+	}
+	if toStr, _ := flags.GetString("to"); toStr != "" {
+		if filter.To, err = time.Parse(time.RFC3339, toStr); err != nil {
+			return filter, fmt.Errorf("malformed --to value, RFC3339 expected")
+		}
+	}
+
+	filter.MinHead, _ = flags.GetUint64("min-head")
+	filter.MinNetworkHeight, _ = flags.GetUint64("min-network-height")
+
+	return filter, nil
+}
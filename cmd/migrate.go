@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/celestiaorg/nodelogger/database"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+
+	migrateUpCmd.Flags().Uint64("to", 0, "apply the migrations up to this version, all of them if not set")
+	migrateDownCmd.Flags().Int("steps", 1, "number of migrations to roll back")
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "database schema migrations, the start command applies the pending ones too",
+	Args:  cobra.ExactArgs(1),
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "apply the pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		target, err := cmd.Flags().GetUint64("to")
+		if err != nil {
+			return err
+		}
+
+		logger, err := getLogger()
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		db := getDatabaseWithoutMigrations(logger)
+
+		applied, err := database.MigrateUp(db, target)
+		for _, m := range applied {
+			fmt.Printf("%04d_%s applied\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Nothing to apply, the schema is up to date.")
+		}
+
+		return nil
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "roll back the latest applied migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		steps, err := cmd.Flags().GetInt("steps")
+		if err != nil {
+			return err
+		}
+		if steps <= 0 {
+			return fmt.Errorf("--steps must be positive")
+		}
+
+		logger, err := getLogger()
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		db := getDatabaseWithoutMigrations(logger)
+
+		rolledBack, err := database.MigrateDown(db, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("%04d_%s rolled back\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("Nothing to roll back.")
+		}
+
+		return nil
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "list the migrations and whether they are applied",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		logger, err := getLogger()
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		db := getDatabaseWithoutMigrations(logger)
+
+		statuses, err := database.GetMigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-8s %-36s %s\n", "VERSION", "NAME", "STATUS")
+		for _, s := range statuses {
+			status := "pending"
+			if s.AppliedAt != nil {
+				status = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			if s.Missing {
+				status += " (not known to this build)"
+			}
+			fmt.Printf("%04d     %-36s %s\n", s.Version, s.Name, status)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(partitionsCmd)
+
+	partitionsCmd.AddCommand(partitionsListCmd)
+	partitionsCmd.AddCommand(partitionsConvertCmd)
+	partitionsCmd.AddCommand(partitionsCreateCmd)
+	partitionsCmd.AddCommand(partitionsPruneCmd)
+
+	partitionsCreateCmd.Flags().Int("months", 1, "number of months ahead to create the partitions for")
+	partitionsPruneCmd.Flags().Int("retention-days", 0, "remove the partitions older than this, defaults to `RETENTION_DAYS`")
+	partitionsPruneCmd.Flags().Bool("detach", false, "detach the old partitions instead of dropping them, defaults to `RETENTION_MODE`=detach")
+	partitionsPruneCmd.Flags().Bool("dry-run", false, "only list the partitions that would be removed")
+}
+
+var partitionsCmd = &cobra.Command{
+	Use:   "partitions",
+	Short: "manage the monthly partitions of the metrics table",
+	Args:  cobra.ExactArgs(1),
+}
+
+var partitionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the partitions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		logger, err := getLogger()
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		db := getDatabase(logger)
+
+		partitioned, err := database.IsPartitioned(db, database.PartitionedTable)
+		if err != nil {
+			return err
+		}
+		if !partitioned {
+			fmt.Printf("Table %q is not partitioned, run `partitions convert` to partition it.\n", database.PartitionedTable)
+			return nil
+		}
+
+		partitions, err := database.ListPartitions(db, database.PartitionedTable)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%-28s %-12s %-12s %14s %14s\n", "NAME", "FROM", "TO", "ROWS (EST.)", "SIZE (BYTES)")
+		for _, p := range partitions {
+			fmt.Printf("%-28s %-12s %-12s %14d %14d\n", p.Name, p.From.Format("2006-01-02"), p.To.Format("2006-01-02"), p.Rows, p.SizeBytes)
+		}
+
+		return nil
+	},
+}
+
+var partitionsConvertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "convert the metrics table into a table partitioned by month, this locks the table while the rows are moved",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		logger, err := getLogger()
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		db := getDatabase(logger)
+
+		fmt.Printf("Converting %q into a partitioned table...", database.PartitionedTable)
+		begin := time.Now()
+		if err := database.ConvertToPartitioned(db, database.PartitionedTable, database.DefaultPartitionsAhead); err != nil {
+			return err
+		}
+		fmt.Printf("Done in %v.\n", time.Since(begin))
+
+		return nil
+	},
+}
+
+var partitionsCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "create the missing partitions from the current month on",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		months, err := cmd.Flags().GetInt("months")
+		if err != nil {
+			return err
+		}
+
+		logger, err := getLogger()
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		db := getDatabase(logger)
+
+		now := time.Now()
+		created, err := database.EnsurePartitions(db, database.PartitionedTable, now, now.AddDate(0, months, 0))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%d partitions created: %v\n", len(created), created)
+		return nil
+	},
+}
+
+var partitionsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "apply the retention policy, drop or detach the partitions older than the retention period",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		logger, err := getLogger()
+		if err != nil {
+			panic(err)
+		}
+		defer logger.Sync()
+
+		policy := getRetentionPolicy()
+		if cmd.Flags().Changed("retention-days") {
+			if policy.Days, err = cmd.Flags().GetInt("retention-days"); err != nil {
+				return err
+			}
+		}
+		if cmd.Flags().Changed("detach") {
+			if policy.Detach, err = cmd.Flags().GetBool("detach"); err != nil {
+				return err
+			}
+		}
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+
+		if policy.Days <= 0 {
+			return fmt.Errorf("no retention period is set, use --retention-days or `RETENTION_DAYS`")
+		}
+
+		db := getDatabase(logger)
+		cutoff := time.Now().AddDate(0, 0, -policy.Days)
+
+		if dryRun {
+			partitions, err := database.ListPartitions(db, database.PartitionedTable)
+			if err != nil {
+				return err
+			}
+			for _, p := range partitions {
+				if !p.To.After(cutoff) {
+					fmt.Printf("%s would be removed\n", p.Name)
+				}
+			}
+			return nil
+		}
+
+		removed, err := database.RemovePartitionsBefore(db, database.PartitionedTable, cutoff, policy.Detach)
+		if err != nil {
+			return err
+		}
+
+		action := "dropped"
+		if policy.Detach {
+			action = "detached"
+		}
+		fmt.Printf("%d partitions %s: %v\n", len(removed), action, removed)
+		return nil
+	},
+}
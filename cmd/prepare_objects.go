@@ -2,137 +2,132 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/celestiaorg/leaderboard-backend/receiver"
 	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"github.com/celestiaorg/nodelogger/ingest"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gorm.io/gorm"
 )
 
 func getLogger() (*zap.Logger, error) {
+	return getLoggerTo("stdout")
+}
 
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logLevel = "info"
-	}
+// getLoggerTo is getLogger for the commands writing their own output to stdout, outputPath is used in production mode
+func getLoggerTo(outputPath string) (*zap.Logger, error) {
 
-	var cfg zap.Config
+	var zapCfg zap.Config
 
-	if os.Getenv("PRODUCTION_MODE") == "true" {
-		cfg = zap.NewProductionConfig()
-		cfg.OutputPaths = []string{"stdout"}
-		cfg.ErrorOutputPaths = []string{"stderr"}
-		cfg.Encoding = "console" // "console" | "json"
+	if cfg.ProductionMode {
+		zapCfg = zap.NewProductionConfig()
+		zapCfg.OutputPaths = []string{outputPath}
+		zapCfg.ErrorOutputPaths = []string{"stderr"}
+		zapCfg.Encoding = "console" // "console" | "json"
 
 	} else {
-		cfg = zap.NewDevelopmentConfig()
+		zapCfg = zap.NewDevelopmentConfig()
 		// Use only with console encoder (i.e. not in production)
-		cfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		zapCfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	}
 
 	var err error
-	cfg.Level, err = zap.ParseAtomicLevel(logLevel)
+	zapCfg.Level, err = zap.ParseAtomicLevel(cfg.LogLevel)
 	if err != nil {
 		return nil, fmt.Errorf("getLogger: %v", err)
 	}
 
-	return cfg.Build()
+	return zapCfg.Build()
 }
 
-func getUptimeStartTime(logger *zap.Logger) time.Time {
-
-	uptimeStartTimeStr := os.Getenv("UPTIME_START_TIME")
-	if uptimeStartTimeStr == "" {
-		logger.Fatal("`UPTIME_START_TIME` is empty")
-	}
-	uptimeStartTime, err := time.Parse(time.RFC3339, uptimeStartTimeStr)
-	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error parsing `UPTIME_START_TIME` date: %v", err))
-	}
-
-	return uptimeStartTime
-}
-
-func getUptimeEndTime(logger *zap.Logger) time.Time {
-
-	uptimeEndTimeStr := os.Getenv("UPTIME_END_TIME")
-	if uptimeEndTimeStr == "" {
-		logger.Fatal("`UPTIME_END_TIME` is empty")
-	}
-	uptimeEndTime, err := time.Parse(time.RFC3339, uptimeEndTimeStr)
-	if err != nil {
-		logger.Fatal(fmt.Sprintf("Error parsing `UPTIME_END_TIME` date: %v", err))
-	}
-
-	return uptimeEndTime
+func getUptimeScorer() metrics.UptimeScorer {
+	scorer, _ := metrics.ParseUptimeScorer(cfg.Uptime.Scorer) // validated with the config
+	return scorer
 }
 
 func getPrometheusReceiver(logger *zap.Logger) *receiver.PrometheusReceiver {
 
-	if getDemoMode() {
+	if cfg.Demo {
 		logger.Info("Demo mode activated")
 		return receiver.NewPrometheusReceiver("0", 10, logger, "", 0, time.Now().Add(-10*24*time.Hour), true)
 	}
 
-	promURL := os.Getenv("PROMETHEUS_URL")
-	if promURL == "" {
-		logger.Fatal("`PROMETHEUS_URL` is empty")
-	}
+	return receiver.NewPrometheusReceiver(cfg.Prometheus.URL, cfg.Prometheus.SyncInterval, logger, cfg.Prometheus.NamespacePrefix, 0, cfg.Uptime.StartTime, false)
+}
 
-	promNSprefix := os.Getenv("PROMETHEUS_NAMESPACE_PREFIX") // This can be empty
+func getTendermintReceiver(logger *zap.Logger) *receiver.TendermintReceiver {
 
-	promIntervalStr := os.Getenv("PROMETHEUS_SYNC_INTERVAL")
-	if promIntervalStr == "" {
-		logger.Warn("`PROMETHEUS_SYNC_INTERVAL` is empty")
-		promIntervalStr = "60"
+	if cfg.Demo {
+		return receiver.NewTendermintReceiver("", 0, 0, 3600, "", logger, true)
 	}
 
-	promInterval, err := strconv.ParseUint(promIntervalStr, 10, 64)
+	return receiver.NewTendermintReceiver(cfg.Tendermint.RPC, 10, 10, 10, "", logger, false)
+}
+
+// getDatabase opens the database and applies the pending migrations
+func getDatabase(logger *zap.Logger) *gorm.DB {
+
+	db, err := database.Init(cfg.PostgresConnStr())
 	if err != nil {
-		logger.Fatal(fmt.Sprintf("`PROMETHEUS_SYNC_INTERVAL` env: %v", err))
+		logger.Fatal(fmt.Sprintf("database initialization: %v", err))
 	}
 
-	uptimeStartTime := getUptimeStartTime(logger)
-
-	return receiver.NewPrometheusReceiver(promURL, promInterval, logger, promNSprefix, 0, uptimeStartTime, false)
+	return db
 }
 
-func getTendermintReceiver(logger *zap.Logger) *receiver.TendermintReceiver {
+// getStorage opens the storage backend picked by `database.driver`
+func getStorage(logger *zap.Logger) storage.Storage {
 
-	if getDemoMode() {
-		return receiver.NewTendermintReceiver("", 0, 0, 3600, "", logger, true)
+	if storage.Dialect(cfg.Database.Driver) == storage.DialectSQLite {
+		store, err := storage.OpenSQLite(cfg.Database.SQLitePath)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("database initialization: %v", err))
+		}
+		logger.Info(fmt.Sprintf("Using the embedded SQLite database %q", cfg.Database.SQLitePath))
+		return store
 	}
 
-	rpcAddr := os.Getenv("APP_TM_RPC")
-	if rpcAddr == "" {
-		logger.Fatal("`APP_TM_RPC` is empty")
-	}
-
-	return receiver.NewTendermintReceiver(rpcAddr, 10, 10, 10, "", logger, false)
+	return storage.NewPostgres(getDatabase(logger))
 }
 
-func getDemoMode() bool {
-	return os.Getenv("DEMO") == "true"
+// getDatabaseWithoutMigrations opens the database as it is, for the migration commands
+func getDatabaseWithoutMigrations(logger *zap.Logger) *gorm.DB {
+
+	db, err := database.Open(cfg.PostgresConnStr())
+	if err != nil {
+		logger.Fatal(fmt.Sprintf("database initialization: %v", err))
+	}
+
+	return db
 }
 
-func getDatabase(logger *zap.Logger) *gorm.DB {
+func configureInsertQueue(logger *zap.Logger, queue *metrics.InsertQueue) {
 
-	psqlconn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"),
-		os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PASSWORD"),
-		os.Getenv("POSTGRES_DB"),
-	)
+	queue.SetBatchSize(cfg.InsertQueue.BatchSize)
+	queue.SetFlushInterval(cfg.InsertQueue.FlushInterval)
 
-	db, err := database.Init(psqlconn)
+	spool, err := metrics.OpenSpool(cfg.InsertQueue.SpoolDir, metrics.DefaultSpoolSegmentSize)
 	if err != nil {
-		logger.Fatal(fmt.Sprintf("database initialization: %v", err))
+		logger.Fatal(fmt.Sprintf("opening the spool: %v", err))
+	}
+	if stats := spool.Stats(); stats.Samples > 0 {
+		logger.Info(fmt.Sprintf("%d spooled samples found in %q, they will be replayed", stats.Samples, stats.Dir))
 	}
+	queue.SetSpool(spool)
+}
 
-	return db
+func getRetentionPolicy() database.RetentionPolicy {
+	return database.RetentionPolicy{
+		Days:   cfg.Retention.Days,
+		Detach: cfg.Retention.Mode == "detach",
+	}
+}
+
+// getIngestAssembler merges the pushed series into samples and queues them for insertion
+func getIngestAssembler(mt *metrics.Metrics) *ingest.Assembler {
+	return ingest.NewAssembler(mt.InsertQueue.Add, mt.ScoreReportedUptime, cfg.Prometheus.NamespacePrefix, cfg.Ingest.MergeWindow)
 }
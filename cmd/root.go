@@ -5,10 +5,60 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/celestiaorg/nodelogger/alerting"
+	"github.com/celestiaorg/nodelogger/api/v1"
+	"github.com/celestiaorg/nodelogger/config"
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/ingest"
 	"github.com/spf13/cobra"
 )
 
-var rootCmd = &cobra.Command{}
+// cfg is the effective configuration, loaded before any command runs
+var cfg *config.Config
+
+var rootCmd = &cobra.Command{
+	SilenceUsage:  true,
+	SilenceErrors: true, // Execute prints them
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfig(cmd); err != nil {
+			return err
+		}
+		return cfg.Validate()
+	},
+}
+
+// configDefaults are the defaults of the config keys whose values belong to the other packages
+var configDefaults = map[string]interface{}{
+	"api.rows_per_page":     api.DefaultRowsPerPage,
+	"api.max_rows_per_page": api.DefaultMaxRowsPerPage,
+	"api.ui_path":           api.DefaultUIPath,
+
+	"insert_queue.batch_size":     metrics.DefaultInsertBatchSize,
+	"insert_queue.flush_interval": metrics.DefaultInsertFlushInterval,
+
+	"workers.runtime_checkpoint_interval":    metrics.DefaultRuntimeCheckpointInterval,
+	"workers.rollup_interval":                metrics.DefaultRollupInterval,
+	"workers.partition_maintenance_interval": database.DefaultPartitionMaintenanceInterval,
+
+	"ingest.merge_window": ingest.DefaultMergeWindow,
+
+	"ready.max_queue_depth": api.DefaultReadyMaxQueueDepth,
+	"ready.max_data_age":    api.DefaultReadyMaxDataAge,
+
+	"alerting.evaluation_interval": alerting.DefaultEvaluationInterval,
+	"alerting.node_ttl":            alerting.DefaultNodeTTL,
+}
+
+func init() {
+	config.RegisterFlags(rootCmd.PersistentFlags())
+}
+
+func loadConfig(cmd *cobra.Command) error {
+	var err error
+	cfg, err = config.Load(cmd.Flags(), configDefaults)
+	return err
+}
 
 func Execute() {
 
@@ -1,14 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/alerting"
 	"github.com/celestiaorg/nodelogger/api/v1"
+	"github.com/celestiaorg/nodelogger/database"
 	"github.com/celestiaorg/nodelogger/database/metrics"
 	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"github.com/celestiaorg/nodelogger/telemetry"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 func init() {
@@ -21,6 +30,10 @@ var startCmd = &cobra.Command{
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
+		if err := cfg.ValidateStart(); err != nil {
+			return err
+		}
+
 		logger, err := getLogger()
 		if err != nil {
 			panic(err)
@@ -29,65 +42,244 @@ var startCmd = &cobra.Command{
 
 		/*------*/
 
-		db := getDatabase(logger)
+		store := getStorage(logger)
 
 		/*------*/
 
-		mt := metrics.New(db)
-		mt.InsertQueue.Start()
+		mt := metrics.NewWithStorage(store)
+		mt.SetUptimeWindow(cfg.Uptime.StartTime, cfg.Uptime.EndTime)
+		mt.SetUptimeScorer(getUptimeScorer())
+		mt.SetHeartbeatGapThreshold(cfg.HeartbeatGapThreshold())
+		if err := mt.RefreshLatestNodeStates(); err != nil {
+			logger.Error(fmt.Sprintf("latest node states: %v", err))
+		}
+		configureInsertQueue(logger, mt.InsertQueue)
+		registerInsertQueueMetrics(mt.InsertQueue)
+		telemetry.RegisterGaugeFunc("stream_subscribers", "Clients streaming the node samples.", func() float64 {
+			return float64(mt.Hub.Len())
+		})
+		if err := mt.InsertQueue.Start(); err != nil {
+			return err
+		}
+
+		workersCtx, stopWorkers := context.WithCancel(cmd.Context())
+		defer stopWorkers()
+		go mt.RunRuntimeCheckpointer(workersCtx, cfg.Workers.RuntimeCheckpointInterval)
+		go mt.RunRollups(workersCtx, cfg.Workers.RollupInterval)
+		if store.Dialect() == storage.DialectPostgres {
+			go database.RunPartitionMaintainer(workersCtx, store.DB(), database.PartitionedTable, getRetentionPolicy(), cfg.Workers.PartitionMaintenanceInterval)
+		}
+
+		var alerts *alerting.Engine
+		if len(cfg.Alerting.Rules) > 0 {
+			alerts, err = startAlerting(workersCtx, logger, mt)
+			if err != nil {
+				return err
+			}
+		}
 
 		/*------*/
 
-		prom := getPrometheusReceiver(logger)
-		prom.SetOnNewDataCallBack(func(node *receiver.CelestiaNode) {
-
-			mt.InsertQueue.Add(&models.CelestiaNode{
-				NodeId:                      node.ID,
-				NodeType:                    node.Type,
-				Version:                     node.Version,
-				LastPfbTimestamp:            node.LastPfbTimestamp,
-				PfbCount:                    node.PfbCount,
-				Head:                        node.Head,
-				NetworkHeight:               node.NetworkHeight,
-				DasLatestSampledTimestamp:   node.DasLatestSampledTimestamp,
-				DasNetworkHead:              node.DasNetworkHead,
-				DasSampledChainHead:         node.DasSampledChainHead,
-				DasSampledHeadersCounter:    node.DasSampledHeadersCounter,
-				DasTotalSampledHeaders:      node.DasTotalSampledHeaders,
-				TotalSyncedHeaders:          node.TotalSyncedHeaders,
-				StartTime:                   node.StartTime,
-				LastRestartTime:             node.LastRestartTime,
-				NodeRuntimeCounterInSeconds: node.NodeRuntimeCounterInSeconds,
-				LastAccumulativeNodeRuntimeCounterInSeconds: node.LastAccumulativeNodeRuntimeCounterInSeconds,
-				Uptime: node.Uptime,
-			})
+		stopPoller := func() {}
+		if cfg.PollingEnabled() {
+			stopPoller = startPrometheusPoller(logger, mt)
+		} else {
+			logger.Info("`PROMETHEUS_URL` is empty, the metrics are only received by push")
+		}
+
+		/*------*/
 
-		})
-		// logger.Info(fmt.Sprintf("%d data points stored in db", len(data)))
+		restApi := api.NewRESTApiV1(mt, logger)
+		restApi.SetRowsPerPage(cfg.API.RowsPerPage)
+		restApi.SetMaxRowsPerPage(cfg.API.MaxRowsPerPage)
+		restApi.SetProductionMode(cfg.ProductionMode)
+		restApi.SetUIPath(cfg.API.UIPath)
+		restApi.SetReadinessLimits(cfg.Ready.MaxQueueDepth, cfg.Ready.MaxDataAge)
+		restApi.SetMinNodeVersion(cfg.Versions.MinVersion)
+		if alerts != nil {
+			restApi.SetAlerting(alerts)
+		}
+
+		ingestCtx, stopIngest := context.WithCancel(context.Background())
+		defer stopIngest()
+		ingestDone := make(chan struct{})
+		if cfg.PushEnabled() {
+			assembler := getIngestAssembler(mt)
+			restApi.SetIngest(assembler, cfg.Ingest.Token)
+			if cfg.Ingest.RemoteWriteEnabled {
+				restApi.EnableRemoteWrite()
+			}
+			if cfg.Ingest.OTLPEnabled {
+				restApi.EnableOTLP()
+			}
+			go func() {
+				defer close(ingestDone)
+				if err := assembler.Run(ingestCtx); err != nil {
+					logger.Error(fmt.Sprintf("metrics ingestion: %v", err))
+				}
+			}()
+		} else {
+			close(ingestDone)
+		}
 
-		// The tendermint receiver is needed to get the network height
-		// as we do not collect data about validators, it does not need to be initiated
-		tm := getTendermintReceiver(logger)
+		shutdownTimeout := cfg.ShutdownTimeout
 
-		re := receiver.New(prom, nil, tm, logger)
-		// Only prometheus receiver service need to be initiated
-		re.InitPrometheus()
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- restApi.Serve(cfg.API.Address, cfg.API.OriginAllowed)
+		}()
+
+		select {
+		case err := <-serveErr:
+			logger.Fatal(fmt.Sprintf("REST API server: %v", err))
+		case <-ctx.Done():
+		}
+		stop() // a second signal kills the process right away
 
 		/*------*/
 
-		restApi := api.NewRESTApiV1(mt, logger)
+		logger.Info(fmt.Sprintf("shutting down, waiting up to %v for the pending work", shutdownTimeout))
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
 
-		addr := os.Getenv("REST_API_ADDRESS")
-		if addr == "" {
-			logger.Fatal("`REST_API_ADDRESS` is empty")
+		if err := restApi.Shutdown(shutdownCtx); err != nil {
+			logger.Error(fmt.Sprintf("REST API server shutdown: %v", err))
 		}
 
-		originAllowed := os.Getenv("ORIGIN_ALLOWED")
-		if originAllowed == "" {
-			logger.Fatal("`ORIGIN_ALLOWED` is empty")
+		// nothing new comes in while the queue is drained, the samples still being merged go into it first
+		stopPoller()
+		stopIngest()
+		<-ingestDone
+
+		logger.Info(fmt.Sprintf("flushing %d queued samples into the database", mt.InsertQueue.Len()))
+		dropped, drainErr := mt.InsertQueue.Drain(shutdownCtx)
+
+		spool := mt.InsertQueue.Spool()
+		if drainErr != nil && spool != nil {
+			// what the database did not take in time is replayed on the next start
+			spooled, err := mt.InsertQueue.SpoolRemaining()
+			if err != nil {
+				logger.Error(fmt.Sprintf("spooling the queued samples: %v", err))
+			}
+			dropped -= spooled
+		}
+		if drainErr != nil {
+			logger.Error(fmt.Sprintf("insert queue drain: %v, %d samples dropped", drainErr, dropped))
 		}
 
-		logger.Fatal(fmt.Sprintf("REST API server: %v", restApi.Serve(addr, originAllowed)))
+		if spool != nil {
+			if stats := spool.Stats(); stats.Samples > 0 {
+				logger.Info(fmt.Sprintf("%d samples left in the spool, they will be replayed on the next start", stats.Samples))
+			}
+			if err := spool.Close(); err != nil {
+				logger.Error(fmt.Sprintf("closing the spool: %v", err))
+			}
+		}
+
+		if drainErr == nil {
+			logger.Info("shutdown completed")
+		}
 		return nil
 	},
 }
+
+// startPrometheusPoller polls the nodes metrics from Prometheus and queues them for insertion.
+// The receiver can not be stopped, the returned func detaches it from the queue.
+func startPrometheusPoller(logger *zap.Logger, mt *metrics.Metrics) (stop func()) {
+
+	var stopped atomic.Bool
+
+	prom := getPrometheusReceiver(logger)
+	prom.SetOnNewDataCallBack(func(node *receiver.CelestiaNode) {
+
+		if stopped.Load() {
+			logger.Debug(fmt.Sprintf("receiver callback: %s polled after the shutdown started, ignored", node.ID))
+			return
+		}
+
+		err := mt.InsertQueue.Add(&models.CelestiaNode{
+			NodeId:                      node.ID,
+			NodeType:                    node.Type,
+			Version:                     node.Version,
+			LastPfbTimestamp:            node.LastPfbTimestamp,
+			PfbCount:                    node.PfbCount,
+			Head:                        node.Head,
+			NetworkHeight:               node.NetworkHeight,
+			DasLatestSampledTimestamp:   node.DasLatestSampledTimestamp,
+			DasNetworkHead:              node.DasNetworkHead,
+			DasSampledChainHead:         node.DasSampledChainHead,
+			DasSampledHeadersCounter:    node.DasSampledHeadersCounter,
+			DasTotalSampledHeaders:      node.DasTotalSampledHeaders,
+			TotalSyncedHeaders:          node.TotalSyncedHeaders,
+			StartTime:                   node.StartTime,
+			LastRestartTime:             node.LastRestartTime,
+			NodeRuntimeCounterInSeconds: node.NodeRuntimeCounterInSeconds,
+			LastAccumulativeNodeRuntimeCounterInSeconds: node.LastAccumulativeNodeRuntimeCounterInSeconds,
+			Uptime: node.Uptime,
+		})
+		if err != nil {
+			// the queue gets closed on shutdown, so the late callbacks are ignored
+			logger.Debug(fmt.Sprintf("receiver callback: %v", err))
+			return
+		}
+		telemetry.MarkSync()
+
+	})
+	// logger.Info(fmt.Sprintf("%d data points stored in db", len(data)))
+
+	// The tendermint receiver is needed to get the network height
+	// as we do not collect data about validators, it does not need to be initiated
+	tm := getTendermintReceiver(logger)
+
+	re := receiver.New(prom, nil, tm, logger)
+	// Only prometheus receiver service need to be initiated
+	re.InitPrometheus()
+
+	return func() { stopped.Store(true) }
+}
+
+// startAlerting evaluates the alert rules on the written samples and posts the alert events to the webhook
+func startAlerting(ctx context.Context, logger *zap.Logger, mt *metrics.Metrics) (*alerting.Engine, error) {
+
+	var notifier alerting.Notifier
+	if cfg.Alerting.WebhookURL != "" {
+		webhook := alerting.NewWebhook(cfg.Alerting.WebhookURL, logger)
+		go webhook.Run(ctx)
+		notifier = webhook
+	}
+
+	engine, err := alerting.NewEngine(mt, cfg.Alerting.Rules, notifier, logger)
+	if err != nil {
+		return nil, err
+	}
+	engine.SetNodeTTL(cfg.Alerting.NodeTTL)
+	if err := engine.Restore(); err != nil {
+		logger.Error(fmt.Sprintf("restoring the alerts: %v", err))
+	}
+	telemetry.RegisterGaugeFunc("alerts_firing", "Alerts currently firing.", func() float64 {
+		return float64(len(engine.Active()))
+	})
+
+	go engine.Run(ctx, cfg.Alerting.EvaluationInterval)
+	logger.Info(fmt.Sprintf("alerting on %d rules", len(cfg.Alerting.Rules)))
+
+	return engine, nil
+}
+
+// registerInsertQueueMetrics exposes the insert queue stats on /metrics
+func registerInsertQueueMetrics(queue *metrics.InsertQueue) {
+
+	telemetry.RegisterGaugeFunc("insert_queue_depth", "Samples waiting to be written into the database.", func() float64 {
+		return float64(queue.Len())
+	})
+	telemetry.RegisterCounterFunc("insert_failed_rows_total", "Samples which could neither be written into the database nor spooled.", func() float64 {
+		return float64(queue.Stats().FailedRows)
+	})
+	telemetry.RegisterCounterFunc("insert_spooled_rows_total", "Samples spooled to the disk while the database was not reachable.", func() float64 {
+		return float64(queue.Stats().SpooledRows)
+	})
+}
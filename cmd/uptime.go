@@ -34,6 +34,10 @@ var uptimeRecomputeCmd = &cobra.Command{
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
+		if err := cfg.ValidateUptimeWindow(); err != nil {
+			return err
+		}
+
 		logger, err := getLogger()
 		if err != nil {
 			panic(err)
@@ -42,16 +46,18 @@ var uptimeRecomputeCmd = &cobra.Command{
 
 		/*------*/
 
-		db := getDatabase(logger)
+		store := getStorage(logger)
 
 		/*------*/
 
-		mt := metrics.New(db)
+		mt := metrics.NewWithStorage(store)
+		mt.SetUptimeScorer(getUptimeScorer())
+		mt.SetHeartbeatGapThreshold(cfg.HeartbeatGapThreshold())
 
-		uptimeStartTime := getUptimeStartTime(logger)
-		uptimeEndTime := getUptimeEndTime(logger)
+		uptimeStartTime := cfg.Uptime.StartTime
+		uptimeEndTime := cfg.Uptime.EndTime
 
-		fmt.Printf("Computing uptime for all nodes...\n")
+		fmt.Printf("Computing uptime for all nodes with the %q scorer...\n", mt.UptimeScorer().Name())
 		nodesList, err := mt.RecomputeUptimeForAll(uptimeStartTime, uptimeEndTime)
 		if err != nil {
 			return err
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/alerting"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config is the whole nodelogger configuration. The values are read from the config file,
+// overridden by the environment variables, overridden by the command line flags.
+type Config struct {
+	LogLevel        string        `mapstructure:"log_level" yaml:"log_level"`
+	ProductionMode  bool          `mapstructure:"production_mode" yaml:"production_mode"`
+	Demo            bool          `mapstructure:"demo" yaml:"demo"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout" yaml:"shutdown_timeout"`
+
+	Prometheus  PrometheusConfig  `mapstructure:"prometheus" yaml:"prometheus"`
+	Tendermint  TendermintConfig  `mapstructure:"tendermint" yaml:"tendermint"`
+	API         APIConfig         `mapstructure:"api" yaml:"api"`
+	Database    DatabaseConfig    `mapstructure:"database" yaml:"database"`
+	InsertQueue InsertQueueConfig `mapstructure:"insert_queue" yaml:"insert_queue"`
+	Uptime      UptimeConfig      `mapstructure:"uptime" yaml:"uptime"`
+	Workers     WorkersConfig     `mapstructure:"workers" yaml:"workers"`
+	Retention   RetentionConfig   `mapstructure:"retention" yaml:"retention"`
+	Ingest      IngestConfig      `mapstructure:"ingest" yaml:"ingest"`
+	Ready       ReadyConfig       `mapstructure:"ready" yaml:"ready"`
+	Alerting    AlertingConfig    `mapstructure:"alerting" yaml:"alerting"`
+	Versions    VersionsConfig    `mapstructure:"versions" yaml:"versions"`
+}
+
+type PrometheusConfig struct {
+	URL             string `mapstructure:"url" yaml:"url"`
+	NamespacePrefix string `mapstructure:"namespace_prefix" yaml:"namespace_prefix"`
+	SyncInterval    uint64 `mapstructure:"sync_interval" yaml:"sync_interval"` // seconds
+}
+
+type TendermintConfig struct {
+	RPC string `mapstructure:"rpc" yaml:"rpc"`
+}
+
+type APIConfig struct {
+	Address        string `mapstructure:"address" yaml:"address"`
+	OriginAllowed  string `mapstructure:"origin_allowed" yaml:"origin_allowed"`
+	RowsPerPage    uint64 `mapstructure:"rows_per_page" yaml:"rows_per_page"`
+	MaxRowsPerPage uint64 `mapstructure:"max_rows_per_page" yaml:"max_rows_per_page"`
+	UIPath         string `mapstructure:"ui_path" yaml:"ui_path"`
+}
+
+type DatabaseConfig struct {
+	Driver     string         `mapstructure:"driver" yaml:"driver"`
+	SQLitePath string         `mapstructure:"sqlite_path" yaml:"sqlite_path"`
+	Postgres   PostgresConfig `mapstructure:"postgres" yaml:"postgres"`
+}
+
+type PostgresConfig struct {
+	Host     string `mapstructure:"host" yaml:"host"`
+	Port     uint16 `mapstructure:"port" yaml:"port"`
+	User     string `mapstructure:"user" yaml:"user"`
+	Password string `mapstructure:"password" yaml:"password"`
+	DB       string `mapstructure:"db" yaml:"db"`
+}
+
+type InsertQueueConfig struct {
+	BatchSize     int           `mapstructure:"batch_size" yaml:"batch_size"`
+	FlushInterval time.Duration `mapstructure:"flush_interval" yaml:"flush_interval"`
+	SpoolDir      string        `mapstructure:"spool_dir" yaml:"spool_dir"`
+}
+
+type UptimeConfig struct {
+	StartTime             time.Time     `mapstructure:"start_time" yaml:"start_time"`
+	EndTime               time.Time     `mapstructure:"end_time" yaml:"end_time"`
+	Scorer                string        `mapstructure:"scorer" yaml:"scorer"`
+	HeartbeatGapThreshold time.Duration `mapstructure:"heartbeat_gap_threshold" yaml:"heartbeat_gap_threshold"`
+	HeartbeatGapTolerance time.Duration `mapstructure:"heartbeat_gap_tolerance" yaml:"heartbeat_gap_tolerance"`
+}
+
+type WorkersConfig struct {
+	RuntimeCheckpointInterval    time.Duration `mapstructure:"runtime_checkpoint_interval" yaml:"runtime_checkpoint_interval"`
+	RollupInterval               time.Duration `mapstructure:"rollup_interval" yaml:"rollup_interval"`
+	PartitionMaintenanceInterval time.Duration `mapstructure:"partition_maintenance_interval" yaml:"partition_maintenance_interval"`
+}
+
+type RetentionConfig struct {
+	Days int    `mapstructure:"days" yaml:"days"`
+	Mode string `mapstructure:"mode" yaml:"mode"` // drop or detach
+}
+
+type IngestConfig struct {
+	RemoteWriteEnabled bool          `mapstructure:"remote_write_enabled" yaml:"remote_write_enabled"`
+	OTLPEnabled        bool          `mapstructure:"otlp_enabled" yaml:"otlp_enabled"`
+	Token              string        `mapstructure:"token" yaml:"token"`
+	MergeWindow        time.Duration `mapstructure:"merge_window" yaml:"merge_window"`
+}
+
+type ReadyConfig struct {
+	MaxQueueDepth int64         `mapstructure:"max_queue_depth" yaml:"max_queue_depth"`
+	MaxDataAge    time.Duration `mapstructure:"max_data_age" yaml:"max_data_age"`
+}
+
+type VersionsConfig struct {
+	MinVersion string `mapstructure:"min_version" yaml:"min_version"`
+}
+
+// AlertingConfig sets the alert rules, they can only be set in the config file
+type AlertingConfig struct {
+	WebhookURL         string          `mapstructure:"webhook_url" yaml:"webhook_url"`
+	EvaluationInterval time.Duration   `mapstructure:"evaluation_interval" yaml:"evaluation_interval"`
+	NodeTTL            time.Duration   `mapstructure:"node_ttl" yaml:"node_ttl"`
+	Rules              []alerting.Rule `mapstructure:"rules" yaml:"rules"`
+}
+
+// PushEnabled tells if the node metrics can be pushed to the API
+func (c *Config) PushEnabled() bool {
+	return c.Ingest.RemoteWriteEnabled || c.Ingest.OTLPEnabled
+}
+
+// PollingEnabled tells if the node metrics are polled from Prometheus,
+// which is only turned off when they are pushed and no Prometheus URL is set
+func (c *Config) PollingEnabled() bool {
+	return c.Prometheus.URL != "" || c.Demo || !c.PushEnabled()
+}
+
+// HeartbeatGapThreshold returns how long a node may stay silent before it is considered down.
+// It is either set explicitly, or derived from the Prometheus sync interval plus a tolerance.
+func (c *Config) HeartbeatGapThreshold() time.Duration {
+	if c.Uptime.HeartbeatGapThreshold > 0 {
+		return c.Uptime.HeartbeatGapThreshold
+	}
+	return time.Duration(c.Prometheus.SyncInterval)*time.Second + c.Uptime.HeartbeatGapTolerance
+}
+
+func (c *Config) PostgresConnStr() string {
+	p := c.Database.Postgres
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		p.Host, p.Port, p.User, p.Password, p.DB)
+}
+
+/*------*/
+
+// FileEnv is the environment variable the config file path is read from when the flag is not set
+const FileEnv = "NODELOGGER_CONFIG"
+
+// RegisterFlags adds a flag for every config key, named after the key, e.g. --database.postgres.host
+func RegisterFlags(flags *pflag.FlagSet) {
+	flags.String("config", "", fmt.Sprintf("config file, YAML or TOML (env: %s)", FileEnv))
+	for _, o := range options {
+		flags.String(o.key, "", fmt.Sprintf("%s (env: %s)", o.usage, o.env))
+	}
+}
+
+// Load reads the config file, the environment and the flags set.
+// The defaults are the default values of the keys owned by the other packages, e.g. the API page size,
+// they are passed in so this package does not depend on them.
+func Load(flags *pflag.FlagSet, defaults map[string]interface{}) (*Config, error) {
+
+	v := viper.New()
+
+	for key, def := range defaults {
+		if !isOption(key) {
+			return nil, fmt.Errorf("default of the unknown config key %q", key)
+		}
+		v.SetDefault(key, def)
+	}
+
+	for _, o := range options {
+		if o.def != nil {
+			v.SetDefault(o.key, o.def)
+		}
+		if err := v.BindEnv(o.key, o.env); err != nil {
+			return nil, err
+		}
+		if flags != nil {
+			if f := flags.Lookup(o.key); f != nil {
+				if err := v.BindPFlag(o.key, f); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	file := os.Getenv(FileEnv)
+	if flags != nil {
+		if f := flags.Lookup("config"); f != nil && f.Changed {
+			file = f.Value.String()
+		}
+	}
+	if file != "" {
+		v.SetConfigFile(file)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("reading the config file: %v", err)
+		}
+	}
+
+	var c Config
+	err := v.Unmarshal(&c, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		emptyStringToZeroHookFunc(),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding the config: %v", err)
+	}
+
+	return &c, nil
+}
+
+// emptyStringToZeroHookFunc decodes an empty string as the zero value, e.g. the unset flags
+func emptyStringToZeroHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if s, ok := data.(string); ok && s == "" {
+			return reflect.Zero(to).Interface(), nil
+		}
+		return data, nil
+	}
+}
+
+// Redacted returns a copy of the config with the secrets hidden, for printing
+func (c Config) Redacted() Config {
+	hide := func(s *string) {
+		if *s != "" {
+			*s = "<redacted>"
+		}
+	}
+	hide(&c.Database.Postgres.Password)
+	hide(&c.Ingest.Token)
+	hide(&c.Alerting.WebhookURL) // the chat webhooks carry their token in the URL
+	return c
+}
+
+func isOption(key string) bool {
+	for _, o := range options {
+		if o.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// envOf returns the environment variable of a key, for the error messages
+func envOf(key string) string {
+	for _, o := range options {
+		if o.key == key {
+			return o.env
+		}
+	}
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// testDefaults stand for the defaults the command passes to Load
+var testDefaults = map[string]interface{}{
+	"api.rows_per_page":                      100,
+	"api.max_rows_per_page":                  1000,
+	"insert_queue.batch_size":                500,
+	"insert_queue.flush_interval":            time.Second,
+	"workers.runtime_checkpoint_interval":    30 * time.Second,
+	"workers.rollup_interval":                5 * time.Minute,
+	"workers.partition_maintenance_interval": time.Hour,
+	"ingest.merge_window":                    5 * time.Second,
+	"ready.max_queue_depth":                  10000,
+	"ready.max_data_age":                     5 * time.Minute,
+	"alerting.evaluation_interval":           30 * time.Second,
+	"alerting.node_ttl":                      24 * time.Hour,
+}
+
+// clearEnv unsets the environment variables of the config for the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	for _, o := range append(options, option{env: FileEnv}) {
+		if value, ok := os.LookupEnv(o.env); ok {
+			t.Setenv(o.env, value) // restored after the test
+			os.Unsetenv(o.env)
+		}
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func loadWithFlags(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return Load(flags, testDefaults)
+}
+
+// The values are read from the defaults, overridden by the file, by the environment and by the flags
+func TestLoadPrecedence(t *testing.T) {
+
+	clearEnv(t)
+
+	file := writeConfigFile(t, "nodelogger.yaml", `
+log_level: warn
+shutdown_timeout: 10s
+api:
+  rows_per_page: 10
+database:
+  postgres:
+    host: file-host
+    user: file-user
+insert_queue:
+  batch_size: 50
+uptime:
+  start_time: 2024-01-01T00:00:00Z
+`)
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("POSTGRES_HOST", "env-host")
+	t.Setenv("INSERT_FLUSH_INTERVAL", "250ms")
+
+	c, err := loadWithFlags(t, "--config", file, "--database.postgres.host", "flag-host", "--retention.days=30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key       string
+		got, want interface{}
+	}{
+		{"retention.mode, the option default", c.Retention.Mode, "drop"},
+		{"api.max_rows_per_page, a default passed in", c.API.MaxRowsPerPage, uint64(1000)},
+		{"api.rows_per_page, the file over a default passed in", c.API.RowsPerPage, uint64(10)},
+		{"shutdown_timeout, the file over the option default", c.ShutdownTimeout, 10 * time.Second},
+		{"database.postgres.user, the file", c.Database.Postgres.User, "file-user"},
+		{"insert_queue.batch_size, the file", c.InsertQueue.BatchSize, 50},
+		{"uptime.start_time, a time in the file", c.Uptime.StartTime, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"log_level, the environment over the file", c.LogLevel, "error"},
+		{"insert_queue.flush_interval, a duration in the environment", c.InsertQueue.FlushInterval, 250 * time.Millisecond},
+		{"database.postgres.host, the flag over the environment and the file", c.Database.Postgres.Host, "flag-host"},
+		{"retention.days, the flag", c.Retention.Days, 30},
+		{"database.postgres.port, not overridden by the unset flag", c.Database.Postgres.Port, uint16(5432)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: %#v, want %#v", tt.key, tt.got, tt.want)
+		}
+	}
+}
+
+// Without --config, the file is read from the path in NODELOGGER_CONFIG
+func TestLoadFileFromEnv(t *testing.T) {
+
+	clearEnv(t)
+	t.Setenv(FileEnv, writeConfigFile(t, "nodelogger.toml", "log_level = \"debug\"\n"))
+
+	c, err := loadWithFlags(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogLevel != "debug" {
+		t.Errorf("log_level %q, want the one of the file", c.LogLevel)
+	}
+
+	if _, err := loadWithFlags(t, "--config", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("a missing config file was not reported")
+	}
+}
+
+func TestLoadUnknownDefault(t *testing.T) {
+
+	clearEnv(t)
+	if _, err := Load(nil, map[string]interface{}{"api.rows_per_pages": 10}); err == nil {
+		t.Error("the default of an unknown key was accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	clearEnv(t)
+
+	valid := func(t *testing.T) *Config {
+		c, err := Load(nil, testDefaults)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Prometheus.SyncInterval = 60
+		return c
+	}
+	if err := valid(t).Validate(); err != nil {
+		t.Fatalf("the defaults are not valid: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string // in the error
+	}{
+		{name: "log level", change: func(c *Config) { c.LogLevel = "loud" }, want: []string{"`log_level` (LOG_LEVEL)"}},
+		{name: "page sizes", change: func(c *Config) { c.API.MaxRowsPerPage = 5 }, want: []string{"`api.max_rows_per_page` (API_MAX_ROWS_PER_PAGE)"}},
+		{name: "driver", change: func(c *Config) { c.Database.Driver = "mysql" }, want: []string{"`database.driver`", `"mysql"`}},
+		{name: "sqlite path", change: func(c *Config) { c.Database.Driver, c.Database.SQLitePath = "sqlite", "" }, want: []string{"`database.sqlite_path` (SQLITE_PATH): is required"}},
+		{name: "batch size", change: func(c *Config) { c.InsertQueue.BatchSize = 0 }, want: []string{"`insert_queue.batch_size` (INSERT_BATCH_SIZE): must be positive"}},
+		{name: "scorer", change: func(c *Config) { c.Uptime.Scorer = "best" }, want: []string{"`uptime.scorer` (UPTIME_SCORER)"}},
+		{name: "uptime window", change: func(c *Config) {
+			c.Uptime.StartTime = time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+			c.Uptime.EndTime = c.Uptime.StartTime.Add(-time.Hour)
+		}, want: []string{"`uptime.end_time`"}},
+		{name: "heartbeat", change: func(c *Config) { c.Prometheus.SyncInterval, c.Uptime.HeartbeatGapTolerance = 0, 0 }, want: []string{"`uptime.heartbeat_gap_threshold`"}},
+		{name: "retention", change: func(c *Config) { c.Retention.Days, c.Retention.Mode = -1, "archive" }, want: []string{"`retention.days`", "`retention.mode`"}},
+		{name: "min version", change: func(c *Config) { c.Versions.MinVersion = "latest" }, want: []string{"`versions.min_version` (MIN_NODE_VERSION)"}},
+		{name: "webhook", change: func(c *Config) { c.Alerting.WebhookURL = "ftp://example.com" }, want: []string{"`alerting.webhook_url`"}},
+		{name: "every problem at once", change: func(c *Config) { c.ShutdownTimeout, c.Ingest.MergeWindow, c.Ready.MaxQueueDepth = 0, 0, 0 },
+			want: []string{"`shutdown_timeout`", "`ingest.merge_window`", "`ready.max_queue_depth`"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			c := valid(t)
+			tt.change(c)
+
+			err := c.Validate()
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("error %v, want a ValidationError", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("%q is not in the error:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateStart(t *testing.T) {
+
+	clearEnv(t)
+
+	c, err := Load(nil, testDefaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// polling Prometheus by default
+	err = c.ValidateStart()
+	for _, key := range []string{"api.address", "api.origin_allowed", "prometheus.url", "tendermint.rpc", "uptime.start_time"} {
+		if err == nil || !strings.Contains(err.Error(), "`"+key+"`") {
+			t.Errorf("%s is not reported as missing: %v", key, err)
+		}
+	}
+
+	// only pushed, a token is needed but no Prometheus
+	c.API.Address, c.API.OriginAllowed = ":5050", "*"
+	c.Ingest.RemoteWriteEnabled = true
+	if err := c.ValidateStart(); err == nil || !strings.Contains(err.Error(), "`ingest.token`") || strings.Contains(err.Error(), "prometheus") {
+		t.Errorf("error %v, want only the missing ingest token", err)
+	}
+	c.Ingest.Token = "secret"
+	if err := c.ValidateStart(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+
+	var c Config
+	c.Database.Postgres.User = "nodelogger"
+	c.Database.Postgres.Password = "hunter2"
+	c.Ingest.Token = "secret"
+	c.Alerting.WebhookURL = "https://hooks.example.com/services/T000/B000/XXXX"
+
+	r := c.Redacted()
+	if r.Database.Postgres.Password != "<redacted>" || r.Ingest.Token != "<redacted>" || r.Alerting.WebhookURL != "<redacted>" {
+		t.Errorf("secrets left in %+v", r)
+	}
+	if r.Database.Postgres.User != "nodelogger" {
+		t.Errorf("user %q, want it kept", r.Database.Postgres.User)
+	}
+	if c.Database.Postgres.Password != "hunter2" || c.Ingest.Token != "secret" {
+		t.Error("the original config was changed")
+	}
+
+	// an unset secret stays empty, so it does not look set
+	if r := (Config{}).Redacted(); r.Ingest.Token != "" || r.Database.Postgres.Password != "" || r.Alerting.WebhookURL != "" {
+		t.Errorf("unset secrets shown as redacted: %+v", r)
+	}
+}
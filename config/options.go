@@ -0,0 +1,71 @@
+package config
+
+import (
+	"time"
+)
+
+// option is a config key with the environment variable and the flag it can be set with
+type option struct {
+	key   string // also the flag name
+	env   string
+	def   interface{} // nil means no default, or a default passed to Load by the package owning the value
+	usage string
+}
+
+var options = []option{
+	{"log_level", "LOG_LEVEL", "info", "debug, info, warn, error, panic or fatal"},
+	{"production_mode", "PRODUCTION_MODE", false, "production logging"},
+	{"demo", "DEMO", false, "demo mode, with fake node metrics"},
+	{"shutdown_timeout", "SHUTDOWN_TIMEOUT", 30 * time.Second, "how long to wait for the queued samples to be written on shutdown"},
+
+	{"prometheus.url", "PROMETHEUS_URL", nil, "Prometheus the node metrics are polled from"},
+	{"prometheus.namespace_prefix", "PROMETHEUS_NAMESPACE_PREFIX", "", "prefix of the node metric names"},
+	{"prometheus.sync_interval", "PROMETHEUS_SYNC_INTERVAL", 60, "seconds between two polls of Prometheus"},
+
+	{"tendermint.rpc", "APP_TM_RPC", nil, "tendermint RPC the network height is read from"},
+
+	{"api.address", "REST_API_ADDRESS", nil, "address the REST API listens on"},
+	{"api.origin_allowed", "ORIGIN_ALLOWED", nil, "origin allowed by CORS"},
+	{"api.rows_per_page", "API_ROWS_PER_PAGE", nil, "rows per page of the paginated endpoints"},
+	{"api.max_rows_per_page", "API_MAX_ROWS_PER_PAGE", nil, "largest page the clients can ask for with ?limit="},
+	{"api.ui_path", "EXEC_PATH", nil, "directory the UI files are served from"},
+
+	{"database.driver", "DATABASE_DRIVER", "postgres", "postgres or sqlite"},
+	{"database.sqlite_path", "SQLITE_PATH", "nodelogger.db", "database file of the embedded SQLite backend"},
+	{"database.postgres.host", "POSTGRES_HOST", "localhost", "Postgres host"},
+	{"database.postgres.port", "POSTGRES_PORT", 5432, "Postgres port"},
+	{"database.postgres.user", "POSTGRES_USER", nil, "Postgres user"},
+	{"database.postgres.password", "POSTGRES_PASSWORD", nil, "Postgres password"},
+	{"database.postgres.db", "POSTGRES_DB", nil, "Postgres database"},
+
+	{"insert_queue.batch_size", "INSERT_BATCH_SIZE", nil, "max number of rows written to the database in one transaction"},
+	{"insert_queue.flush_interval", "INSERT_FLUSH_INTERVAL", nil, "max time a sample waits in the queue before its batch is written"},
+	{"insert_queue.spool_dir", "SPOOL_DIR", "spool", "where the samples which cannot be written to the database are kept"},
+
+	{"uptime.start_time", "UPTIME_START_TIME", nil, "RFC3339, beginning of the period the uptime is computed for"},
+	{"uptime.end_time", "UPTIME_END_TIME", nil, "RFC3339, end of the period the uptime is computed for"},
+	{"uptime.scorer", "UPTIME_SCORER", "", "min, time, sync, weighted:<sync weight> or per-node-type:<type>=<scorer>,..."},
+	{"uptime.heartbeat_gap_threshold", "HEARTBEAT_GAP_THRESHOLD", nil, "a node silent for longer than this is considered down, derived from the sync interval if not set"},
+	{"uptime.heartbeat_gap_tolerance", "HEARTBEAT_GAP_TOLERANCE", 40 * time.Second, "added to the sync interval to get the heartbeat gap threshold"},
+
+	{"workers.runtime_checkpoint_interval", "RUNTIME_CHECKPOINT_INTERVAL", nil, "how often the runtime checkpoints are brought up to date"},
+	{"workers.rollup_interval", "ROLLUP_INTERVAL", nil, "how often the rollups are brought up to date"},
+	{"workers.partition_maintenance_interval", "PARTITION_MAINTENANCE_INTERVAL", nil, "how often the partitions are created and the retention policy is applied"},
+
+	{"retention.days", "RETENTION_DAYS", 0, "partitions older than this are removed, 0 keeps everything"},
+	{"retention.mode", "RETENTION_MODE", "drop", "drop or detach"},
+
+	{"ingest.remote_write_enabled", "REMOTE_WRITE_ENABLED", false, "accept Prometheus remote-write pushes"},
+	{"ingest.otlp_enabled", "OTLP_ENABLED", false, "accept OTLP/HTTP metrics exports"},
+	{"ingest.token", "INGEST_TOKEN", nil, "bearer token the pushers must send, required to accept pushes"},
+	{"ingest.merge_window", "INGEST_MERGE_WINDOW", nil, "how long the pushed series of a node are merged into one sample"},
+
+	{"ready.max_queue_depth", "READY_MAX_QUEUE_DEPTH", nil, "/readyz fails while more samples wait in the insert queue"},
+	{"ready.max_data_age", "READY_MAX_DATA_AGE", nil, "/readyz fails when the newest sample is older, 0 turns the check off"},
+
+	{"alerting.webhook_url", "ALERT_WEBHOOK_URL", nil, "URL the alert events are posted to, the rules are set in the config file"},
+	{"alerting.evaluation_interval", "ALERT_EVALUATION_INTERVAL", nil, "how often the silent nodes and the pending alerts are checked"},
+	{"alerting.node_ttl", "ALERT_NODE_TTL", nil, "the nodes silent for longer than this are forgotten and their alerts resolved"},
+
+	{"versions.min_version", "MIN_NODE_VERSION", nil, "the nodes running an older version are listed as outdated"},
+}
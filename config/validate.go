@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/celestiaorg/nodelogger/alerting"
+	"github.com/celestiaorg/nodelogger/database/metrics"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"go.uber.org/zap"
+)
+
+// ValidationError lists all the problems found in a config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf("`%s` (%s): %s", key, envOf(key), fmt.Sprintf(format, args...)))
+	}
+}
+
+// checkFile is check for the keys which can only be set in the config file
+func (v *validator) checkFile(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf("`%s` (config file): %s", key, fmt.Sprintf(format, args...)))
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// Validate checks the values are well formed, whatever the command
+func (c *Config) Validate() error {
+
+	v := &validator{}
+
+	_, err := zap.ParseAtomicLevel(c.LogLevel)
+	v.check(err == nil, "log_level", "%v", err)
+	v.check(c.ShutdownTimeout > 0, "shutdown_timeout", "must be positive")
+
+	v.check(c.API.RowsPerPage > 0, "api.rows_per_page", "must be positive")
+	v.check(c.API.MaxRowsPerPage >= c.API.RowsPerPage, "api.max_rows_per_page", "must be at least api.rows_per_page")
+
+	switch storage.Dialect(c.Database.Driver) {
+	case storage.DialectPostgres:
+		v.check(c.Database.Postgres.Host != "", "database.postgres.host", "is required")
+	case storage.DialectSQLite:
+		v.check(c.Database.SQLitePath != "", "database.sqlite_path", "is required")
+	default:
+		v.check(false, "database.driver", "unknown driver %q, expected postgres or sqlite", c.Database.Driver)
+	}
+
+	v.check(c.InsertQueue.BatchSize > 0, "insert_queue.batch_size", "must be positive")
+	v.check(c.InsertQueue.FlushInterval > 0, "insert_queue.flush_interval", "must be positive")
+	v.check(c.InsertQueue.SpoolDir != "", "insert_queue.spool_dir", "is required")
+
+	_, err = metrics.ParseUptimeScorer(c.Uptime.Scorer)
+	v.check(err == nil, "uptime.scorer", "%v", err)
+	v.check(c.Uptime.StartTime.IsZero() || c.Uptime.EndTime.IsZero() || c.Uptime.StartTime.Before(c.Uptime.EndTime),
+		"uptime.end_time", "must be after the start time")
+	v.check(c.Uptime.HeartbeatGapThreshold >= 0, "uptime.heartbeat_gap_threshold", "must not be negative")
+	v.check(c.Uptime.HeartbeatGapTolerance >= 0, "uptime.heartbeat_gap_tolerance", "must not be negative")
+	v.check(c.HeartbeatGapThreshold() > 0, "uptime.heartbeat_gap_threshold", "must be positive, set it or the sync interval")
+
+	v.check(c.Workers.RuntimeCheckpointInterval > 0, "workers.runtime_checkpoint_interval", "must be positive")
+	v.check(c.Workers.RollupInterval > 0, "workers.rollup_interval", "must be positive")
+	v.check(c.Workers.PartitionMaintenanceInterval > 0, "workers.partition_maintenance_interval", "must be positive")
+
+	v.check(c.Retention.Days >= 0, "retention.days", "must not be negative")
+	v.check(c.Retention.Mode == "drop" || c.Retention.Mode == "detach", "retention.mode", "unknown mode %q, expected drop or detach", c.Retention.Mode)
+
+	v.check(c.Ingest.MergeWindow > 0, "ingest.merge_window", "must be positive")
+
+	v.check(c.Ready.MaxQueueDepth > 0, "ready.max_queue_depth", "must be positive")
+	v.check(c.Ready.MaxDataAge >= 0, "ready.max_data_age", "must not be negative")
+
+	if c.Versions.MinVersion != "" {
+		_, err = metrics.ParseVersion(c.Versions.MinVersion)
+		v.check(err == nil, "versions.min_version", "%v", err)
+	}
+
+	v.check(c.Alerting.EvaluationInterval > 0, "alerting.evaluation_interval", "must be positive")
+	v.check(c.Alerting.NodeTTL > 0, "alerting.node_ttl", "must be positive")
+	if c.Alerting.WebhookURL != "" {
+		u, err := url.Parse(c.Alerting.WebhookURL)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "alerting.webhook_url", "must be an http or https URL")
+	}
+	for _, problem := range alerting.ValidateRules(c.Alerting.Rules) {
+		v.checkFile(false, "alerting.rules", "%s", problem)
+	}
+
+	return v.err()
+}
+
+// ValidateStart checks the values the start command needs are set
+func (c *Config) ValidateStart() error {
+
+	v := &validator{}
+
+	v.check(c.API.Address != "", "api.address", "is required")
+	v.check(c.API.OriginAllowed != "", "api.origin_allowed", "is required")
+
+	if c.PollingEnabled() && !c.Demo {
+		v.check(c.Prometheus.URL != "", "prometheus.url", "is required, unless the metrics are pushed")
+		v.check(c.Prometheus.SyncInterval > 0, "prometheus.sync_interval", "must be positive")
+		v.check(c.Tendermint.RPC != "", "tendermint.rpc", "is required to poll Prometheus")
+		v.check(!c.Uptime.StartTime.IsZero(), "uptime.start_time", "is required to poll Prometheus")
+	}
+
+	v.check(!c.PushEnabled() || c.Ingest.Token != "", "ingest.token", "is required when the metrics can be pushed")
+
+	return v.err()
+}
+
+// ValidateUptimeWindow checks both ends of the uptime window are set
+func (c *Config) ValidateUptimeWindow() error {
+
+	v := &validator{}
+
+	v.check(!c.Uptime.StartTime.IsZero(), "uptime.start_time", "is required")
+	v.check(!c.Uptime.EndTime.IsZero(), "uptime.end_time", "is required")
+
+	return v.err()
+}
@@ -5,14 +5,30 @@ import (
 	"os"
 	"time"
 
-	"github.com/celestiaorg/nodelogger/database/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// Init opens the database and applies the pending migrations
 func Init(connStr string) (*gorm.DB, error) {
 
+	db, err := Open(connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := MigrateUp(db, 0)
+	for _, m := range applied {
+		log.Printf("migration %d_%s applied\n", m.Version, m.Name)
+	}
+
+	return db, err
+}
+
+// Open opens the database without touching the schema
+func Open(connStr string) (*gorm.DB, error) {
+
 	newLogger := logger.New(
 		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
 		logger.Config{
@@ -28,7 +44,6 @@ func Init(connStr string) (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	err = db.AutoMigrate(&models.CelestiaNode{})
 
-	return db, err
+	return db, nil
 }
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// The no transaction migrations building or dropping an index concurrently are run through
+// createIndexConcurrently and dropIndexConcurrently, so they also work on a partitioned table
+// and never leave an invalid index behind recorded as applied
+var (
+	createIndexRe = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+CONCURRENTLY\s+(?:IF\s+NOT\s+EXISTS\s+)?"(\w+)"\s+ON\s+"(\w+)"\s*(\(.*\))$`)
+	dropIndexRe   = regexp.MustCompile(`(?is)^DROP\s+INDEX\s+CONCURRENTLY\s+(?:IF\s+EXISTS\s+)?"(\w+)"$`)
+)
+
+type concurrentIndex struct {
+	unique  bool
+	name    string
+	table   string
+	columns string // the parenthesized column list, with what follows it
+}
+
+type indexState struct {
+	Exists      bool
+	Valid       bool
+	Partitioned bool // the index of a partitioned table
+}
+
+// runMigrationStatement runs one statement of a no transaction migration
+func runMigrationStatement(db *gorm.DB, stmt string) error {
+
+	stmt = strings.TrimSuffix(strings.TrimSpace(stripSQLComments(stmt)), ";")
+
+	if m := createIndexRe.FindStringSubmatch(stmt); m != nil {
+		return createIndexConcurrently(db, concurrentIndex{unique: m[1] != "", name: m[2], table: m[3], columns: m[4]})
+	}
+	if m := dropIndexRe.FindStringSubmatch(stmt); m != nil {
+		return dropIndexConcurrently(db, m[1])
+	}
+	return db.Exec(stmt).Error
+}
+
+func getIndexState(db *gorm.DB, name string) (indexState, error) {
+
+	var rows []indexState
+
+	SQL := `
+		SELECT TRUE AS "exists", i."indisvalid" AS "valid", c."relkind" = 'I' AS "partitioned"
+		FROM "pg_class" c
+		JOIN "pg_index" i ON i."indexrelid" = c."oid"
+		WHERE c."oid" = TO_REGCLASS(?)`
+	if err := Query(db, SQL, &rows, name); err != nil {
+		return indexState{}, err
+	}
+	if len(rows) == 0 {
+		return indexState{}, nil
+	}
+	return rows[0], nil
+}
+
+// createIndexConcurrently builds the index without blocking the writes.
+// Postgres refuses CONCURRENTLY on a partitioned table, there the index is created on the parent only,
+// built concurrently on every partition and the partition indexes are attached to it,
+// the parent index becomes valid once all of them are attached.
+func createIndexConcurrently(db *gorm.DB, idx concurrentIndex) error {
+
+	partitioned, err := IsPartitioned(db, idx.table)
+	if err != nil {
+		return err
+	}
+	if !partitioned {
+		return buildIndexConcurrently(db, idx)
+	}
+
+	unique := ""
+	if idx.unique {
+		unique = "UNIQUE "
+	}
+	SQL := fmt.Sprintf(`CREATE %sINDEX IF NOT EXISTS %q ON ONLY %q %s`, unique, idx.name, idx.table, idx.columns)
+	if err := db.Exec(SQL).Error; err != nil {
+		return err
+	}
+
+	partitions, err := listPartitionTables(db, idx.table)
+	if err != nil {
+		return err
+	}
+	for _, p := range partitions {
+		child := idx
+		child.table = p
+		child.name = partitionIndexName(idx, p)
+		if err := buildIndexConcurrently(db, child); err != nil {
+			return err
+		}
+
+		var attached []struct{ Attached bool }
+		SQL := `SELECT EXISTS (SELECT 1 FROM "pg_inherits" WHERE "inhrelid" = TO_REGCLASS(?)) AS "attached"`
+		if err := Query(db, SQL, &attached, child.name); err != nil {
+			return err
+		}
+		if len(attached) > 0 && attached[0].Attached {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf(`ALTER INDEX %q ATTACH PARTITION %q`, idx.name, child.name)).Error; err != nil {
+			return err
+		}
+	}
+
+	return checkIndexValid(db, idx.name)
+}
+
+// buildIndexConcurrently builds the index of a plain table or a partition.
+// A valid index with the same name is kept, an invalid one is the leftover of an interrupted build and is built again.
+func buildIndexConcurrently(db *gorm.DB, idx concurrentIndex) error {
+
+	state, err := getIndexState(db, idx.name)
+	if err != nil {
+		return err
+	}
+	if state.Exists && state.Valid {
+		return nil
+	}
+	if state.Exists {
+		log.Printf("index %q is invalid, an earlier build of it was interrupted, building it again\n", idx.name)
+		if err := db.Exec(fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %q`, idx.name)).Error; err != nil {
+			return err
+		}
+	}
+
+	unique := ""
+	if idx.unique {
+		unique = "UNIQUE "
+	}
+	SQL := fmt.Sprintf(`CREATE %sINDEX CONCURRENTLY %q ON %q %s`, unique, idx.name, idx.table, idx.columns)
+	if err := db.Exec(SQL).Error; err != nil {
+		return err
+	}
+
+	return checkIndexValid(db, idx.name)
+}
+
+// checkIndexValid makes sure the migration is not recorded with an index the planner ignores
+func checkIndexValid(db *gorm.DB, name string) error {
+
+	state, err := getIndexState(db, name)
+	if err != nil {
+		return err
+	}
+	if !state.Exists || !state.Valid {
+		return fmt.Errorf("index %q is not valid after its build", name)
+	}
+	return nil
+}
+
+// dropIndexConcurrently drops the index without blocking the writes,
+// the index of a partitioned table can only be dropped along with the ones of its partitions, without CONCURRENTLY
+func dropIndexConcurrently(db *gorm.DB, name string) error {
+
+	state, err := getIndexState(db, name)
+	if err != nil {
+		return err
+	}
+	if !state.Exists {
+		return nil
+	}
+	if state.Partitioned {
+		return db.Exec(fmt.Sprintf(`DROP INDEX IF EXISTS %q`, name)).Error
+	}
+	return db.Exec(fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %q`, name)).Error
+}
+
+// listPartitionTables lists all the partitions of the table, the default one included
+func listPartitionTables(db *gorm.DB, table string) ([]string, error) {
+
+	var rows []struct{ Name string }
+
+	SQL := `
+		SELECT c."relname" AS "name"
+		FROM "pg_inherits" i
+		JOIN "pg_class" c ON c."oid" = i."inhrelid"
+		WHERE i."inhparent" = TO_REGCLASS(?)
+		ORDER BY c."relname" ASC`
+	if err := Query(db, SQL, &rows, table); err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(rows))
+	for _, r := range rows {
+		res = append(res, r.Name)
+	}
+	return res, nil
+}
+
+// partitionIndexName names the index of a partition after the partition and the columns part of the parent index name,
+// e.g. celestia_nodes_p202401_node_id_id for idx_celestia_nodes_node_id_id
+func partitionIndexName(idx concurrentIndex, partition string) string {
+	return partition + "_" + strings.TrimPrefix(idx.name, "idx_"+idx.table+"_")
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// AlertEventFilter picks the alert events, the empty fields match everything
+type AlertEventFilter struct {
+	Rule   string
+	NodeId string
+	State  string
+	From   time.Time
+	To     time.Time
+}
+
+// AddAlertEvent stores a transition of an alert, the time is stored in UTC as SQLite compares it as text
+func (m *Metrics) AddAlertEvent(event *models.AlertEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+	return m.db.Create(event).Error
+}
+
+// GetAlertEvents returns the alert events matching the filter, the newest first
+func (m *Metrics) GetAlertEvents(filter AlertEventFilter, offset, limit int) ([]models.AlertEvent, int64, error) {
+
+	var res []models.AlertEvent
+
+	var count int64
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	tx := m.db.Model(&models.AlertEvent{})
+	if filter.Rule != "" {
+		tx = tx.Where(`"rule" = ?`, filter.Rule)
+	}
+	if filter.NodeId != "" {
+		tx = tx.Where(`"node_id" = ?`, filter.NodeId)
+	}
+	if filter.State != "" {
+		tx = tx.Where(`"state" = ?`, filter.State)
+	}
+	if !filter.From.IsZero() {
+		tx = tx.Where(`"created_at" >= ?`, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where(`"created_at" < ?`, filter.To.UTC())
+	}
+
+	if err := tx.Count(&count).Error; err != nil {
+		return res, count, err
+	}
+
+	err := tx.Order(`"id" DESC`).Offset(offset).Limit(limit).Find(&res).Error
+	return res, count, err
+}
+
+// GetLastAlertEvents returns the latest event of every rule and node,
+// so the state of the alerts can be restored on start
+func (m *Metrics) GetLastAlertEvents() ([]models.AlertEvent, error) {
+
+	var res []models.AlertEvent
+
+	err := m.db.Where(`"id" IN (?)`,
+		m.db.Model(&models.AlertEvent{}).Select(`MAX("id")`).Group(`"rule", "node_id"`),
+	).Find(&res).Error
+
+	return res, err
+}
+
+// GetLatestNodeStates returns the latest state of every node seen since the given time
+func (m *Metrics) GetLatestNodeStates(since time.Time) ([]models.LatestNodeState, error) {
+	var res []models.LatestNodeState
+	err := m.db.Where(`"last_seen_at" >= ?`, m.timeArg(since)).Find(&res).Error
+	return res, err
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/storage"
+)
+
+// The few SQL expressions which differ between the storage backends,
+// the queries are otherwise written so they run on both of them
+
+func (m *Metrics) isSQLite() bool {
+	return m.store.Dialect() == storage.DialectSQLite
+}
+
+// secondsBetweenSQL returns the SQL of the number of seconds from earlier to later, both time expressions
+func (m *Metrics) secondsBetweenSQL(later, earlier string) string {
+	if m.isSQLite() {
+		// JULIANDAY is a float, rounded to the millisecond it gives the exact difference back
+		return "ROUND((JULIANDAY(" + later + ") - JULIANDAY(" + earlier + ")) * 86400.0, 3)"
+	}
+	return "EXTRACT(EPOCH FROM (" + later + " - " + earlier + "))"
+}
+
+// bucketStartSQL returns the SQL of the beginning, in UTC, of the bucket the time expression falls in
+func (m *Metrics) bucketStartSQL(resolution Resolution, t string) string {
+	if m.isSQLite() {
+		// the format the driver writes the times in, so the buckets compare with them as text
+		format := "%Y-%m-%d %H:00:00+00:00"
+		if resolution == ResolutionDay {
+			format = "%Y-%m-%d 00:00:00+00:00"
+		}
+		return "STRFTIME('" + format + "', " + t + ")"
+	}
+	return "DATE_TRUNC('" + string(resolution) + "', " + t + " AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'"
+}
+
+// timeArg returns t as a query argument, SQLite compares the times as text and the samples are stored in UTC
+func (m *Metrics) timeArg(t time.Time) interface{} {
+	if m.isSQLite() {
+		return t.UTC()
+	}
+	return t
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// ExportNodes calls fn for every sample matching the filter, the oldest first. The samples are read
+// from a database cursor one at a time, so any number of them is exported with constant memory.
+func (m *Metrics) ExportNodes(ctx context.Context, filter NodeFilter, fn func(node *models.CelestiaNode) error) error {
+
+	tx, err := m.nodesQuery(filter)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.WithContext(ctx).Order(`"id" ASC`).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var node models.CelestiaNode
+		if err := m.db.ScanRows(rows, &node); err != nil {
+			return err
+		}
+		if err := fn(&node); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// Ping checks the database can be reached
+func (m *Metrics) Ping(ctx context.Context) error {
+	sqlDB, err := m.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// GetLatestSampleTime returns when the newest sample was taken, a zero time if there is none
+func (m *Metrics) GetLatestSampleTime(ctx context.Context) (time.Time, error) {
+
+	var rows []struct{ CreatedAt time.Time }
+
+	tx := m.db.WithContext(ctx).Model(&models.CelestiaNode{}).
+		Select(`"created_at"`).Order(`"id" DESC`).Limit(1).Find(&rows)
+	if tx.Error != nil || len(rows) == 0 {
+		return time.Time{}, tx.Error
+	}
+
+	return rows[0].CreatedAt, nil
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+const (
+	// Number of insert batches a subscriber may lag behind before it is dropped,
+	// a whole batch is published at once so the buffer must hold more than one
+	subscriberBufferBatches = 4
+
+	// Number of samples a subscriber may lag behind before it is dropped
+	DefaultSubscriberBuffer = subscriberBufferBatches * DefaultInsertBatchSize
+)
+
+// SampleFilter picks the samples a subscriber gets, an empty filter matches all of them
+type SampleFilter struct {
+	NodeId   string
+	NodeType *receiver.NodeType
+}
+
+func (f SampleFilter) match(node *models.CelestiaNode) bool {
+	if f.NodeId != "" && node.NodeId != f.NodeId {
+		return false
+	}
+	if f.NodeType != nil && node.NodeType != *f.NodeType {
+		return false
+	}
+	return true
+}
+
+// Subscription receives the samples matching its filter as they are written into the database.
+// Its channel is closed when it is unsubscribed, or when it is dropped for being too slow.
+type Subscription struct {
+	filter  SampleFilter
+	samples chan *models.CelestiaNode
+	dropped atomic.Bool
+}
+
+func (s *Subscription) Samples() <-chan *models.CelestiaNode {
+	return s.samples
+}
+
+// Dropped tells if the subscriber was dropped because it did not keep up
+func (s *Subscription) Dropped() bool {
+	return s.dropped.Load()
+}
+
+// Hub fans out the written samples to the subscribers without ever blocking the writer,
+// each subscriber has its own buffer and is dropped once it is full
+type Hub struct {
+	bufferSize int
+
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultSubscriberBuffer
+	}
+	return &Hub{
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// SetBatchSize sizes the buffer of the new subscribers after the number of samples published at once,
+// so a subscriber is dropped only when it is a few batches behind
+func (h *Hub) SetBatchSize(size int) {
+	if size <= 0 {
+		return
+	}
+	h.mu.Lock()
+	h.bufferSize = subscriberBufferBatches * size
+	h.mu.Unlock()
+}
+
+func (h *Hub) Subscribe(filter SampleFilter) *Subscription {
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := &Subscription{
+		filter:  filter,
+		samples: make(chan *models.CelestiaNode, h.bufferSize),
+	}
+	h.subscribers[s] = struct{}{}
+
+	return s
+}
+
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.samples)
+	}
+}
+
+// Publish hands the samples to the matching subscribers
+func (h *Hub) Publish(batch []*models.CelestiaNode) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers {
+		for _, node := range batch {
+			if !s.filter.match(node) {
+				continue
+			}
+			select {
+			case s.samples <- node:
+			default:
+				s.dropped.Store(true)
+				delete(h.subscribers, s)
+				close(s.samples)
+			}
+			if s.dropped.Load() {
+				break
+			}
+		}
+	}
+}
+
+// Len returns the number of subscribers
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
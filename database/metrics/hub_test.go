@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func samplesOf(nodeIds ...string) []*models.CelestiaNode {
+	batch := make([]*models.CelestiaNode, 0, len(nodeIds))
+	for _, nodeId := range nodeIds {
+		batch = append(batch, &models.CelestiaNode{NodeId: nodeId, NodeType: receiver.FullNodeType})
+	}
+	return batch
+}
+
+// receive takes what is buffered in the subscription without waiting
+func receive(s *Subscription) []string {
+	var nodeIds []string
+	for {
+		select {
+		case node, ok := <-s.Samples():
+			if !ok {
+				return nodeIds
+			}
+			nodeIds = append(nodeIds, node.NodeId)
+		default:
+			return nodeIds
+		}
+	}
+}
+
+func TestHubFanOut(t *testing.T) {
+
+	h := NewHub(10)
+	a := h.Subscribe(SampleFilter{})
+	b := h.Subscribe(SampleFilter{})
+
+	h.Publish(samplesOf("node-1", "node-2"))
+
+	for name, s := range map[string]*Subscription{"a": a, "b": b} {
+		if got := fmt.Sprint(receive(s)); got != "[node-1 node-2]" {
+			t.Errorf("subscriber %s got %s, want both samples in order", name, got)
+		}
+	}
+	if h.Len() != 2 {
+		t.Errorf("%d subscribers, want 2", h.Len())
+	}
+}
+
+func TestHubFilters(t *testing.T) {
+
+	h := NewHub(10)
+	bridge := receiver.BridgeNodeType
+	byId := h.Subscribe(SampleFilter{NodeId: "node-2"})
+	byType := h.Subscribe(SampleFilter{NodeType: &bridge})
+
+	batch := samplesOf("node-1", "node-2", "node-3")
+	batch[2].NodeType = receiver.BridgeNodeType
+	h.Publish(batch)
+
+	if got := fmt.Sprint(receive(byId)); got != "[node-2]" {
+		t.Errorf("node id filter got %s, want [node-2]", got)
+	}
+	if got := fmt.Sprint(receive(byType)); got != "[node-3]" {
+		t.Errorf("node type filter got %s, want [node-3]", got)
+	}
+}
+
+// A subscriber is dropped once it is behind by more than its buffer, the others keep getting the samples
+func TestHubDrop(t *testing.T) {
+
+	h := NewHub(3)
+	slow := h.Subscribe(SampleFilter{})
+	filtered := h.Subscribe(SampleFilter{NodeId: "node-1"})
+
+	h.Publish(samplesOf("node-1", "node-2"))
+	if slow.Dropped() {
+		t.Fatal("dropped within its buffer")
+	}
+	h.Publish(samplesOf("node-3", "node-4"))
+
+	if !slow.Dropped() {
+		t.Fatal("not dropped beyond its buffer")
+	}
+	if got := fmt.Sprint(receive(slow)); got != "[node-1 node-2 node-3]" {
+		t.Errorf("the dropped subscriber got %s, want what fit in its buffer", got)
+	}
+	if _, ok := <-slow.Samples(); ok {
+		t.Error("the channel of the dropped subscriber is not closed")
+	}
+
+	if filtered.Dropped() || h.Len() != 1 {
+		t.Errorf("filtered dropped: %v, %d subscribers, want only the slow one dropped", filtered.Dropped(), h.Len())
+	}
+	if got := fmt.Sprint(receive(filtered)); got != "[node-1]" {
+		t.Errorf("filtered subscriber got %s, want [node-1]", got)
+	}
+}
+
+// Unsubscribing a dropped subscriber does not close its channel again
+func TestHubUnsubscribeAfterDrop(t *testing.T) {
+
+	h := NewHub(1)
+	s := h.Subscribe(SampleFilter{})
+	h.Publish(samplesOf("node-1", "node-2"))
+	if !s.Dropped() {
+		t.Fatal("not dropped")
+	}
+
+	h.Unsubscribe(s)
+	h.Unsubscribe(s)
+	if h.Len() != 0 {
+		t.Errorf("%d subscribers, want 0", h.Len())
+	}
+}
+
+// The buffer follows the insert batch size, so a full batch never drops an idle subscriber
+func TestHubFullBatch(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+	m.InsertQueue.SetBatchSize(1000)
+	s := m.Hub.Subscribe(SampleFilter{})
+
+	batch := make([]string, 1000)
+	for i := range batch {
+		batch[i] = fmt.Sprintf("node-%d", i)
+	}
+	m.Hub.Publish(samplesOf(batch...))
+	m.Hub.Publish(samplesOf(batch...))
+
+	if s.Dropped() {
+		t.Fatal("dropped on two full batches")
+	}
+	if got := len(receive(s)); got != 2000 {
+		t.Errorf("%d samples received, want 2000", got)
+	}
+}
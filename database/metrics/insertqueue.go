@@ -1,58 +1,557 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"github.com/celestiaorg/nodelogger/telemetry"
 	fifo "github.com/foize/go.fifo"
 )
 
+const (
+	DefaultInsertBatchSize     = 500
+	DefaultInsertFlushInterval = 1 * time.Second
+
+	minReplayBackoff = 1 * time.Second
+	maxReplayBackoff = 1 * time.Minute
+
+	// a spooled batch failing this many times with an error that is neither a rejection of the data
+	// nor a connection error is set aside, so it does not hold back the samples spooled after it
+	maxReplayAttempts = 10
+
+	// how long an aborted drain still waits for the write in flight to return and be spooled
+	defaultAbortWait = 5 * time.Second
+)
+
+var ErrQueueClosed = errors.New("insert queue is closed")
+
 type InsertQueue struct {
 	insert  *fifo.Queue
 	metrics *Metrics
-	closed  bool
+
+	batchSize     int
+	flushInterval time.Duration
+	abortWait     time.Duration
+
+	// When set, the samples that cannot be written into the DB are kept here
+	// and replayed in order once the DB is reachable again
+	spool *Spool
+
+	notify    chan struct{}
+	done      chan struct{} // closed when the queue stops accepting data and drains the rest
+	abort     chan struct{} // closed when the remaining data must not be written anymore
+	finished  chan struct{} // closed when the writer and the replayer goroutines exit
+	closeOnce sync.Once
+	abortOnce sync.Once
+	takeMu    sync.Mutex // the writer and SpoolRemaining never take items from the fifo at the same time
+	closed    atomic.Bool
+	started   atomic.Bool
+
+	pending         atomic.Int64 // items popped from the fifo but not flushed yet
+	insertedRows    atomic.Uint64
+	failedRows      atomic.Uint64
+	spooledRows     atomic.Uint64
+	replayedRows    atomic.Uint64
+	flushes         atomic.Uint64
+	lastFlushNanos  atomic.Int64
+	maxFlushNanos   atomic.Int64
+	totalFlushNanos atomic.Int64
+}
+
+type InsertQueueStats struct {
+	QueueDepth         int64   `json:"queue_depth"`
+	BatchSize          int     `json:"batch_size"`
+	FlushIntervalMs    int64   `json:"flush_interval_ms"`
+	InsertedRows       uint64  `json:"inserted_rows"`
+	FailedRows         uint64  `json:"failed_rows"`
+	SpooledRows        uint64  `json:"spooled_rows"`
+	ReplayedRows       uint64  `json:"replayed_rows"`
+	Flushes            uint64  `json:"flushes"`
+	LastFlushLatencyMs float64 `json:"last_flush_latency_ms"`
+	MaxFlushLatencyMs  float64 `json:"max_flush_latency_ms"`
+	AvgFlushLatencyMs  float64 `json:"avg_flush_latency_ms"`
 }
 
 func NewInsertQueue(metrics *Metrics) *InsertQueue {
 	return &InsertQueue{
-		insert:  fifo.NewQueue(),
-		metrics: metrics,
-		closed:  false,
+		insert:        fifo.NewQueue(),
+		metrics:       metrics,
+		batchSize:     DefaultInsertBatchSize,
+		flushInterval: DefaultInsertFlushInterval,
+		abortWait:     defaultAbortWait,
+		notify:        make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		abort:         make(chan struct{}),
+		finished:      make(chan struct{}),
+	}
+}
+
+// SetBatchSize sets the maximum number of rows written in one transaction,
+// the storage splits it in as many statements as the bind parameters limit needs.
+// It must be called before Start.
+func (i *InsertQueue) SetBatchSize(size int) {
+	if size > 0 {
+		i.batchSize = size
+		i.metrics.Hub.SetBatchSize(size)
 	}
 }
 
+// SetFlushInterval sets how long an incomplete batch may wait before it is written.
+// It must be called before Start.
+func (i *InsertQueue) SetFlushInterval(interval time.Duration) {
+	if interval > 0 {
+		i.flushInterval = interval
+	}
+}
+
+// SetSpool makes the queue keep the samples it fails to write in the given spool
+// instead of dropping them. It must be called before Start.
+func (i *InsertQueue) SetSpool(spool *Spool) {
+	i.spool = spool
+}
+
+func (i *InsertQueue) Spool() *Spool {
+	return i.spool
+}
+
 // This function puts data in a queue and adds them to the DB whenever it can,
 //
 //	to be able to keep up with a big load of coming data
-func (i *InsertQueue) Add(data *models.CelestiaNode) {
+func (i *InsertQueue) Add(data *models.CelestiaNode) error {
+	if i.isClosed() {
+		return ErrQueueClosed
+	}
+
+	// The sample time must not depend on when it actually reaches the DB
+	if data.CreatedAt.IsZero() {
+		data.CreatedAt = time.Now()
+	}
 	i.insert.Add(data)
+
+	// wake up the writer without blocking the caller
+	select {
+	case i.notify <- struct{}{}:
+	default:
+	}
+	return nil
 }
 
 func (i *InsertQueue) Start() error {
-	if i.closed {
+	if i.isClosed() {
 		return fmt.Errorf("queue is already closed")
 	}
+	if !i.started.CompareAndSwap(false, true) {
+		return fmt.Errorf("queue is already started")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		for !i.closed {
+		defer wg.Done()
+		i.run()
+	}()
+
+	if i.spool != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			i.replay()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(i.finished)
+	}()
+	return nil
+}
+
+// Stop closes the queue without waiting for the queued data to be written
+func (i *InsertQueue) Stop() {
+	i.close()
+	i.abortOnce.Do(func() { close(i.abort) })
+}
+
+// Drain closes the queue and writes everything still queued into the DB.
+// If ctx expires first, the writing is aborted: the writer spools the batch it holds
+// once its write returns and stops taking samples from the queue, Drain waits a bounded time for that.
+// The number of samples that made it neither into the DB nor into the spool is returned along with the ctx error,
+// the ones still queued can then be moved into the spool with SpoolRemaining.
+func (i *InsertQueue) Drain(ctx context.Context) (int64, error) {
+	i.close()
+
+	if !i.started.Load() {
+		return i.Len(), nil
+	}
+
+	select {
+	case <-i.finished:
+		return i.Len(), nil
+	case <-ctx.Done():
+	}
+
+	i.abortOnce.Do(func() { close(i.abort) })
+	select {
+	case <-i.finished:
+	case <-time.After(i.abortWait):
+		log.Printf("insert queue: the write in flight did not return in %v after the drain was aborted\n", i.abortWait)
+	}
+	return i.Len(), ctx.Err()
+}
 
+// SpoolRemaining moves the samples still queued into the spool once a drain was cut short,
+// so they are replayed on the next start instead of being lost. It returns how many were spooled.
+// The writer does not take anything from the queue once the drain is aborted, so no sample is taken by both.
+func (i *InsertQueue) SpoolRemaining() (int64, error) {
+	if i.spool == nil {
+		return 0, fmt.Errorf("no spool is set")
+	}
+
+	i.takeMu.Lock()
+	defer i.takeMu.Unlock()
+
+	spooled := int64(0)
+	for {
+		batch := make([]*models.CelestiaNode, 0, i.batchSize)
+		for len(batch) < i.batchSize {
 			item := i.insert.Next()
 			if item == nil {
-				// Queue is empty, keep waiting
-				time.Sleep(50 * time.Millisecond)
+				break
+			}
+			batch = append(batch, item.(*models.CelestiaNode))
+		}
+		if len(batch) == 0 {
+			return spooled, nil
+		}
+
+		if err := i.spool.Append(batch); err != nil {
+			i.failedRows.Add(uint64(len(batch)))
+			return spooled, err
+		}
+		i.spooledRows.Add(uint64(len(batch)))
+		spooled += int64(len(batch))
+	}
+}
+
+func (i *InsertQueue) close() {
+	i.closed.Store(true)
+	i.closeOnce.Do(func() { close(i.done) })
+}
+
+func (i *InsertQueue) isClosed() bool {
+	return i.closed.Load()
+}
+
+func (i *InsertQueue) isAborted() bool {
+	select {
+	case <-i.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// take pops the next queued item for the writer, it counts as pending until it is flushed.
+// Nothing is taken once the writing is aborted, what is left is for SpoolRemaining.
+func (i *InsertQueue) take() *models.CelestiaNode {
+	i.takeMu.Lock()
+	defer i.takeMu.Unlock()
+
+	if i.isAborted() {
+		return nil
+	}
+	item := i.insert.Next()
+	if item == nil {
+		return nil
+	}
+	i.pending.Add(1)
+	return item.(*models.CelestiaNode)
+}
+
+// run collects the queued items into batches and writes a batch
+// either when it is full or when its oldest item waited for flushInterval
+func (i *InsertQueue) run() {
+
+	batch := make([]*models.CelestiaNode, 0, i.batchSize)
+
+	deadline := time.NewTimer(i.flushInterval)
+	if !deadline.Stop() {
+		<-deadline.C
+	}
+	deadlineSet := false
+
+	flush := func() {
+		if deadlineSet && !deadline.Stop() {
+			<-deadline.C
+		}
+		deadlineSet = false
+
+		i.flush(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		for len(batch) < i.batchSize {
+			item := i.take()
+			if item == nil {
+				break
+			}
+			batch = append(batch, item)
+		}
+
+		if len(batch) >= i.batchSize {
+			flush()
+			continue
+		}
+
+		if len(batch) > 0 && !deadlineSet {
+			deadline.Reset(i.flushInterval)
+			deadlineSet = true
+		}
+
+		select {
+		case <-i.notify:
+		case <-deadline.C:
+			deadlineSet = false
+			flush()
+		case <-i.done:
+			flush()
+			i.drain(batch)
+			return
+		}
+	}
+}
+
+// drain writes whatever is left in the queue until it is empty or aborted
+func (i *InsertQueue) drain(batch []*models.CelestiaNode) {
+	for !i.isAborted() {
+		batch = batch[:0]
+		for len(batch) < i.batchSize {
+			item := i.take()
+			if item == nil {
+				break
+			}
+			batch = append(batch, item)
+		}
+
+		if len(batch) == 0 {
+			return
+		}
+		i.flush(batch)
+	}
+}
+
+func (i *InsertQueue) flush(batch []*models.CelestiaNode) {
+	if len(batch) == 0 {
+		return
+	}
+
+	// keep the order: nothing goes directly to the DB while older samples wait in the spool,
+	// and once the drain is aborted there is no time left to write
+	if i.spool != nil && (i.isAborted() || !i.spool.Empty()) {
+		i.pending.Add(-int64(len(batch)))
+		i.spoolBatch(batch)
+		return
+	}
+
+	var written []*models.CelestiaNode
+	begin := time.Now()
+	rest, err := i.writeBatch(batch, &written)
+	latency := time.Since(begin).Nanoseconds()
+
+	i.pending.Add(-int64(len(batch)))
+	i.flushes.Add(1)
+	i.lastFlushNanos.Store(latency)
+	i.totalFlushNanos.Add(latency)
+	for {
+		max := i.maxFlushNanos.Load()
+		if latency <= max || i.maxFlushNanos.CompareAndSwap(max, latency) {
+			break
+		}
+	}
+
+	if len(written) > 0 {
+		i.insertedRows.Add(uint64(len(written)))
+		telemetry.CountIngested(written)
+		i.metrics.Hub.Publish(written)
+	}
+	if err != nil {
+		telemetry.InsertErrors.Inc()
+		log.Printf("async batch insert of %d rows: %v\n", len(rest), err)
+		i.spoolBatch(rest)
+	}
+}
+
+// writeBatch writes the batch into the DB. When the DB rejects it for good, the batch is split in halves
+// written on their own, down to single rows, so only the rows the DB rejects go to the dead letters.
+// The rows written are appended to written. On any other error, the rows not written yet are returned with it.
+func (i *InsertQueue) writeBatch(batch []*models.CelestiaNode, written *[]*models.CelestiaNode) ([]*models.CelestiaNode, error) {
+
+	err := i.metrics.AddNodeDataBatch(batch)
+	if err == nil {
+		*written = append(*written, batch...)
+		return nil, nil
+	}
+	if !storage.IsPermanentError(err) {
+		return batch, err
+	}
+	if len(batch) == 1 {
+		telemetry.InsertErrors.Inc()
+		i.deadLetter(batch, err)
+		return nil, nil
+	}
+
+	half := len(batch) / 2
+	if rest, err := i.writeBatch(batch[:half], written); err != nil {
+		// the rest of the first half is followed by the whole second half
+		return batch[half-len(rest):], err
+	}
+	return i.writeBatch(batch[half:], written)
+}
+
+func (i *InsertQueue) spoolBatch(batch []*models.CelestiaNode) {
+	if i.spool == nil {
+		i.failedRows.Add(uint64(len(batch)))
+		return
+	}
+
+	if err := i.spool.Append(batch); err != nil {
+		i.failedRows.Add(uint64(len(batch)))
+		log.Printf("spooling %d rows: %v\n", len(batch), err)
+		return
+	}
+	i.spooledRows.Add(uint64(len(batch)))
+}
+
+// deadLetter drops the samples the DB does not accept, they are kept in the dead letter file of the spool if there is one
+func (i *InsertQueue) deadLetter(batch []*models.CelestiaNode, reason error) {
+	i.failedRows.Add(uint64(len(batch)))
+	if i.spool == nil {
+		log.Printf("dropping %d rows rejected by the DB: %v\n", len(batch), reason)
+		return
+	}
+
+	if err := i.spool.DeadLetter(batch, reason); err != nil {
+		log.Printf("dropping %d rows rejected by the DB: %v, they are lost: %v\n", len(batch), reason, err)
+		return
+	}
+	log.Printf("dropping %d rows rejected by the DB into the dead letter file of the spool: %v\n", len(batch), reason)
+}
+
+// replay writes the spooled samples into the DB in the order they were spooled,
+// retrying with an exponential backoff while the DB is not reachable.
+// The rows the DB rejects go to the dead letter file, and so does a batch which keeps failing for another reason.
+// Only the rows not written yet are retried, the spooled batch is committed once none is left.
+func (i *InsertQueue) replay() {
+
+	var batch []*models.CelestiaNode // the rows of the spooled batch not written yet
+	var pos SpoolPosition
+	spooled := 0
+
+	backoff := minReplayBackoff
+	attempts := 0
+	for {
+		if len(batch) == 0 {
+			var err error
+			batch, pos, err = i.spool.Read(i.batchSize)
+			if err != nil {
+				log.Printf("spool replay: %v\n", err)
+			}
+
+			if err != nil || len(batch) == 0 {
+				wait := backoff
+				if err == nil {
+					wait = maxReplayBackoff // nothing to do, wait for new samples
+				}
+				select {
+				case <-i.spool.Appended():
+				case <-time.After(wait):
+				case <-i.done:
+					return
+				}
 				continue
 			}
+			spooled = len(batch)
+		}
+
+		var written []*models.CelestiaNode
+		rest, err := i.writeBatch(batch, &written)
+		if len(written) > 0 {
+			i.replayedRows.Add(uint64(len(written)))
+			telemetry.CountIngested(written)
+			i.metrics.Hub.Publish(written)
+		}
+
+		if err != nil {
+			telemetry.InsertErrors.Inc()
+			attempts++
+			batch = rest
 
-			data := item.(*models.CelestiaNode)
-			if err := i.metrics.AddNodeData(data); err != nil {
-				log.Printf("async insert: %v\n\t data: %#v\n", err, data)
+			if storage.IsConnectionError(err) || attempts < maxReplayAttempts {
+				log.Printf("spool replay of %d rows, attempt %d, retrying in %v: %v\n", len(batch), attempts, backoff, err)
+				select {
+				case <-time.After(backoff):
+				case <-i.done:
+					return
+				}
+				backoff *= 2
+				if backoff > maxReplayBackoff {
+					backoff = maxReplayBackoff
+				}
+				continue
 			}
+			i.deadLetter(batch, err)
 		}
-	}()
-	return nil
+		batch = nil
+		attempts, backoff = 0, minReplayBackoff
+
+		if err := i.spool.Commit(pos, spooled); err != nil {
+			log.Printf("spool replay commit: %v\n", err)
+		}
+
+		select {
+		case <-i.done:
+			return
+		default:
+		}
+	}
 }
 
-func (i *InsertQueue) Stop() {
-	i.closed = true
+// Len returns the number of items waiting to be written into the DB
+func (i *InsertQueue) Len() int64 {
+	return int64(i.insert.Len()) + i.pending.Load()
+}
+
+func (i *InsertQueue) Stats() InsertQueueStats {
+	flushes := i.flushes.Load()
+
+	avg := float64(0)
+	if flushes > 0 {
+		avg = float64(i.totalFlushNanos.Load()) / float64(flushes)
+	}
+
+	return InsertQueueStats{
+		QueueDepth:         i.Len(),
+		BatchSize:          i.batchSize,
+		FlushIntervalMs:    i.flushInterval.Milliseconds(),
+		InsertedRows:       i.insertedRows.Load(),
+		FailedRows:         i.failedRows.Load(),
+		SpooledRows:        i.spooledRows.Load(),
+		ReplayedRows:       i.replayedRows.Load(),
+		Flushes:            flushes,
+		LastFlushLatencyMs: nanosToMs(i.lastFlushNanos.Load()),
+		MaxFlushLatencyMs:  nanosToMs(i.maxFlushNanos.Load()),
+		AvgFlushLatencyMs:  avg / float64(time.Millisecond),
+	}
+}
+
+func nanosToMs(n int64) float64 {
+	return float64(n) / float64(time.Millisecond)
 }
@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/database/storage"
+	"github.com/jackc/pgconn"
+)
+
+// blockingStorage holds every write until release gets the result of it
+type blockingStorage struct {
+	storage.Storage
+	writing chan []*models.CelestiaNode
+	release chan error
+}
+
+func (b *blockingStorage) AddNodeData(data []*models.CelestiaNode) error {
+	b.writing <- data
+	if err := <-b.release; err != nil {
+		return err
+	}
+	return b.Storage.AddNodeData(data)
+}
+
+// The samples a drain could not write in time go to the spool instead of being lost
+func TestInsertQueueSpoolRemaining(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	spool, err := OpenSpool(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+
+	q := NewInsertQueue(m)
+	q.SetBatchSize(2)
+	q.SetSpool(spool)
+
+	for i := 0; i < 5; i++ {
+		if err := q.Add(&models.CelestiaNode{NodeId: fmt.Sprintf("node-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the queue was never started, a cancelled drain leaves everything queued
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	left, err := q.Drain(ctx)
+	if err != nil || left != 5 {
+		t.Fatalf("%d samples left (err: %v), want 5", left, err)
+	}
+
+	spooled, err := q.SpoolRemaining()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spooled != 5 || q.Len() != 0 || spool.Stats().Samples != 5 {
+		t.Errorf("%d samples spooled, %d left in the queue and %d in the spool, want all 5 in the spool", spooled, q.Len(), spool.Stats().Samples)
+	}
+
+	batch, _, err := spool.Read(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 5 || batch[0].NodeId != "node-0" || batch[4].NodeId != "node-4" {
+		t.Errorf("spooled samples %+v, want the 5 in order", batch)
+	}
+
+	if err := q.Add(&models.CelestiaNode{NodeId: "late"}); err != ErrQueueClosed {
+		t.Errorf("adding after the drain: %v, want ErrQueueClosed", err)
+	}
+}
+
+// The batch being written when the drain times out is spooled by the writer once the write returns,
+// and what the writer never took is left for SpoolRemaining, so nothing is lost nor spooled twice
+func TestInsertQueueAbortedDrain(t *testing.T) {
+
+	store := &blockingStorage{
+		Storage: newSQLiteMetrics(t).store,
+		writing: make(chan []*models.CelestiaNode),
+		release: make(chan error),
+	}
+	m := NewWithStorage(store)
+
+	spool, err := OpenSpool(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer spool.Close()
+
+	q := m.InsertQueue
+	q.SetBatchSize(2)
+	q.SetSpool(spool)
+	q.abortWait = 5 * time.Second
+
+	for i := 0; i < 5; i++ {
+		if err := q.Add(&models.CelestiaNode{NodeId: fmt.Sprintf("node-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	inFlight := <-store.writing
+	if len(inFlight) != 2 {
+		t.Fatalf("%d samples in flight, want a full batch of 2", len(inFlight))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	type result struct {
+		left int64
+		err  error
+	}
+	drained := make(chan result, 1)
+	go func() {
+		left, err := q.Drain(ctx)
+		drained <- result{left, err}
+	}()
+
+	// the database answers only after the drain was aborted
+	<-ctx.Done()
+	time.Sleep(50 * time.Millisecond)
+	store.release <- errors.New("connection reset by peer")
+
+	r := <-drained
+	if !errors.Is(r.err, context.DeadlineExceeded) {
+		t.Fatalf("drain error %v, want the deadline", r.err)
+	}
+	if r.left != 3 {
+		t.Errorf("%d samples left after the drain, want the 3 the writer never took", r.left)
+	}
+
+	spooled, err := q.SpoolRemaining()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spooled != 3 || q.Len() != 0 {
+		t.Errorf("%d samples spooled by SpoolRemaining and %d left in the queue, want 3 and 0", spooled, q.Len())
+	}
+
+	batch, _, err := spool.Read(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 5 {
+		t.Fatalf("%d samples in the spool, want all 5", len(batch))
+	}
+	for i, data := range batch {
+		if want := fmt.Sprintf("node-%d", i); data.NodeId != want {
+			t.Errorf("spooled sample %d is %s, want %s", i, data.NodeId, want)
+		}
+	}
+}
+
+// A write in flight that never returns only holds the drain for the abort wait,
+// its samples are reported as not stored
+func TestInsertQueueAbortedDrainStuckWrite(t *testing.T) {
+
+	store := &blockingStorage{
+		Storage: newSQLiteMetrics(t).store,
+		writing: make(chan []*models.CelestiaNode),
+		release: make(chan error),
+	}
+	m := NewWithStorage(store)
+
+	q := m.InsertQueue
+	q.SetBatchSize(2)
+	q.abortWait = 50 * time.Millisecond
+
+	for i := 0; i < 3; i++ {
+		if err := q.Add(&models.CelestiaNode{NodeId: fmt.Sprintf("node-%d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	<-store.writing
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	left, err := q.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("drain error %v, want the deadline", err)
+	}
+	if left != 3 {
+		t.Errorf("%d samples left, want the 2 in flight and the 1 queued", left)
+	}
+	if elapsed := time.Since(begin); elapsed > 2*time.Second {
+		t.Errorf("the drain took %v, want it bounded by the abort wait", elapsed)
+	}
+
+	// let the writer exit
+	store.release <- errors.New("connection reset by peer")
+}
+
+// rejectingStorage rejects for good every batch holding a sample of a node id starting with "bad"
+type rejectingStorage struct {
+	storage.Storage
+}
+
+func (r *rejectingStorage) AddNodeData(data []*models.CelestiaNode) error {
+	for _, d := range data {
+		if strings.HasPrefix(d.NodeId, "bad") {
+			return &pgconn.PgError{Code: "22021", Message: "invalid byte sequence"}
+		}
+	}
+	return r.Storage.AddNodeData(data)
+}
+
+func readDeadLetters(t *testing.T, dir string) []deadLetterEntry {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, spoolDeadLetter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []deadLetterEntry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e deadLetterEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Only the rows the DB rejects go to the dead letters, the rest of their batch is written,
+// both when the batch is flushed and when it is replayed from the spool
+func TestInsertQueueDeadLettersRejectedRowsOnly(t *testing.T) {
+
+	for _, replayed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("replayed=%v", replayed), func(t *testing.T) {
+
+			m := NewWithStorage(&rejectingStorage{Storage: newSQLiteMetrics(t).store})
+
+			dir := t.TempDir()
+			spool, err := OpenSpool(dir, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer spool.Close()
+
+			batch := []*models.CelestiaNode{}
+			for i := 0; i < 8; i++ {
+				nodeId := fmt.Sprintf("node-%d", i)
+				if i == 2 || i == 5 {
+					nodeId = fmt.Sprintf("bad-%d", i)
+				}
+				batch = append(batch, &models.CelestiaNode{NodeId: nodeId, CreatedAt: time.Now()})
+			}
+
+			q := m.InsertQueue
+			q.SetBatchSize(len(batch))
+			q.SetSpool(spool)
+			if replayed {
+				if err := spool.Append(batch); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				for _, d := range batch {
+					if err := q.Add(d); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if err := q.Start(); err != nil {
+				t.Fatal(err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for q.Stats().InsertedRows+q.Stats().ReplayedRows < 6 || !spool.Empty() {
+				if time.Now().After(deadline) {
+					t.Fatalf("stats %+v, spool %+v, want the 6 valid rows written", q.Stats(), spool.Stats())
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if _, err := q.Drain(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			_, count, err := m.GetAllNodes(0, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 6 {
+				t.Errorf("%d rows in the DB, want the 6 valid ones", count)
+			}
+			if failed := q.Stats().FailedRows; failed != 2 {
+				t.Errorf("%d failed rows, want the 2 rejected", failed)
+			}
+
+			entries := readDeadLetters(t, dir)
+			if len(entries) != 2 || entries[0].Sample.NodeId != "bad-2" || entries[1].Sample.NodeId != "bad-5" || entries[0].Error == "" {
+				t.Errorf("dead letters %+v, want bad-2 and bad-5 with their error", entries)
+			}
+		})
+	}
+}
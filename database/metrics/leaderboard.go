@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// ParseNodeType returns the node type by its name: bridge, full or light
+func ParseNodeType(name string) (receiver.NodeType, error) {
+	if nType, ok := nodeTypesByName[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return nType, nil
+	}
+	return 0, fmt.Errorf("unknown node type %q, expected one of bridge, full, light", name)
+}
+
+// RefreshLatestNodeStates brings the latest node states up to date with the rows
+// that are not tracked yet, e.g. the rows inserted before the table existed
+func (m *Metrics) RefreshLatestNodeStates() error {
+
+	// Written for both Postgres and SQLite
+	SQL := `
+		INSERT INTO "latest_node_state"
+			("node_id", "last_row_id", "node_type", "version", "uptime", "last_seen_at")
+		SELECT
+			"node_id", "id", "node_type", "version", "uptime", "created_at"
+		FROM "celestia_nodes"
+		WHERE "id" IN (
+			SELECT MAX("id")
+			FROM "celestia_nodes"
+			WHERE
+				"id" > (SELECT COALESCE(MAX("last_row_id"), 0) FROM "latest_node_state")
+				AND "deleted_at" IS NULL
+			GROUP BY "node_id"
+		)
+		ON CONFLICT ("node_id") DO UPDATE SET
+			"last_row_id" = EXCLUDED."last_row_id",
+			"node_type" = EXCLUDED."node_type",
+			"version" = EXCLUDED."version",
+			"uptime" = EXCLUDED."uptime",
+			"last_seen_at" = EXCLUDED."last_seen_at"
+		WHERE "latest_node_state"."last_row_id" < EXCLUDED."last_row_id"`
+
+	return m.db.Exec(SQL).Error
+}
+
+// GetLeaderboard returns the latest sample of every node ranked by uptime,
+// a nil nType means all the node types
+func (m *Metrics) GetLeaderboard(nType *receiver.NodeType, offset, limit int) ([]models.LeaderboardEntry, int64, error) {
+
+	var res []models.LeaderboardEntry
+
+	var count int64
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	// the soft deleted rows are left out, as gorm does for the other queries
+	where := `WHERE n."deleted_at" IS NULL`
+	args := []interface{}{}
+	if nType != nil {
+		where += ` AND s."node_type" = ?`
+		args = append(args, *nType)
+	}
+
+	// The states whose row was removed with its partition are left out of the count as well as the page
+	from := `
+		FROM
+			"latest_node_state" s
+			INNER JOIN "celestia_nodes" n ON n."id" = s."last_row_id"
+		` + where
+
+	var counts []struct{ Count int64 }
+	if err := database.Query(m.db, `SELECT COUNT(*) AS "count"`+from, &counts, args...); err != nil {
+		return res, count, err
+	}
+	if len(counts) > 0 {
+		count = counts[0].Count
+	}
+
+	SQL := `
+		SELECT n.*` + from + `
+		ORDER BY s."uptime" DESC, s."node_id" ASC
+		LIMIT ? OFFSET ?`
+
+	var rows []models.CelestiaNode
+	if err := database.Query(m.db, SQL, &rows, append(args, limit, offset)...); err != nil {
+		return res, count, err
+	}
+
+	res = make([]models.LeaderboardEntry, 0, len(rows))
+	for i, row := range rows {
+		res = append(res, models.LeaderboardEntry{
+			Rank:         offset + i + 1,
+			CelestiaNode: row,
+		})
+	}
+
+	return res, count, nil
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func TestLeaderboard(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	now := time.Now()
+	err := m.AddNodeDataBatch([]*models.CelestiaNode{
+		{NodeId: "bridge-1", NodeType: receiver.BridgeNodeType, Uptime: 90, CreatedAt: now},
+		{NodeId: "light-1", NodeType: receiver.LightNodeType, Uptime: 99, CreatedAt: now},
+		{NodeId: "light-2", NodeType: receiver.LightNodeType, Uptime: 50, CreatedAt: now},
+		{NodeId: "light-3", NodeType: receiver.LightNodeType, Uptime: 70, CreatedAt: now},
+		{NodeId: "light-4", NodeType: receiver.LightNodeType, Uptime: 95, CreatedAt: now},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the row of light-3 is gone, as when its partition is dropped, its state is left behind
+	if err := m.db.Unscoped().Where(`"node_id" = ?`, "light-3").Delete(&models.CelestiaNode{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// the row of light-4 is soft deleted, it is left out as well
+	if err := m.db.Where(`"node_id" = ?`, "light-4").Delete(&models.CelestiaNode{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	light := receiver.LightNodeType
+	tests := []struct {
+		name  string
+		nType *receiver.NodeType
+		want  []string
+	}{
+		{name: "all", want: []string{"light-1", "bridge-1", "light-2"}},
+		{name: "light", nType: &light, want: []string{"light-1", "light-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, count, err := m.GetLeaderboard(tt.nType, 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != int64(len(tt.want)) || len(entries) != len(tt.want) {
+				t.Fatalf("%d entries of %d, want %d", len(entries), count, len(tt.want))
+			}
+			for i, e := range entries {
+				if e.NodeId != tt.want[i] || e.Rank != i+1 {
+					t.Errorf("entry %d: %s ranked %d, want %s", i, e.NodeId, e.Rank, tt.want[i])
+				}
+			}
+		})
+	}
+}
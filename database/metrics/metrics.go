@@ -1,105 +1,143 @@
 package metrics
 
 import (
+	"sync"
+	"time"
+
 	"github.com/celestiaorg/leaderboard-backend/receiver"
 	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/database/storage"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 type Metrics struct {
-	db          *gorm.DB
+	store       storage.Storage
+	db          *gorm.DB // the database behind the storage, for the queries beyond the storage interface
 	InsertQueue *InsertQueue
+	Hub         *Hub // the samples written by the insert queue are published here
+
+	// the window the uptime is computed for, a zero start time means it is not set
+	uptimeStartTime time.Time
+	uptimeEndTime   time.Time
+	uptimeScorer    UptimeScorer
+
+	heartbeatGapThreshold time.Duration
+
+	// nodes whose runtime checkpoints are behind
+	dirtyMu    sync.Mutex
+	dirtyNodes map[string]struct{}
+
+	// the oldest sample written since the rollups were last updated, zero if none
+	rollupsMu         sync.Mutex
+	rollupsDirtySince time.Time
+
+	// the versions sent by the nodes, for the version range filters
+	versionsMu       sync.Mutex
+	knownVersions    map[string]struct{}
+	versionsLoadedAt time.Time // zero until they are read from the database
 }
 
 const defaultLimit = 100
 
+// New uses the Postgres database opened by database.Init
 func New(db *gorm.DB) *Metrics {
+	return NewWithStorage(storage.NewPostgres(db))
+}
+
+func NewWithStorage(store storage.Storage) *Metrics {
 	m := &Metrics{
-		db: db,
+		store:        store,
+		db:           store.DB(),
+		uptimeScorer: MinUptimeScorer{},
+		dirtyNodes:   map[string]struct{}{},
+
+		knownVersions: map[string]struct{}{},
+
+		heartbeatGapThreshold: DefaultHeartbeatGapThreshold,
 	}
+	m.Hub = NewHub(DefaultSubscriberBuffer)
 	m.InsertQueue = NewInsertQueue(m)
 	return m
 }
 
-func (m *Metrics) AddNodeData(data *models.CelestiaNode) error {
-	tx := m.db.Create(data)
-	return tx.Error
+// SetUptimeWindow sets the period the uptime of the nodes is computed for,
+// a zero end time means the uptime is computed up to now
+func (m *Metrics) SetUptimeWindow(startTime, endTime time.Time) {
+	m.uptimeStartTime = startTime
+	m.uptimeEndTime = endTime
 }
 
-func (m *Metrics) FindByNodeId(nodeId string, offset, limit int) ([]models.CelestiaNode, int64, error) {
-
-	var res []models.CelestiaNode
-
-	var count int64
-	if limit == 0 {
-		limit = defaultLimit
-	}
-	tx := m.db.Model(&models.CelestiaNode{}).Where(&models.CelestiaNode{NodeId: nodeId}).Count(&count)
-	if tx.Error != nil {
-		return res, count, tx.Error
+// SetUptimeScorer sets the policy the uptime of the nodes is computed with
+func (m *Metrics) SetUptimeScorer(scorer UptimeScorer) {
+	if scorer != nil {
+		m.uptimeScorer = scorer
 	}
-
-	tx = m.db.Offset(offset).Limit(limit).
-		Where(&models.CelestiaNode{NodeId: nodeId}).Find(&res)
-	return res, count, tx.Error
 }
 
-func (m *Metrics) GetAllNodes(offset, limit int) ([]models.CelestiaNode, int64, error) {
-
-	var res []models.CelestiaNode
+func (m *Metrics) UptimeScorer() UptimeScorer {
+	return m.uptimeScorer
+}
 
-	var count int64
-	if limit == 0 {
-		limit = defaultLimit
+// SetHeartbeatGapThreshold sets how long a node may not send any metrics
+// before that period is considered as downtime
+func (m *Metrics) SetHeartbeatGapThreshold(threshold time.Duration) {
+	if threshold > 0 {
+		m.heartbeatGapThreshold = threshold
 	}
-	tx := m.db.Model(&models.CelestiaNode{}).Count(&count)
-	if tx.Error != nil {
-		return res, count, tx.Error
-	}
-
-	tx = m.db.Offset(offset).Limit(limit).
-		Order(clause.OrderByColumn{
-			Column: clause.Column{Name: "uptime"},
-			Desc:   true,
-		}).Find(&res)
-	return res, count, tx.Error
 }
 
-func (m *Metrics) GetNodesByType(nType receiver.NodeType, offset, limit int) ([]models.CelestiaNode, int64, error) {
+func (m *Metrics) HeartbeatGapThreshold() time.Duration {
+	return m.heartbeatGapThreshold
+}
 
-	var res []models.CelestiaNode
+func (m *Metrics) AddNodeData(data *models.CelestiaNode) error {
+	return m.AddNodeDataBatch([]*models.CelestiaNode{data})
+}
 
-	var count int64
-	if limit == 0 {
-		limit = defaultLimit
+// AddNodeDataBatch writes all the given rows in one transaction,
+// so either the whole batch is stored or none of it
+func (m *Metrics) AddNodeDataBatch(data []*models.CelestiaNode) error {
+	if len(data) == 0 {
+		return nil
 	}
-	tx := m.db.Model(&models.CelestiaNode{}).Where(&models.CelestiaNode{NodeType: nType}).Count(&count)
-	if tx.Error != nil {
-		return res, count, tx.Error
+
+	err := m.store.AddNodeData(data)
+	if err == nil {
+		m.markDirty(data)
+		m.markRollupsDirty(data)
+		m.markVersions(data)
 	}
+	return err
+}
 
-	tx = m.db.Offset(offset).Limit(limit).
-		Where(&models.CelestiaNode{NodeType: nType}).
-		Order(clause.OrderByColumn{
-			Column: clause.Column{Name: "uptime"},
-			Desc:   true,
-		}).Find(&res)
-	return res, count, tx.Error
+func (m *Metrics) FindByNodeId(nodeId string, offset, limit int) ([]models.CelestiaNode, int64, error) {
+	return m.store.FindByNodeId(nodeId, offset, limit)
 }
 
-func (m *Metrics) FindByNodeIdAtNetworkHeight(nodeId string, networkHeight uint64) ([]models.CelestiaNode, error) {
+func (m *Metrics) GetAllNodes(offset, limit int) ([]models.CelestiaNode, int64, error) {
+	return m.store.GetAllNodes(offset, limit)
+}
 
-	var res []models.CelestiaNode
+func (m *Metrics) GetNodesByType(nType receiver.NodeType, offset, limit int) ([]models.CelestiaNode, int64, error) {
+	return m.store.GetNodesByType(nType, offset, limit)
+}
 
-	tx := m.db.Where(&models.CelestiaNode{NodeId: nodeId, NetworkHeight: networkHeight}).Find(&res)
-	return res, tx.Error
+func (m *Metrics) FindByNodeIdAtNetworkHeight(nodeId string, networkHeight uint64) ([]models.CelestiaNode, error) {
+	return m.store.FindByNodeIdAtNetworkHeight(nodeId, networkHeight)
 }
 
 func (m *Metrics) FindByNodeIdAtNetworkHeightRange(nodeId string, networkHeightBegin, networkHeightEnd uint64) ([]models.CelestiaNode, error) {
+	return m.store.FindByNodeIdAtNetworkHeightRange(nodeId, networkHeightBegin, networkHeightEnd)
+}
+
+func (m *Metrics) GetNodesVersions(nodeId string) ([]models.NodeVersion, error) {
+	return m.store.GetNodesVersions(nodeId)
+}
 
-	var res []models.CelestiaNode
+func (m *Metrics) GetLatestNodeData(nodeId string) (models.CelestiaNode, error) {
+	return m.store.GetLatestNodeData(nodeId)
+}
 
-	tx := m.db.Where("node_id = ? AND ( network_height BETWEEN ? AND ? )", nodeId, networkHeightBegin, networkHeightEnd).Find(&res)
-	return res, tx.Error
+func (m *Metrics) getNetworkHeightAtTime(metricTime time.Time) (uint64, error) {
+	return m.store.GetNetworkHeightAtTime(metricTime)
 }
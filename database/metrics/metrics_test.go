@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/celestiaorg/nodelogger/database/storage"
+)
+
+// The cached queries are written in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "nodelogger-metrics")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newSQLiteMetrics gives metrics on top of a fresh SQLite database
+func newSQLiteMetrics(t *testing.T) *Metrics {
+	t.Helper()
+
+	store, err := storage.OpenSQLite(filepath.Join(t.TempDir(), "nodelogger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWithStorage(store)
+}
+
+// addSamples writes a sample of the node at every given time, in order,
+// with head i and network height 100+i for the i-th one
+func addSamples(t *testing.T, m *Metrics, nodeId string, times ...time.Time) {
+	t.Helper()
+
+	batch := make([]*models.CelestiaNode, 0, len(times))
+	for i, at := range times {
+		batch = append(batch, &models.CelestiaNode{
+			NodeId:        nodeId,
+			Head:          uint64(i),
+			NetworkHeight: uint64(100 + i),
+			CreatedAt:     at,
+		})
+	}
+	if err := m.AddNodeDataBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// every returns n times from begin, step apart
+func every(begin time.Time, step time.Duration, n int) []time.Time {
+	times := make([]time.Time, n)
+	for i := range times {
+		times[i] = begin.Add(time.Duration(i) * step)
+	}
+	return times
+}
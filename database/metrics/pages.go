@@ -0,0 +1,352 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"gorm.io/gorm"
+)
+
+// NodeFilter narrows down the listed samples, the zero value lists them all
+type NodeFilter struct {
+	NodeId           string // exact
+	NodeIdPrefix     string
+	NodeType         *receiver.NodeType
+	Version          string       // exact
+	VersionRange     VersionRange // nil for any version
+	MinUptime        *float64
+	MaxUptime        *float64
+	From             time.Time // created_at in [From, To), a zero time is unbounded
+	To               time.Time
+	MinHead          uint64
+	MinNetworkHeight uint64
+}
+
+// NodeSort is the order of the listed samples, the ties are broken by id in the same direction
+type NodeSort struct {
+	Column string
+	Desc   bool
+}
+
+var (
+	DefaultNodeSort   = NodeSort{Column: "uptime", Desc: true}     // the node rankings
+	DefaultNodeIdSort = NodeSort{Column: "created_at", Desc: true} // the samples of a node, newest first
+)
+
+type sortColumnKind int
+
+const (
+	sortUint sortColumnKind = iota
+	sortFloat
+	sortString
+	sortTime
+)
+
+// sortColumn is a column the samples can be sorted on, value reads it from a sample
+type sortColumn struct {
+	kind  sortColumnKind
+	value func(n *models.CelestiaNode) interface{}
+}
+
+var nodeSortColumns = map[string]sortColumn{
+	"id":                              {sortUint, func(n *models.CelestiaNode) interface{} { return uint64(n.ID) }},
+	"created_at":                      {sortTime, func(n *models.CelestiaNode) interface{} { return n.CreatedAt }},
+	"node_id":                         {sortString, func(n *models.CelestiaNode) interface{} { return n.NodeId }},
+	"node_type":                       {sortUint, func(n *models.CelestiaNode) interface{} { return uint64(n.NodeType) }},
+	"version":                         {sortString, func(n *models.CelestiaNode) interface{} { return n.Version }},
+	"uptime":                          {sortFloat, func(n *models.CelestiaNode) interface{} { return float64(n.Uptime) }}, // the way it is written, so the row of the key compares equal
+	"head":                            {sortUint, func(n *models.CelestiaNode) interface{} { return n.Head }},
+	"network_height":                  {sortUint, func(n *models.CelestiaNode) interface{} { return n.NetworkHeight }},
+	"pfb_count":                       {sortUint, func(n *models.CelestiaNode) interface{} { return n.PfbCount }},
+	"last_pfb_timestamp":              {sortTime, func(n *models.CelestiaNode) interface{} { return n.LastPfbTimestamp }},
+	"das_network_head":                {sortUint, func(n *models.CelestiaNode) interface{} { return n.DasNetworkHead }},
+	"das_sampled_chain_head":          {sortUint, func(n *models.CelestiaNode) interface{} { return n.DasSampledChainHead }},
+	"das_total_sampled_headers":       {sortUint, func(n *models.CelestiaNode) interface{} { return n.DasTotalSampledHeaders }},
+	"total_synced_headers":            {sortUint, func(n *models.CelestiaNode) interface{} { return n.TotalSyncedHeaders }},
+	"start_time":                      {sortTime, func(n *models.CelestiaNode) interface{} { return n.StartTime }},
+	"last_restart_time":               {sortTime, func(n *models.CelestiaNode) interface{} { return n.LastRestartTime }},
+	"node_runtime_counter_in_seconds": {sortUint, func(n *models.CelestiaNode) interface{} { return n.NodeRuntimeCounterInSeconds }},
+}
+
+// NodeSortColumns returns the columns the samples can be sorted on
+func NodeSortColumns() []string {
+	columns := make([]string, 0, len(nodeSortColumns))
+	for c := range nodeSortColumns {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// ParseNodeSort reads a sort column and an order, asc or desc, the order is desc if empty
+func ParseNodeSort(column, order string) (NodeSort, error) {
+
+	s := NodeSort{Column: strings.ToLower(strings.TrimSpace(column))}
+	if _, ok := nodeSortColumns[s.Column]; !ok {
+		return s, fmt.Errorf("unknown sort column %q, expected one of %s", column, strings.Join(NodeSortColumns(), ", "))
+	}
+
+	switch strings.ToLower(strings.TrimSpace(order)) {
+	case "", "desc":
+		s.Desc = true
+	case "asc":
+	default:
+		return s, fmt.Errorf("unknown sort order %q, expected asc or desc", order)
+	}
+
+	return s, nil
+}
+
+func (s NodeSort) String() string {
+	if s.Desc {
+		return s.Column + ":desc"
+	}
+	return s.Column + ":asc"
+}
+
+func (s NodeSort) order() string {
+	if s.Desc {
+		return fmt.Sprintf(`"%s" DESC, "id" DESC`, s.Column)
+	}
+	return fmt.Sprintf(`"%s" ASC, "id" ASC`, s.Column)
+}
+
+// PageKey is the sort key of the last row of a keyset page, the next page starts right after it
+type PageKey struct {
+	Value interface{} // of the sort column
+	ID    uint
+}
+
+// KeyOf returns the key the page after node starts from
+func (s NodeSort) KeyOf(node models.CelestiaNode) PageKey {
+	return PageKey{Value: nodeSortColumns[s.Column].value(&node), ID: node.ID}
+}
+
+// FormatKeyValue writes the value of a key so it can be carried by a cursor
+func (s NodeSort) FormatKeyValue(v interface{}) string {
+	switch v := v.(type) {
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// ParseKeyValue reads back a value written by FormatKeyValue
+func (s NodeSort) ParseKeyValue(str string) (interface{}, error) {
+
+	column, ok := nodeSortColumns[s.Column]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort column %q", s.Column)
+	}
+
+	switch column.kind {
+	case sortUint:
+		return strconv.ParseUint(str, 10, 64)
+	case sortFloat:
+		return strconv.ParseFloat(str, 64)
+	case sortTime:
+		return time.Parse(time.RFC3339Nano, str)
+	}
+	return str, nil
+}
+
+// nodesQuery selects the samples matching the filter
+func (m *Metrics) nodesQuery(filter NodeFilter) (*gorm.DB, error) {
+
+	tx := m.db.Model(&models.CelestiaNode{})
+
+	if filter.NodeId != "" {
+		tx = tx.Where(`"node_id" = ?`, filter.NodeId)
+	}
+	if filter.NodeIdPrefix != "" {
+		tx = tx.Where(`"node_id" LIKE ? ESCAPE '\'`, escapeLike(filter.NodeIdPrefix)+"%")
+	}
+	if filter.NodeType != nil {
+		tx = tx.Where(`"node_type" = ?`, *filter.NodeType)
+	}
+	if filter.Version != "" {
+		tx = tx.Where(`"version" = ?`, filter.Version)
+	}
+	if filter.VersionRange != nil {
+		versions, err := m.versionsIn(filter.VersionRange)
+		if err != nil {
+			return nil, err
+		}
+		tx = tx.Where(`"version" IN ?`, versions)
+	}
+	if filter.MinUptime != nil {
+		tx = tx.Where(`"uptime" >= ?`, *filter.MinUptime)
+	}
+	if filter.MaxUptime != nil {
+		tx = tx.Where(`"uptime" <= ?`, *filter.MaxUptime)
+	}
+	if !filter.From.IsZero() {
+		tx = tx.Where(`"created_at" >= ?`, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where(`"created_at" < ?`, filter.To.UTC())
+	}
+	if filter.MinHead > 0 {
+		tx = tx.Where(`"head" >= ?`, filter.MinHead)
+	}
+	if filter.MinNetworkHeight > 0 {
+		tx = tx.Where(`"network_height" >= ?`, filter.MinNetworkHeight)
+	}
+
+	return tx, nil
+}
+
+// versionsCacheTTL is how often the known versions are read again from the database,
+// for the samples written by another instance
+const versionsCacheTTL = 10 * time.Minute
+
+// versionsIn returns the versions sent by the nodes which are in the range,
+// there are few of them so the range is matched here rather than in SQL
+func (m *Metrics) versionsIn(r VersionRange) ([]string, error) {
+
+	all, err := m.getKnownVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for _, s := range all {
+		if v, err := ParseVersion(s); err == nil && r.Contains(v) {
+			versions = append(versions, s)
+		}
+	}
+	return versions, nil
+}
+
+// getKnownVersions returns the versions sent by the nodes. They are read from the whole history once,
+// then kept up to date by the writes, so a version range filter does not go through all the samples every time.
+func (m *Metrics) getKnownVersions() ([]string, error) {
+
+	m.versionsMu.Lock()
+	stale := m.versionsLoadedAt.IsZero() || time.Since(m.versionsLoadedAt) > versionsCacheTTL
+	m.versionsMu.Unlock()
+
+	if stale {
+		// not under the lock, the writes keep adding their versions meanwhile
+		var all []string
+		begin := time.Now()
+		if err := m.db.Model(&models.CelestiaNode{}).Distinct(`"version"`).Pluck(`"version"`, &all).Error; err != nil {
+			return nil, err
+		}
+
+		m.versionsMu.Lock()
+		for _, v := range all {
+			m.knownVersions[v] = struct{}{}
+		}
+		m.versionsLoadedAt = begin
+		m.versionsMu.Unlock()
+	}
+
+	m.versionsMu.Lock()
+	defer m.versionsMu.Unlock()
+
+	versions := make([]string, 0, len(m.knownVersions))
+	for v := range m.knownVersions {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// markVersions adds the versions of the written samples to the known ones.
+// The versions no sample has anymore are kept, they only match nothing.
+func (m *Metrics) markVersions(data []*models.CelestiaNode) {
+	m.versionsMu.Lock()
+	defer m.versionsMu.Unlock()
+
+	for _, d := range data {
+		m.knownVersions[d.Version] = struct{}{}
+	}
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ListNodes returns the samples matching the filter in the given order, starting right after the given key.
+// A nil after is the first page.
+func (m *Metrics) ListNodes(filter NodeFilter, s NodeSort, after *PageKey, limit int) ([]models.CelestiaNode, error) {
+
+	var res []models.CelestiaNode
+
+	if _, ok := nodeSortColumns[s.Column]; !ok {
+		return nil, fmt.Errorf("unknown sort column %q", s.Column)
+	}
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	tx, err := m.nodesQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		value := after.Value
+		if t, ok := value.(time.Time); ok {
+			value = t.UTC()
+		}
+		cmp := ">"
+		if s.Desc {
+			cmp = "<"
+		}
+		tx = tx.Where(fmt.Sprintf(`("%s" %s ? OR ("%s" = ? AND "id" %s ?))`, s.Column, cmp, s.Column, cmp), value, value, after.ID)
+	}
+
+	err = tx.Order(s.order()).Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// ListNodesByOffset is ListNodes for the clients paginating with page numbers, it returns the total count too
+func (m *Metrics) ListNodesByOffset(filter NodeFilter, s NodeSort, offset, limit int) ([]models.CelestiaNode, int64, error) {
+
+	var res []models.CelestiaNode
+
+	if _, ok := nodeSortColumns[s.Column]; !ok {
+		return nil, 0, fmt.Errorf("unknown sort column %q", s.Column)
+	}
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	count, err := m.CountNodes(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := m.nodesQuery(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = tx.Order(s.order()).Offset(offset).Limit(limit).Find(&res).Error
+	return res, count, err
+}
+
+// CountNodes returns the number of samples matching the filter
+func (m *Metrics) CountNodes(filter NodeFilter) (int64, error) {
+
+	var count int64
+
+	tx, err := m.nodesQuery(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func TestParseNodeSort(t *testing.T) {
+
+	tests := []struct {
+		column, order string
+		want          NodeSort
+		err           bool
+	}{
+		{column: "uptime", order: "", want: NodeSort{Column: "uptime", Desc: true}},
+		{column: "uptime", order: "desc", want: NodeSort{Column: "uptime", Desc: true}},
+		{column: " Head ", order: "ASC", want: NodeSort{Column: "head"}},
+		{column: "created_at", order: "asc", want: NodeSort{Column: "created_at"}},
+		{column: "uptime", order: "up", err: true},
+		{column: "id; DROP TABLE celestia_nodes", order: "", err: true},
+		{column: "", order: "asc", err: true},
+		{column: "das_sampled_headers_counter", order: "", err: true}, // exported but not sortable
+	}
+
+	for _, tt := range tests {
+		got, err := ParseNodeSort(tt.column, tt.order)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseNodeSort(%q, %q) error %v, want an error %v", tt.column, tt.order, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("ParseNodeSort(%q, %q) = %v, want %v", tt.column, tt.order, got, tt.want)
+		}
+	}
+}
+
+// nodeIds lists the node ids of the samples, sorted and without duplicates
+func nodeIds(nodes []models.CelestiaNode) string {
+	seen := map[string]bool{}
+	ids := []string{}
+	for _, n := range nodes {
+		if !seen[n.NodeId] {
+			seen[n.NodeId] = true
+			ids = append(ids, n.NodeId)
+		}
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func TestNodesQuery(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+	begin := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	light, full := receiver.LightNodeType, receiver.FullNodeType
+	err := m.AddNodeDataBatch([]*models.CelestiaNode{
+		{NodeId: "light-1", NodeType: light, Version: "v0.9.1", Uptime: 99, Head: 10, NetworkHeight: 110, CreatedAt: begin},
+		{NodeId: "light-2", NodeType: light, Version: "v0.10.0-rc1", Uptime: 50, Head: 20, NetworkHeight: 120, CreatedAt: begin.Add(time.Hour)},
+		{NodeId: "full-1", NodeType: full, Version: "v0.10.0", Uptime: 75, Head: 30, NetworkHeight: 130, CreatedAt: begin.Add(2 * time.Hour)},
+		{NodeId: "light_3", NodeType: light, Version: "v0.10.1", Uptime: 10, Head: 40, NetworkHeight: 140, CreatedAt: begin.Add(3 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minUptime, maxUptime := 50.0, 80.0
+	versionRange, err := ParseVersionRange(">=v0.10.0-rc1,<v0.10.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter NodeFilter
+		want   string
+	}{
+		{name: "all", filter: NodeFilter{}, want: "full-1,light-1,light-2,light_3"},
+		{name: "node id", filter: NodeFilter{NodeId: "light-2"}, want: "light-2"},
+		{name: "node id prefix", filter: NodeFilter{NodeIdPrefix: "light"}, want: "light-1,light-2,light_3"},
+		{name: "prefix with a LIKE wildcard", filter: NodeFilter{NodeIdPrefix: "light_"}, want: "light_3"},
+		{name: "prefix with a percent", filter: NodeFilter{NodeIdPrefix: "%"}, want: ""},
+		{name: "node type", filter: NodeFilter{NodeType: &full}, want: "full-1"},
+		{name: "exact version", filter: NodeFilter{Version: "v0.10.0"}, want: "full-1"},
+		{name: "version range", filter: NodeFilter{VersionRange: versionRange}, want: "full-1,light-2"},
+		{name: "uptime", filter: NodeFilter{MinUptime: &minUptime, MaxUptime: &maxUptime}, want: "full-1,light-2"},
+		{name: "from is inclusive, to exclusive", filter: NodeFilter{From: begin.Add(time.Hour), To: begin.Add(3 * time.Hour)}, want: "full-1,light-2"},
+		{name: "min head", filter: NodeFilter{MinHead: 30}, want: "full-1,light_3"},
+		{name: "min network height", filter: NodeFilter{MinNetworkHeight: 121}, want: "full-1,light_3"},
+		{name: "combined", filter: NodeFilter{NodeType: &light, MinHead: 15, MaxUptime: &maxUptime}, want: "light-2,light_3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			nodes, err := m.ListNodes(tt.filter, DefaultNodeSort, nil, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := nodeIds(nodes); got != tt.want {
+				t.Errorf("listed %q, want %q", got, tt.want)
+			}
+
+			count, err := m.CountNodes(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != int64(len(nodes)) {
+				t.Errorf("counted %d, listed %d", count, len(nodes))
+			}
+		})
+	}
+}
+
+// The versions written after the known versions were read are matched by the version range filters
+func TestVersionRangeNewVersions(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+	if err := m.AddNodeData(&models.CelestiaNode{NodeId: "old", Version: "v0.9.0", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ParseVersionRange(">=v0.9.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := NodeFilter{VersionRange: r}
+
+	if count, err := m.CountNodes(filter); err != nil || count != 1 {
+		t.Fatalf("%d samples in the range (err: %v), want 1", count, err)
+	}
+
+	if err := m.AddNodeData(&models.CelestiaNode{NodeId: "new", Version: "v0.11.0", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := m.CountNodes(filter); err != nil || count != 2 {
+		t.Errorf("%d samples in the range (err: %v), want 2 with the new version", count, err)
+	}
+
+	// a version written by another instance shows up once the cache expires
+	if err := m.db.Create(&models.CelestiaNode{NodeId: "other", Version: "v0.12.0", CreatedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	m.versionsLoadedAt = time.Now().Add(-2 * versionsCacheTTL)
+	if count, err := m.CountNodes(filter); err != nil || count != 3 {
+		t.Errorf("%d samples in the range (err: %v), want 3 once the versions were read again", count, err)
+	}
+}
@@ -0,0 +1,284 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+type Resolution string
+
+const (
+	ResolutionRaw  Resolution = "raw"
+	ResolutionHour Resolution = "hour"
+	ResolutionDay  Resolution = "day"
+
+	DefaultRollupInterval = 5 * time.Minute
+
+	// The rollups are computed this much time at a time, to keep the transactions short while catching up
+	rollupChunk = 24 * time.Hour
+
+	// Max number of raw samples returned by GetNodeRawHistory
+	MaxRawHistoryRows = 2000
+)
+
+func ParseResolution(resolution string) (Resolution, error) {
+	switch r := Resolution(resolution); r {
+	case ResolutionRaw, ResolutionHour, ResolutionDay:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown resolution %q, expected one of raw, hour, day", resolution)
+}
+
+// PickResolution picks a resolution which gives a few hundred points at most for the time range
+func PickResolution(from, to time.Time) Resolution {
+	switch span := to.Sub(from); {
+	case span <= 6*time.Hour:
+		return ResolutionRaw
+	case span <= 14*24*time.Hour:
+		return ResolutionHour
+	}
+	return ResolutionDay
+}
+
+func (r Resolution) duration() time.Duration {
+	if r == ResolutionDay {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+func (r Resolution) table() string {
+	if r == ResolutionDay {
+		return models.DailyNodeRollup{}.TableName()
+	}
+	return models.HourlyNodeRollup{}.TableName()
+}
+
+// UpdateRollups computes the rollups of the given resolution for the buckets overlapping [from, to)
+func (m *Metrics) UpdateRollups(resolution Resolution, from, to time.Time) error {
+
+	if resolution == ResolutionRaw {
+		return fmt.Errorf("raw samples are not rolled up")
+	}
+
+	from = from.UTC().Truncate(resolution.duration())
+	for start := from; start.Before(to); start = start.Add(rollupChunk) {
+		end := start.Add(rollupChunk)
+		if end.After(to) {
+			end = to
+		}
+		// whole buckets only, so a bucket is never computed from a part of its samples
+		end = end.Add(resolution.duration() - 1).Truncate(resolution.duration())
+
+		if err := m.updateRollups(resolution, start, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Metrics) updateRollups(resolution Resolution, from, to time.Time) error {
+
+	bucketStart := m.bucketStartSQL(resolution, `"created_at"`)
+
+	SQL := `
+		INSERT INTO "` + resolution.table() + `" (
+			"node_id", "bucket_start", "node_type", "samples", "online_seconds",
+			"head_min", "head_max", "head_last",
+			"network_height_min", "network_height_max", "network_height_last",
+			"das_sampled_chain_head_min", "das_sampled_chain_head_max", "das_sampled_chain_head_last",
+			"pfb_count_min", "pfb_count_max", "pfb_count_last",
+			"updated_at"
+		)
+		SELECT
+			"node_id",
+			"bucket_start",
+			MAX("last_node_type"),
+			COUNT(*),
+			` + runtimeGapsSumSQL + `,
+			MIN("head"), MAX("head"), MAX("last_head"),
+			MIN("network_height"), MAX("network_height"), MAX("last_network_height"),
+			MIN("das_sampled_chain_head"), MAX("das_sampled_chain_head"), MAX("last_das_sampled_chain_head"),
+			MIN("pfb_count"), MAX("pfb_count"), MAX("last_pfb_count"),
+			CURRENT_TIMESTAMP
+		FROM (
+		SELECT
+			"id",
+			"node_id",
+			` + bucketStart + ` AS "bucket_start",
+			"created_at",
+			"head",
+			"network_height",
+			"das_sampled_chain_head",
+			"pfb_count",
+			FIRST_VALUE("node_type") OVER latest AS "last_node_type",
+			FIRST_VALUE("head") OVER latest AS "last_head",
+			FIRST_VALUE("network_height") OVER latest AS "last_network_height",
+			FIRST_VALUE("das_sampled_chain_head") OVER latest AS "last_das_sampled_chain_head",
+			FIRST_VALUE("pfb_count") OVER latest AS "last_pfb_count",
+			` + m.secondsBetweenSQL(`"created_at"`, `LAG("created_at") OVER w`) + ` AS "time_gap_seconds",
+			LAG("network_height") OVER w AS "prev_network_height"
+		FROM "celestia_nodes"
+		WHERE
+			"created_at" >= ?
+			AND "created_at" < ?
+			AND "deleted_at" IS NULL
+		WINDOW
+			w AS (PARTITION BY "node_id" ORDER BY "id"),
+			latest AS (PARTITION BY "node_id", ` + bucketStart + ` ORDER BY "id" DESC)
+		) AS subquery
+		WHERE "created_at" >= ?
+		GROUP BY "node_id", "bucket_start"
+		ON CONFLICT ("node_id", "bucket_start") DO UPDATE SET
+			"node_type" = EXCLUDED."node_type",
+			"samples" = EXCLUDED."samples",
+			"online_seconds" = EXCLUDED."online_seconds",
+			"head_min" = EXCLUDED."head_min",
+			"head_max" = EXCLUDED."head_max",
+			"head_last" = EXCLUDED."head_last",
+			"network_height_min" = EXCLUDED."network_height_min",
+			"network_height_max" = EXCLUDED."network_height_max",
+			"network_height_last" = EXCLUDED."network_height_last",
+			"das_sampled_chain_head_min" = EXCLUDED."das_sampled_chain_head_min",
+			"das_sampled_chain_head_max" = EXCLUDED."das_sampled_chain_head_max",
+			"das_sampled_chain_head_last" = EXCLUDED."das_sampled_chain_head_last",
+			"pfb_count_min" = EXCLUDED."pfb_count_min",
+			"pfb_count_max" = EXCLUDED."pfb_count_max",
+			"pfb_count_last" = EXCLUDED."pfb_count_last",
+			"updated_at" = EXCLUDED."updated_at"`
+
+	// The samples right before the window are read as well, so the gap to the first sample of the window is counted
+	lookback := from.Add(-m.heartbeatGapThreshold)
+	return m.db.Exec(SQL, m.heartbeatGapThreshold.Seconds(), m.timeArg(lookback), m.timeArg(to), m.timeArg(from)).Error
+}
+
+// rollupsStart returns where the rollups of a resolution should be computed from:
+// one bucket before the latest one, since the latest buckets may still get samples,
+// or the first sample if there is no rollup yet
+func (m *Metrics) rollupsStart(resolution Resolution) (time.Time, error) {
+
+	var latest []models.NodeRollup
+	tx := m.db.Table(resolution.table()).Select(`"bucket_start"`).Order(`"bucket_start" DESC`).Limit(1).Find(&latest)
+	if tx.Error != nil {
+		return time.Time{}, tx.Error
+	}
+	if len(latest) > 0 {
+		return latest[0].BucketStart.Add(-resolution.duration()), nil
+	}
+
+	var first []models.CelestiaNode
+	tx = m.db.Select(`"created_at"`).Order(`"created_at" ASC`).Limit(1).Find(&first)
+	if tx.Error != nil {
+		return time.Time{}, tx.Error
+	}
+	if len(first) > 0 {
+		return first[0].CreatedAt, nil
+	}
+
+	return time.Now(), nil
+}
+
+// markRollupsDirty moves the rollups watermark back to the oldest of the written samples,
+// a late sample, e.g. replayed from the spool, lands in a bucket already rolled up
+func (m *Metrics) markRollupsDirty(data []*models.CelestiaNode) {
+	m.rollupsMu.Lock()
+	defer m.rollupsMu.Unlock()
+
+	for _, d := range data {
+		if !d.CreatedAt.IsZero() && (m.rollupsDirtySince.IsZero() || d.CreatedAt.Before(m.rollupsDirtySince)) {
+			m.rollupsDirtySince = d.CreatedAt
+		}
+	}
+}
+
+// takeRollupsDirty returns the rollups watermark and resets it
+func (m *Metrics) takeRollupsDirty() time.Time {
+	m.rollupsMu.Lock()
+	defer m.rollupsMu.Unlock()
+
+	since := m.rollupsDirtySince
+	m.rollupsDirtySince = time.Time{}
+	return since
+}
+
+// updateAllRollups brings the hourly and daily rollups up to date, from the latest rollups
+// or from the oldest sample written since the last update if it is older
+func (m *Metrics) updateAllRollups(now time.Time) {
+
+	dirtySince := m.takeRollupsDirty()
+
+	failed := false
+	for _, resolution := range []Resolution{ResolutionHour, ResolutionDay} {
+		from, err := m.rollupsStart(resolution)
+		if err == nil {
+			if !dirtySince.IsZero() && dirtySince.Before(from) {
+				from = dirtySince
+			}
+			err = m.UpdateRollups(resolution, from, now)
+		}
+		if err != nil {
+			log.Printf("%s rollups: %v\n", resolution, err)
+			failed = true
+		}
+	}
+
+	// try again from there next time
+	if failed && !dirtySince.IsZero() {
+		m.markRollupsDirty([]*models.CelestiaNode{{CreatedAt: dirtySince}})
+	}
+}
+
+// RunRollups keeps the hourly and daily rollups up to date until ctx is done,
+// catching up from the latest rollups first
+func (m *Metrics) RunRollups(ctx context.Context, interval time.Duration) {
+
+	if interval <= 0 {
+		interval = DefaultRollupInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.updateAllRollups(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetNodeRollups returns the rollups of a node with the buckets starting in [from, to)
+func (m *Metrics) GetNodeRollups(nodeId string, resolution Resolution, from, to time.Time) ([]models.NodeRollup, error) {
+
+	var res []models.NodeRollup
+
+	SQL := `
+		SELECT *
+		FROM "` + resolution.table() + `"
+		WHERE
+			"node_id" = ?
+			AND "bucket_start" >= ?
+			AND "bucket_start" < ?
+		ORDER BY "bucket_start" ASC`
+	err := database.Query(m.db, SQL, &res, nodeId, m.timeArg(from.UTC().Truncate(resolution.duration())), m.timeArg(to))
+	return res, err
+}
+
+// GetNodeRawHistory returns the raw samples of a node in [from, to), MaxRawHistoryRows at most
+func (m *Metrics) GetNodeRawHistory(nodeId string, from, to time.Time) ([]models.CelestiaNode, error) {
+
+	var res []models.CelestiaNode
+
+	tx := m.db.Where(`"node_id" = ? AND "created_at" >= ? AND "created_at" < ?`, nodeId, m.timeArg(from), m.timeArg(to)).
+		Order(`"id" ASC`).Limit(MaxRawHistoryRows).Find(&res)
+	return res, tx.Error
+}
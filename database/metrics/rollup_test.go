@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func TestRollupsOnSQLite(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	// 40 samples 30s apart from 10:50 to 11:09:30, a bridge node turned into a full node at 11:00
+	begin := time.Date(2024, 5, 1, 10, 50, 0, 0, time.UTC)
+	addSamples(t, m, "node-1", every(begin, 30*time.Second, 40)...)
+	if err := m.db.Model(&models.CelestiaNode{}).Where(`"head" >= 20`).Update("node_type", receiver.FullNodeType).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	from, to := begin.Truncate(time.Hour), begin.Add(2*time.Hour)
+	for _, resolution := range []Resolution{ResolutionHour, ResolutionDay} {
+		if err := m.UpdateRollups(resolution, from, to); err != nil {
+			t.Fatalf("%s rollups: %v", resolution, err)
+		}
+	}
+
+	hourly, err := m.GetNodeRollups("node-1", ResolutionHour, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hourly) != 2 {
+		t.Fatalf("%d hourly rollups, want 2: %+v", len(hourly), hourly)
+	}
+
+	tests := []struct {
+		got           models.NodeRollup
+		start         time.Time
+		samples       int64
+		onlineSeconds int64
+		headMin       uint64
+		headMax       uint64
+		nodeType      receiver.NodeType
+	}{
+		{got: hourly[0], start: from, samples: 20, onlineSeconds: 19 * 30, headMin: 0, headMax: 19, nodeType: receiver.BridgeNodeType},
+		// the gap from the last sample of the previous hour is counted
+		{got: hourly[1], start: from.Add(time.Hour), samples: 20, onlineSeconds: 20 * 30, headMin: 20, headMax: 39, nodeType: receiver.FullNodeType},
+	}
+	for i, tt := range tests {
+		r := tt.got
+		if !r.BucketStart.Equal(tt.start) || r.Samples != tt.samples || r.OnlineSeconds != tt.onlineSeconds ||
+			r.HeadMin != tt.headMin || r.HeadMax != tt.headMax || r.HeadLast != tt.headMax ||
+			r.NetworkHeightLast != 100+tt.headMax || r.NodeType != tt.nodeType {
+			t.Errorf("hourly rollup %d: %+v, want %+v", i, r, tt)
+		}
+	}
+
+	daily, err := m.GetNodeRollups("node-1", ResolutionDay, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 1 || daily[0].Samples != 40 || daily[0].OnlineSeconds != 39*30 || daily[0].HeadLast != 39 {
+		t.Errorf("daily rollups %+v, want one with the 40 samples", daily)
+	}
+
+	// computing them again gives the same rollups
+	if err := m.UpdateRollups(ResolutionHour, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := m.GetNodeRollups("node-1", ResolutionHour, from, to); len(again) != 2 || again[1].Samples != 20 {
+		t.Errorf("hourly rollups computed again %+v", again)
+	}
+
+	start, err := m.rollupsStart(ResolutionHour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !start.Equal(from) {
+		t.Errorf("hourly rollups start at %v, want one bucket before the latest one %v", start, from)
+	}
+}
+
+// A late sample, older than the latest rollups, gets its bucket rolled up again
+func TestRollupsLateSamples(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	now := time.Now().UTC()
+	begin := now.Truncate(time.Hour).Add(-3 * time.Hour)
+	addSamples(t, m, "node-1", every(begin.Add(30*time.Minute), 30*time.Second, 10)...)
+	addSamples(t, m, "node-1", every(begin.Add(3*time.Hour), 30*time.Second, 1)...)
+
+	m.updateAllRollups(now)
+	if m.takeRollupsDirty() != (time.Time{}) {
+		t.Error("the watermark is not reset by the update")
+	}
+	rollups, err := m.GetNodeRollups("node-1", ResolutionHour, begin, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) != 2 || rollups[0].Samples != 10 {
+		t.Fatalf("hourly rollups %+v, want 10 samples in the first one", rollups)
+	}
+
+	// two hours behind the latest bucket, out of the buckets computed again by default
+	addSamples(t, m, "node-2", begin.Add(40*time.Minute))
+	m.updateAllRollups(now)
+
+	rollups, err = m.GetNodeRollups("node-2", ResolutionHour, begin, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) != 1 || !rollups[0].BucketStart.Equal(begin) || rollups[0].Samples != 1 {
+		t.Errorf("hourly rollups of the late node %+v, want one at %v", rollups, begin)
+	}
+}
@@ -0,0 +1,288 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// The time when there is no metrics (heartbeat) for longer than this, we consider the node down
+	DefaultHeartbeatGapThreshold = 100 * time.Second
+
+	DefaultRuntimeCheckpointInterval = 30 * time.Second
+
+	checkpointChunkSize = 500
+)
+
+// Sums up the gaps between consecutive samples of a node, a gap is counted as runtime
+// only if it is shorter than the heartbeat threshold and the node reported a network height before it
+const runtimeGapsSumSQL = `
+	COALESCE(CAST(ROUND(SUM(
+		CASE WHEN "time_gap_seconds" > 0 AND "time_gap_seconds" < ? AND "prev_network_height" > 0
+		THEN "time_gap_seconds" ELSE 0 END
+	)) AS BIGINT), 0)`
+
+// UpdateRuntimeCheckpoints extends the runtime checkpoints of the given nodes with their new rows.
+// If no node is given, all the nodes are updated.
+func (m *Metrics) UpdateRuntimeCheckpoints(nodeIds []string) error {
+
+	if nodeIds == nil {
+		SQL := `SELECT DISTINCT "node_id" FROM "celestia_nodes"`
+		if err := database.Query(m.db, SQL, &nodeIds); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(nodeIds); start += checkpointChunkSize {
+		end := start + checkpointChunkSize
+		if end > len(nodeIds) {
+			end = len(nodeIds)
+		}
+		if err := m.updateRuntimeCheckpoints(nodeIds[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Metrics) updateRuntimeCheckpoints(nodeIds []string) error {
+
+	SQL := `
+		INSERT INTO "node_runtime_checkpoints"
+			("node_id", "last_row_id", "last_created_at", "last_network_height", "runtime", "gap_threshold", "updated_at")
+		SELECT
+			"node_id",
+			MAX("id"),
+			MAX("created_at"),
+			MAX("last_network_height"),
+			MAX("prev_runtime") + ` + runtimeGapsSumSQL + `,
+			?,
+			CURRENT_TIMESTAMP
+		FROM (
+		SELECT
+			n."id",
+			n."node_id",
+			n."created_at",
+			FIRST_VALUE(n."network_height") OVER latest AS "last_network_height",
+			COALESCE(cp."runtime", 0) AS "prev_runtime",
+			` + m.secondsBetweenSQL(`n."created_at"`, `COALESCE(LAG(n."created_at") OVER w, cp."last_created_at")`) + ` AS "time_gap_seconds",
+			COALESCE(LAG(n."network_height") OVER w, cp."last_network_height") AS "prev_network_height"
+		FROM
+			"celestia_nodes" n
+			LEFT JOIN "node_runtime_checkpoints" cp ON cp."node_id" = n."node_id" AND cp."gap_threshold" = ?
+		WHERE
+			n."node_id" IN ?
+			AND n."id" > COALESCE(cp."last_row_id", 0)
+			AND n."deleted_at" IS NULL
+		WINDOW
+			w AS (PARTITION BY n."node_id" ORDER BY n."id"),
+			latest AS (PARTITION BY n."node_id" ORDER BY n."id" DESC)
+		) AS subquery
+		WHERE TRUE
+		GROUP BY "node_id"
+		ON CONFLICT ("node_id") DO UPDATE SET
+			"last_row_id" = EXCLUDED."last_row_id",
+			"last_created_at" = EXCLUDED."last_created_at",
+			"last_network_height" = EXCLUDED."last_network_height",
+			"runtime" = EXCLUDED."runtime",
+			"gap_threshold" = EXCLUDED."gap_threshold",
+			"updated_at" = EXCLUDED."updated_at"
+		WHERE
+			"node_runtime_checkpoints"."last_row_id" < EXCLUDED."last_row_id"
+			OR "node_runtime_checkpoints"."gap_threshold" != EXCLUDED."gap_threshold"`
+
+	// A checkpoint computed with another threshold is ignored, so the runtime is computed from scratch
+	threshold := m.heartbeatGapThreshold.Seconds()
+	return m.db.Exec(SQL, threshold, threshold, threshold, nodeIds).Error
+}
+
+func (m *Metrics) GetRuntimeCheckpoint(nodeId string) (models.NodeRuntimeCheckpoint, error) {
+
+	var cp models.NodeRuntimeCheckpoint
+	tx := m.db.Where(&models.NodeRuntimeCheckpoint{NodeId: nodeId}).First(&cp)
+	return cp, tx.Error
+}
+
+// runtimeUntil returns the runtime of a node in seconds, counting the samples created before endTime.
+// It starts from the node checkpoint if the checkpoint is not beyond endTime,
+// otherwise it goes through the whole history of the node.
+func (m *Metrics) runtimeUntil(nodeId string, endTime time.Time) (int64, error) {
+
+	cp, err := m.GetRuntimeCheckpoint(nodeId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	if err != nil || cp.LastCreatedAt.After(endTime) || cp.GapThreshold != m.heartbeatGapThreshold.Seconds() {
+		cp = models.NodeRuntimeCheckpoint{NodeId: nodeId}
+	}
+
+	var lastCreatedAt interface{}
+	if !cp.LastCreatedAt.IsZero() {
+		lastCreatedAt = m.timeArg(cp.LastCreatedAt)
+	}
+
+	SQL := `
+		SELECT ` + runtimeGapsSumSQL + ` AS "runtime"
+		FROM (
+		SELECT
+			` + m.secondsBetweenSQL(`"created_at"`, `COALESCE(LAG("created_at") OVER w, ?)`) + ` AS "time_gap_seconds",
+			COALESCE(LAG("network_height") OVER w, ?) AS "prev_network_height"
+		FROM "celestia_nodes"
+		WHERE
+			"node_id" = ?
+			AND "id" > ?
+			AND "created_at" < ?
+			AND "deleted_at" IS NULL
+		WINDOW w AS (ORDER BY "id")
+		) AS subquery`
+	args := []interface{}{m.heartbeatGapThreshold.Seconds(), lastCreatedAt, cp.LastNetworkHeight, nodeId, cp.LastRowId, m.timeArg(endTime)}
+
+	var rows []struct{ Runtime int64 }
+	if cp.LastRowId == 0 && endTime.Before(time.Now()) {
+		// The whole history before a past time does not change anymore
+		err = database.CachedQuery(m.db, SQL, &rows, args...)
+	} else {
+		err = database.Query(m.db, SQL, &rows, args...)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return cp.Runtime, nil
+	}
+
+	return cp.Runtime + rows[0].Runtime, nil
+}
+
+// markDirty keeps track of the nodes which got new rows since their checkpoints were updated
+func (m *Metrics) markDirty(data []*models.CelestiaNode) {
+	m.dirtyMu.Lock()
+	defer m.dirtyMu.Unlock()
+
+	for _, d := range data {
+		m.dirtyNodes[d.NodeId] = struct{}{}
+	}
+}
+
+func (m *Metrics) takeDirty() []string {
+	m.dirtyMu.Lock()
+	defer m.dirtyMu.Unlock()
+
+	nodeIds := make([]string, 0, len(m.dirtyNodes))
+	for nodeId := range m.dirtyNodes {
+		nodeIds = append(nodeIds, nodeId)
+	}
+	m.dirtyNodes = map[string]struct{}{}
+
+	return nodeIds
+}
+
+// RunRuntimeCheckpointer brings all the runtime checkpoints up to date,
+// then keeps updating the checkpoints of the nodes that receive new samples until ctx is done
+func (m *Metrics) RunRuntimeCheckpointer(ctx context.Context, interval time.Duration) {
+
+	if interval <= 0 {
+		interval = DefaultRuntimeCheckpointInterval
+	}
+
+	begin := time.Now()
+	if err := m.UpdateRuntimeCheckpoints(nil); err != nil {
+		log.Printf("runtime checkpoints: %v\n", err)
+	} else {
+		log.Printf("runtime checkpoints are up to date in %v\n", time.Since(begin))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		nodeIds := m.takeDirty()
+		if len(nodeIds) == 0 {
+			continue
+		}
+		if err := m.UpdateRuntimeCheckpoints(nodeIds); err != nil {
+			log.Printf("runtime checkpoints of %d nodes: %v\n", len(nodeIds), err)
+
+			// try them again next time
+			m.dirtyMu.Lock()
+			for _, nodeId := range nodeIds {
+				m.dirtyNodes[nodeId] = struct{}{}
+			}
+			m.dirtyMu.Unlock()
+		}
+	}
+}
+
+// GetNodeGaps lists the periods in [from, to) the node has not sent any metrics for longer
+// than the heartbeat gap threshold, i.e. the periods that are counted as downtime.
+// A zero to means up to now.
+func (m *Metrics) GetNodeGaps(nodeId string, from, to time.Time) ([]models.NodeGap, error) {
+
+	var rows []models.NodeGap
+
+	now := time.Now()
+	upToNow := to.IsZero() || !to.Before(now)
+	if to.IsZero() {
+		to = now
+	}
+
+	// The times of the gaps are read from the samples themselves, SQLite loses the type of the times out of a window function
+	SQL := `
+		SELECT p."created_at" AS "start", n."created_at" AS "end", g."duration_seconds"
+		FROM (
+		SELECT
+			"id",
+			LAG("id") OVER w AS "prev_id",
+			` + m.secondsBetweenSQL(`"created_at"`, `LAG("created_at") OVER w`) + ` AS "duration_seconds"
+		FROM "celestia_nodes"
+		WHERE
+			"node_id" = ?
+			AND "created_at" >= ?
+			AND "created_at" < ?
+			AND "deleted_at" IS NULL
+		WINDOW w AS (ORDER BY "id")
+		) AS g
+		JOIN "celestia_nodes" p ON p."id" = g."prev_id" AND p."created_at" >= ? AND p."created_at" < ?
+		JOIN "celestia_nodes" n ON n."id" = g."id" AND n."created_at" >= ? AND n."created_at" < ?
+		WHERE g."duration_seconds" >= ?
+		ORDER BY p."created_at" ASC`
+
+	fromArg, toArg := m.timeArg(from), m.timeArg(to)
+	threshold := m.heartbeatGapThreshold.Seconds()
+	if err := database.Query(m.db, SQL, &rows, nodeId, fromArg, toArg, fromArg, toArg, fromArg, toArg, threshold); err != nil {
+		return rows, err
+	}
+
+	// The node may still be down, if the window reaches the present
+	if !upToNow {
+		return rows, nil
+	}
+
+	latest, err := m.GetLatestNodeData(nodeId)
+	if err != nil {
+		return rows, err
+	}
+	if !latest.CreatedAt.Before(from) && now.Sub(latest.CreatedAt) >= m.heartbeatGapThreshold {
+		rows = append(rows, models.NodeGap{
+			Start:           latest.CreatedAt,
+			End:             now,
+			DurationSeconds: now.Sub(latest.CreatedAt).Seconds(),
+			Ongoing:         true,
+		})
+	}
+
+	return rows, nil
+}
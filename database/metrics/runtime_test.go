@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRuntimeOnSQLite(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	// 10 samples 30s apart, a gap of 10 minutes, then 5 more samples
+	begin := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+	times := append(every(begin, 30*time.Second, 10), every(begin.Add(870*time.Second), 30*time.Second, 5)...)
+	addSamples(t, m, "node-1", times...)
+	addSamples(t, m, "node-2", every(begin, time.Minute, 3)...)
+
+	// 9 + 4 gaps of 30s, the gap of 10 minutes is longer than the heartbeat threshold
+	const want = 13 * 30
+
+	runtime, err := m.runtimeUntil("node-1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime != want {
+		t.Errorf("runtime without a checkpoint %d, want %d", runtime, want)
+	}
+
+	if err := m.UpdateRuntimeCheckpoints(nil); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := m.GetRuntimeCheckpoint("node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Runtime != want || cp.LastNetworkHeight != 114 || !cp.LastCreatedAt.Equal(times[len(times)-1]) {
+		t.Errorf("checkpoint %+v, want a runtime of %d up to the sample at %v with the network height 114", cp, want, times[len(times)-1])
+	}
+	if cp, err := m.GetRuntimeCheckpoint("node-2"); err != nil || cp.Runtime != 120 {
+		t.Errorf("checkpoint of node-2 %+v, want a runtime of 120 (err: %v)", cp, err)
+	}
+
+	// the checkpoint is extended with the new samples only
+	next := times[len(times)-1].Add(30 * time.Second)
+	addSamples(t, m, "node-1", next, next.Add(30*time.Second))
+	if err := m.UpdateRuntimeCheckpoints([]string{"node-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if cp, _ := m.GetRuntimeCheckpoint("node-1"); cp.Runtime != want+60 {
+		t.Errorf("extended checkpoint runtime %d, want %d", cp.Runtime, want+60)
+	}
+
+	runtime, err = m.runtimeUntil("node-1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime != want+60 {
+		t.Errorf("runtime from the checkpoint %d, want %d", runtime, want+60)
+	}
+}
+
+func TestNodeGapsOnSQLite(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	begin := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+	times := append(every(begin, 30*time.Second, 10), every(begin.Add(870*time.Second), 30*time.Second, 5)...)
+	addSamples(t, m, "node-1", times...)
+
+	gaps, err := m.GetNodeGaps("node-1", begin.Add(-time.Minute), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 2 {
+		t.Fatalf("%d gaps, want 2: %+v", len(gaps), gaps)
+	}
+
+	if !gaps[0].Start.Equal(times[9]) || !gaps[0].End.Equal(times[10]) || gaps[0].DurationSeconds != 600 || gaps[0].Ongoing {
+		t.Errorf("gap %+v, want the 600s from %v to %v", gaps[0], times[9], times[10])
+	}
+	if !gaps[1].Ongoing || !gaps[1].Start.Equal(times[14]) {
+		t.Errorf("gap %+v, want an ongoing gap from %v", gaps[1], times[14])
+	}
+
+	// a window ending in the past has no ongoing gap
+	gaps, err = m.GetNodeGaps("node-1", begin, times[12])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 1 {
+		t.Errorf("%d gaps in a past window, want 1: %+v", len(gaps), gaps)
+	}
+}
+
+func TestRecomputeUptimeOnSQLite(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	begin := time.Now().Add(-time.Hour).Truncate(time.Second)
+	addSamples(t, m, "node-1", every(begin, 30*time.Second, 20)...)
+	addSamples(t, m, "node-2", every(begin, 30*time.Second, 5)...)
+
+	// node-2 stops before the end of the window, it has no sample to score and is skipped
+	end := begin.Add(5 * time.Minute)
+	nodes, err := m.RecomputeUptimeForAll(begin, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].NodeId != "node-1" {
+		t.Fatalf("nodes %+v, want node-1 only", nodes)
+	}
+
+	// only the samples before the end of the window are counted, with the first sample after it
+	n := nodes[0]
+	if n.LastAccumulativeNodeRuntimeCounterInSeconds != 9*30 || n.NodeRuntimeCounterInSeconds != 0 {
+		t.Errorf("runtime %d, want %d", n.LastAccumulativeNodeRuntimeCounterInSeconds, 9*30)
+	}
+	if !n.CreatedAt.Equal(end) {
+		t.Errorf("sample at %v, want the one at %v", n.CreatedAt, end)
+	}
+}
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// UptimeInput is everything a scorer gets to compute the uptime of a node
+type UptimeInput struct {
+	Node          models.CelestiaNode // the latest sample of the node in the window
+	Runtime       uint64              // seconds the node has been up
+	NetworkHeight uint64
+	StartTime     time.Time // the uptime window
+	EndTime       time.Time
+}
+
+// UptimeScorer computes the uptime of a node as a percentage
+type UptimeScorer interface {
+	Score(in UptimeInput) float32
+	// Name describes the scorer in the same format ParseUptimeScorer accepts
+	Name() string
+}
+
+var (
+	_ UptimeScorer = MinUptimeScorer{}
+	_ UptimeScorer = TimeUptimeScorer{}
+	_ UptimeScorer = SyncUptimeScorer{}
+	_ UptimeScorer = WeightedUptimeScorer{}
+	_ UptimeScorer = PerNodeTypeUptimeScorer{}
+)
+
+// syncRatio is the share of the network blocks the node has synced or sampled
+func syncRatio(in UptimeInput) float64 {
+
+	totalSyncedBlocks := in.Node.DasTotalSampledHeaders // full & light nodes
+	if in.Node.NodeType == receiver.BridgeNodeType {
+		totalSyncedBlocks = in.Node.Head
+	}
+
+	if in.NetworkHeight == 0 {
+		return 0
+	}
+	return clampRatio(float64(totalSyncedBlocks) / float64(in.NetworkHeight))
+}
+
+// timeRatio is the share of the time the node has been up since it started
+func timeRatio(in UptimeInput) float64 {
+
+	// for nodes that started late
+	nodeStartTime := in.Node.StartTime
+	if nodeStartTime.After(in.StartTime) {
+		nodeStartTime = in.StartTime
+	}
+
+	elapsed := in.EndTime.Unix() - nodeStartTime.Unix()
+	if elapsed <= 0 {
+		return 0
+	}
+	return clampRatio(float64(in.Runtime) / float64(elapsed))
+}
+
+// clampRatio keeps a ratio in [0, 1], a node may report more blocks or runtime than the window holds
+func clampRatio(r float64) float64 {
+	if r < 0 {
+		return 0
+	}
+	if r > 1 {
+		return 1
+	}
+	return r
+}
+
+// MinUptimeScorer is the default policy, the node is as good as the worse of its sync and time ratios
+type MinUptimeScorer struct{}
+
+func (MinUptimeScorer) Name() string { return "min" }
+
+func (MinUptimeScorer) Score(in UptimeInput) float32 {
+
+	syncUptime := syncRatio(in)
+	tsUptime := timeRatio(in)
+
+	if syncUptime < tsUptime || in.Node.StartTime.IsZero() {
+		return float32(100 * syncUptime)
+	}
+	return float32(100 * tsUptime)
+
+	// ref: uptime = minimum(total_block_sampled_or_synced/network_head, total_node_uptime_in_seconds/(current_time-node_Start_time))
+}
+
+// TimeUptimeScorer only counts the time the node has been up
+type TimeUptimeScorer struct{}
+
+func (TimeUptimeScorer) Name() string { return "time" }
+
+func (TimeUptimeScorer) Score(in UptimeInput) float32 {
+	return float32(100 * timeRatio(in))
+}
+
+// SyncUptimeScorer only counts the blocks the node has synced or sampled
+type SyncUptimeScorer struct{}
+
+func (SyncUptimeScorer) Name() string { return "sync" }
+
+func (SyncUptimeScorer) Score(in UptimeInput) float32 {
+	return float32(100 * syncRatio(in))
+}
+
+// WeightedUptimeScorer blends the sync and time ratios, SyncWeight is in [0, 1]
+// and the time ratio gets the rest of the weight
+type WeightedUptimeScorer struct {
+	SyncWeight float64
+}
+
+func (w WeightedUptimeScorer) Name() string {
+	return "weighted:" + strconv.FormatFloat(w.SyncWeight, 'f', -1, 64)
+}
+
+func (w WeightedUptimeScorer) Score(in UptimeInput) float32 {
+	return float32(100 * (w.SyncWeight*syncRatio(in) + (1-w.SyncWeight)*timeRatio(in)))
+}
+
+// PerNodeTypeUptimeScorer picks a scorer by the node type,
+// the types not listed are scored by Default
+type PerNodeTypeUptimeScorer struct {
+	ByType  map[receiver.NodeType]UptimeScorer
+	Default UptimeScorer
+}
+
+func (p PerNodeTypeUptimeScorer) Name() string {
+
+	parts := []string{}
+	for name, nType := range nodeTypesByName {
+		if s, ok := p.ByType[nType]; ok {
+			parts = append(parts, name+"="+s.Name())
+		}
+	}
+	sort.Strings(parts)
+
+	return "per-node-type:" + strings.Join(parts, ",")
+}
+
+func (p PerNodeTypeUptimeScorer) Score(in UptimeInput) float32 {
+	if s, ok := p.ByType[in.Node.NodeType]; ok {
+		return s.Score(in)
+	}
+	if p.Default != nil {
+		return p.Default.Score(in)
+	}
+	return MinUptimeScorer{}.Score(in)
+}
+
+var nodeTypesByName = map[string]receiver.NodeType{
+	"bridge": receiver.BridgeNodeType,
+	"full":   receiver.FullNodeType,
+	"light":  receiver.LightNodeType,
+}
+
+// ParseUptimeScorer builds a scorer from its description:
+//
+//	min | time | sync
+//	weighted:<sync weight>                       e.g. weighted:0.7
+//	per-node-type:<type>=<scorer>,...            e.g. per-node-type:bridge=sync,light=weighted:0.5
+//
+// An empty description gives the default scorer.
+func ParseUptimeScorer(spec string) (UptimeScorer, error) {
+
+	spec = strings.TrimSpace(spec)
+	name, arg, _ := strings.Cut(spec, ":")
+
+	switch name {
+	case "", "min":
+		return MinUptimeScorer{}, nil
+
+	case "time":
+		return TimeUptimeScorer{}, nil
+
+	case "sync":
+		return SyncUptimeScorer{}, nil
+
+	case "weighted":
+		weight, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("uptime scorer %q: sync weight: %v", spec, err)
+		}
+		if weight < 0 || weight > 1 {
+			return nil, fmt.Errorf("uptime scorer %q: sync weight must be between 0 and 1", spec)
+		}
+		return WeightedUptimeScorer{SyncWeight: weight}, nil
+
+	case "per-node-type":
+		p := PerNodeTypeUptimeScorer{
+			ByType:  map[receiver.NodeType]UptimeScorer{},
+			Default: MinUptimeScorer{},
+		}
+		for _, item := range strings.Split(arg, ",") {
+			typeName, scorerSpec, ok := strings.Cut(item, "=")
+			if !ok {
+				return nil, fmt.Errorf("uptime scorer %q: expected <type>=<scorer>, got %q", spec, item)
+			}
+			nType, ok := nodeTypesByName[strings.ToLower(strings.TrimSpace(typeName))]
+			if !ok {
+				return nil, fmt.Errorf("uptime scorer %q: unknown node type %q", spec, typeName)
+			}
+			s, err := ParseUptimeScorer(scorerSpec)
+			if err != nil {
+				return nil, err
+			}
+			if _, nested := s.(PerNodeTypeUptimeScorer); nested {
+				return nil, fmt.Errorf("uptime scorer %q: per-node-type scorers can not be nested", spec)
+			}
+			p.ByType[nType] = s
+		}
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("unknown uptime scorer %q", spec)
+}
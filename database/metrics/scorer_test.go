@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func TestScorerRatios(t *testing.T) {
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(1000 * time.Second)
+
+	tests := []struct {
+		name      string
+		in        UptimeInput
+		syncRatio float64
+		timeRatio float64
+	}{
+		{
+			name:      "in range",
+			in:        UptimeInput{Node: models.CelestiaNode{NodeType: receiver.BridgeNodeType, Head: 50, StartTime: start}, Runtime: 250, NetworkHeight: 100, StartTime: start, EndTime: end},
+			syncRatio: 0.5,
+			timeRatio: 0.25,
+		},
+		{
+			name:      "no network height",
+			in:        UptimeInput{Node: models.CelestiaNode{NodeType: receiver.BridgeNodeType, Head: 50, StartTime: start}, Runtime: 250, StartTime: start, EndTime: end},
+			syncRatio: 0,
+			timeRatio: 0.25,
+		},
+		{
+			name:      "empty window",
+			in:        UptimeInput{Node: models.CelestiaNode{NodeType: receiver.LightNodeType, DasTotalSampledHeaders: 10, StartTime: start}, Runtime: 250, NetworkHeight: 100, StartTime: start, EndTime: start},
+			syncRatio: 0.1,
+			timeRatio: 0,
+		},
+		{
+			name:      "window ending before it starts",
+			in:        UptimeInput{Node: models.CelestiaNode{StartTime: start}, Runtime: 250, NetworkHeight: 100, StartTime: start, EndTime: start.Add(-time.Hour)},
+			syncRatio: 0,
+			timeRatio: 0,
+		},
+		{
+			name:      "more than the window holds",
+			in:        UptimeInput{Node: models.CelestiaNode{NodeType: receiver.LightNodeType, DasTotalSampledHeaders: 150, StartTime: start}, Runtime: 5000, NetworkHeight: 100, StartTime: start, EndTime: end},
+			syncRatio: 1,
+			timeRatio: 1,
+		},
+	}
+
+	scorers := []UptimeScorer{MinUptimeScorer{}, TimeUptimeScorer{}, SyncUptimeScorer{}, WeightedUptimeScorer{SyncWeight: 0.5}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := syncRatio(tt.in); got != tt.syncRatio {
+				t.Errorf("sync ratio %v, want %v", got, tt.syncRatio)
+			}
+			if got := timeRatio(tt.in); got != tt.timeRatio {
+				t.Errorf("time ratio %v, want %v", got, tt.timeRatio)
+			}
+			for _, s := range scorers {
+				score := s.Score(tt.in)
+				if math.IsNaN(float64(score)) || math.IsInf(float64(score), 0) || score < 0 || score > 100 {
+					t.Errorf("%s scorer gives %v, want a percentage", s.Name(), score)
+				}
+			}
+		})
+	}
+}
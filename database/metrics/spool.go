@@ -0,0 +1,459 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+const (
+	DefaultSpoolSegmentSize = 64 << 20 // bytes
+
+	spoolSegmentExt  = ".wal"
+	spoolCursorFile  = "cursor"
+	spoolCursorTemp  = "cursor.tmp"
+	spoolSegmentName = "%016d" + spoolSegmentExt
+	spoolDeadLetter  = "deadletter.jsonl"
+)
+
+// Spool is an append-only, segmented write-ahead file that keeps the samples
+// which could not be written into the DB yet.
+// Every sample is stored as one JSON line, segments are read in the order they are written
+// and a segment is removed once all its samples are committed into the DB.
+type Spool struct {
+	dir             string
+	maxSegmentBytes int64
+
+	mu         sync.Mutex
+	segments   []uint64 // ids of the segments on disk, ascending
+	active     *os.File // the segment being appended to, it is always the last one in segments
+	activeSize int64
+	cursor     SpoolPosition // first sample not committed yet
+	samples    int64
+	bytes      int64
+
+	appended chan struct{}
+}
+
+// SpoolPosition points to a sample inside the spool
+type SpoolPosition struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+type SpoolStats struct {
+	Dir      string `json:"dir"`
+	Segments int    `json:"segments"`
+	Samples  int64  `json:"samples"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// OpenSpool opens the spool stored in dir, creating it if needed.
+// The segments left over from a previous run are kept and replayed first.
+func OpenSpool(dir string, maxSegmentBytes int64) (*Spool, error) {
+
+	if maxSegmentBytes <= 0 {
+		maxSegmentBytes = DefaultSpoolSegmentSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("spool: %v", err)
+	}
+
+	s := &Spool{
+		dir:             dir,
+		maxSegmentBytes: maxSegmentBytes,
+		appended:        make(chan struct{}, 1),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("spool: %v", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), spoolSegmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(e.Name(), spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue // not ours
+		}
+		s.segments = append(s.segments, id)
+	}
+	sort.Slice(s.segments, func(a, b int) bool { return s.segments[a] < s.segments[b] })
+
+	if err := s.readCursor(); err != nil {
+		return nil, err
+	}
+
+	// drop what the cursor says is already committed
+	for len(s.segments) > 0 && s.segments[0] < s.cursor.Segment {
+		if err := os.Remove(s.segmentPath(s.segments[0])); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("spool: %v", err)
+		}
+		s.segments = s.segments[1:]
+	}
+	if len(s.segments) == 0 || s.segments[0] != s.cursor.Segment {
+		s.cursor = SpoolPosition{}
+		if len(s.segments) > 0 {
+			s.cursor.Segment = s.segments[0]
+		}
+	}
+
+	for _, id := range s.segments {
+		offset := int64(0)
+		if id == s.cursor.Segment {
+			offset = s.cursor.Offset
+		}
+		lines, size, err := countLines(s.segmentPath(id), offset)
+		if err != nil {
+			return nil, fmt.Errorf("spool: %v", err)
+		}
+		s.samples += lines
+		s.bytes += size
+	}
+
+	return s, nil
+}
+
+func (s *Spool) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf(spoolSegmentName, id))
+}
+
+func (s *Spool) readCursor() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, spoolCursorFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("spool cursor: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.cursor); err != nil {
+		return fmt.Errorf("spool cursor: %v", err)
+	}
+	return nil
+}
+
+// writeCursor stores the cursor atomically, so a crash never leaves a half written one
+func (s *Spool) writeCursor() error {
+	data, err := json.Marshal(s.cursor)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := filepath.Join(s.dir, spoolCursorTemp)
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(s.dir, spoolCursorFile))
+}
+
+// Append stores the samples at the end of the spool and syncs them to disk
+func (s *Spool) Append(batch []*models.CelestiaNode) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf) // Encode adds the trailing new line
+	for _, data := range batch {
+		if err := enc.Encode(data); err != nil {
+			return fmt.Errorf("spool append: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil || s.activeSize >= s.maxSegmentBytes {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("spool append: %v", err)
+		}
+	}
+
+	n, err := s.active.Write(buf.Bytes())
+	s.activeSize += int64(n)
+	s.bytes += int64(n)
+	if err != nil {
+		return fmt.Errorf("spool append: %v", err)
+	}
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("spool append: %v", err)
+	}
+	s.samples += int64(len(batch))
+
+	select {
+	case s.appended <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// deadLetterEntry is one line of the dead letter file
+type deadLetterEntry struct {
+	Error  string               `json:"error"`
+	Sample *models.CelestiaNode `json:"sample"`
+}
+
+// DeadLetter sets aside the samples the DB rejects for good, with the reason, in a file next to the segments.
+// They are never replayed, the file is there to look into them and to import them by hand once fixed.
+func (s *Spool) DeadLetter(batch []*models.CelestiaNode, reason error) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, data := range batch {
+		if err := enc.Encode(deadLetterEntry{Error: reason.Error(), Sample: data}); err != nil {
+			return fmt.Errorf("spool dead letter: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(s.dir, spoolDeadLetter), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("spool dead letter: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("spool dead letter: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("spool dead letter: %v", err)
+	}
+	return nil
+}
+
+// rotate closes the active segment and opens a new one
+func (s *Spool) rotate() error {
+	if s.active != nil {
+		if err := s.active.Close(); err != nil {
+			return err
+		}
+		s.active = nil
+	}
+
+	id := uint64(1)
+	if len(s.segments) > 0 {
+		id = s.segments[len(s.segments)-1] + 1
+	}
+
+	f, err := os.OpenFile(s.segmentPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if len(s.segments) == 0 {
+		s.cursor = SpoolPosition{Segment: id}
+	}
+	s.segments = append(s.segments, id)
+	s.active = f
+	s.activeSize = 0
+	return nil
+}
+
+// Read returns up to max samples from the head of the spool, without removing them.
+// Once they are stored, call Commit with the returned position.
+func (s *Spool) Read(max int) ([]*models.CelestiaNode, SpoolPosition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pos := s.cursor
+	batch := []*models.CelestiaNode{}
+
+	for len(s.segments) > 0 && len(batch) == 0 {
+
+		f, err := os.Open(s.segmentPath(pos.Segment))
+		if err != nil {
+			return nil, pos, fmt.Errorf("spool read: %v", err)
+		}
+		if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, pos, fmt.Errorf("spool read: %v", err)
+		}
+
+		r := bufio.NewReader(f)
+		for len(batch) < max {
+			line, err := r.ReadBytes('\n')
+			if err == io.EOF {
+				// a partial line can only be the leftover of a crash in the middle of a write
+				if len(line) > 0 && !s.isActive(pos.Segment) {
+					log.Printf("spool: skipping %d bytes of a truncated sample in segment %d\n", len(line), pos.Segment)
+					pos.Offset += int64(len(line))
+				}
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, pos, fmt.Errorf("spool read: %v", err)
+			}
+			pos.Offset += int64(len(line))
+
+			data := &models.CelestiaNode{}
+			if err := json.Unmarshal(line, data); err != nil {
+				log.Printf("spool: skipping a corrupted sample in segment %d: %v\n", pos.Segment, err)
+				continue
+			}
+			batch = append(batch, data)
+		}
+		f.Close()
+
+		if len(batch) > 0 || s.isActive(pos.Segment) {
+			break
+		}
+
+		// the segment is fully consumed and everything in it is already committed
+		if err := s.removeSegment(pos.Segment); err != nil {
+			return nil, pos, err
+		}
+		pos = SpoolPosition{}
+		if len(s.segments) > 0 {
+			pos.Segment = s.segments[0]
+		}
+		s.cursor = pos
+		if err := s.writeCursor(); err != nil {
+			return nil, pos, fmt.Errorf("spool read: %v", err)
+		}
+	}
+
+	if len(s.segments) == 0 {
+		s.samples, s.bytes = 0, 0
+	}
+
+	return batch, pos, nil
+}
+
+func (s *Spool) isActive(id uint64) bool {
+	return s.active != nil && len(s.segments) > 0 && s.segments[len(s.segments)-1] == id
+}
+
+// Commit marks every sample before pos as stored and removes the fully consumed segments
+func (s *Spool) Commit(pos SpoolPosition, samples int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.segments) > 0 && s.segments[0] < pos.Segment {
+		if err := s.removeSegment(s.segments[0]); err != nil {
+			return err
+		}
+	}
+
+	// the whole spool has been replayed, start over with a fresh segment next time
+	if s.isActive(pos.Segment) && pos.Offset >= s.activeSize {
+		if err := s.active.Close(); err != nil {
+			return fmt.Errorf("spool commit: %v", err)
+		}
+		s.active = nil
+		if err := s.removeSegment(pos.Segment); err != nil {
+			return err
+		}
+		pos = SpoolPosition{}
+	}
+
+	s.cursor = pos
+	s.samples -= int64(samples)
+	if s.samples < 0 {
+		s.samples = 0
+	}
+	if len(s.segments) == 0 {
+		s.samples, s.bytes = 0, 0
+	}
+
+	if err := s.writeCursor(); err != nil {
+		return fmt.Errorf("spool commit: %v", err)
+	}
+	return nil
+}
+
+func (s *Spool) removeSegment(id uint64) error {
+	path := s.segmentPath(id)
+	info, err := os.Stat(path)
+	if err == nil {
+		s.bytes -= info.Size()
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("spool: %v", err)
+	}
+	s.segments = s.segments[1:]
+	return nil
+}
+
+// Appended returns a channel that receives a signal whenever new samples are spooled
+func (s *Spool) Appended() <-chan struct{} {
+	return s.appended
+}
+
+func (s *Spool) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.samples == 0
+}
+
+func (s *Spool) Stats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SpoolStats{
+		Dir:      s.dir,
+		Segments: len(s.segments),
+		Samples:  s.samples,
+		Bytes:    s.bytes,
+	}
+}
+
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
+
+func countLines(path string, offset int64) (int64, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	lines := int64(0)
+	r := bufio.NewReader(f)
+	for {
+		_, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		lines++
+	}
+	return lines, info.Size(), nil
+}
@@ -0,0 +1,303 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func openTestSpool(t *testing.T, dir string, maxSegmentBytes int64) *Spool {
+	t.Helper()
+
+	s, err := OpenSpool(dir, maxSegmentBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func spoolSamples(from, n int) []*models.CelestiaNode {
+	batch := make([]*models.CelestiaNode, n)
+	for i := range batch {
+		batch[i] = &models.CelestiaNode{NodeId: fmt.Sprintf("node-%d", from+i), Head: uint64(from + i)}
+	}
+	return batch
+}
+
+func nodeIdsOf(batch []*models.CelestiaNode) string {
+	ids := make([]string, len(batch))
+	for i, d := range batch {
+		ids[i] = d.NodeId
+	}
+	return fmt.Sprint(ids)
+}
+
+// readAll reads and commits the whole spool, one batch at a time
+func readAll(t *testing.T, s *Spool, max int) []*models.CelestiaNode {
+	t.Helper()
+
+	var all []*models.CelestiaNode
+	for i := 0; i < 100; i++ {
+		batch, pos, err := s.Read(max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batch) == 0 {
+			return all
+		}
+		if err := s.Commit(pos, len(batch)); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, batch...)
+	}
+	t.Fatal("the spool never gets empty")
+	return nil
+}
+
+func TestSpoolAppendReadCommit(t *testing.T) {
+
+	s := openTestSpool(t, t.TempDir(), 0)
+	if !s.Empty() {
+		t.Fatal("a new spool is not empty")
+	}
+
+	if err := s.Append(spoolSamples(0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(); stats.Samples != 3 || stats.Segments != 1 || stats.Bytes == 0 {
+		t.Errorf("stats %+v, want 3 samples in 1 segment", stats)
+	}
+
+	batch, pos, err := s.Read(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIdsOf(batch); got != "[node-0 node-1]" {
+		t.Errorf("read %s, want the first 2", got)
+	}
+	if batch[1].Head != 1 {
+		t.Errorf("read %+v, want the fields back", batch[1])
+	}
+
+	// reading again without a commit gives the same samples
+	again, _, err := s.Read(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIdsOf(again); got != "[node-0 node-1]" {
+		t.Errorf("read %s again, want the same 2", got)
+	}
+
+	if err := s.Commit(pos, len(batch)); err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(); stats.Samples != 1 {
+		t.Errorf("%d samples after the commit, want 1", stats.Samples)
+	}
+
+	if got := nodeIdsOf(readAll(t, s, 10)); got != "[node-2]" {
+		t.Errorf("read %s, want the last one", got)
+	}
+	if stats := s.Stats(); !s.Empty() || stats.Segments != 0 || stats.Bytes != 0 {
+		t.Errorf("stats %+v once everything is committed, want nothing left", stats)
+	}
+
+	// and it starts over
+	if err := s.Append(spoolSamples(3, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIdsOf(readAll(t, s, 10)); got != "[node-3]" {
+		t.Errorf("read %s, want the new one", got)
+	}
+}
+
+func TestSpoolRotation(t *testing.T) {
+
+	dir := t.TempDir()
+	s := openTestSpool(t, dir, 1) // every append gets a segment of its own
+
+	for i := 0; i < 3; i++ {
+		if err := s.Append(spoolSamples(2*i, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := s.Stats(); stats.Segments != 3 || stats.Samples != 6 {
+		t.Errorf("stats %+v, want 6 samples in 3 segments", stats)
+	}
+
+	// a read does not go across segments
+	batch, _, err := s.Read(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIdsOf(batch); got != "[node-0 node-1]" {
+		t.Errorf("read %s, want the first segment", got)
+	}
+
+	if got := nodeIdsOf(readAll(t, s, 10)); got != "[node-0 node-1 node-2 node-3 node-4 node-5]" {
+		t.Errorf("read %s, want all of them in order", got)
+	}
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 0 {
+		t.Errorf("segments %v left on disk, want them removed", segments)
+	}
+}
+
+// The committed samples are not replayed after a restart
+func TestSpoolReopen(t *testing.T) {
+
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := s.Append(spoolSamples(2*i, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		batch, pos, err := s.Read(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Commit(pos, len(batch)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openTestSpool(t, dir, 1)
+	if stats := s.Stats(); stats.Samples != 4 {
+		t.Errorf("%d samples after the reopen, want the 4 not committed", stats.Samples)
+	}
+	if got := nodeIdsOf(readAll(t, s, 10)); got != "[node-2 node-3 node-4 node-5]" {
+		t.Errorf("read %s after the reopen, want the 4 not committed", got)
+	}
+
+	// appending after the reopen goes after what was there
+	if err := s.Append(spoolSamples(6, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := nodeIdsOf(readAll(t, s, 10)); got != "[node-6]" {
+		t.Errorf("read %s, want the new one", got)
+	}
+}
+
+// A line cut short by a crash in the middle of a write is skipped, the samples around it are kept
+func TestSpoolTruncatedTail(t *testing.T) {
+
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(spoolSamples(0, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(s.segmentPath(1), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"NodeId":"node-cut`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s = openTestSpool(t, dir, 0)
+	if stats := s.Stats(); stats.Samples != 2 {
+		t.Errorf("%d samples, want the 2 complete ones", stats.Samples)
+	}
+	if err := s.Append(spoolSamples(2, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := nodeIdsOf(readAll(t, s, 10)); got != "[node-0 node-1 node-2]" {
+		t.Errorf("read %s, want the complete samples around the cut one", got)
+	}
+	if !s.Empty() {
+		t.Errorf("stats %+v, want the spool empty", s.Stats())
+	}
+}
+
+// A line which is not a sample is skipped
+func TestSpoolCorruptedLine(t *testing.T) {
+
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(spoolSamples(0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	f, err := os.OpenFile(s.segmentPath(1), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("not a sample\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s = openTestSpool(t, dir, 0)
+	if err := s.Append(spoolSamples(1, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := nodeIdsOf(readAll(t, s, 10)); got != "[node-0 node-1]" {
+		t.Errorf("read %s, want the samples around the corrupted line", got)
+	}
+	if !s.Empty() {
+		t.Errorf("stats %+v, want the spool empty", s.Stats())
+	}
+}
+
+func TestSpoolDeadLetter(t *testing.T) {
+
+	dir := t.TempDir()
+	s := openTestSpool(t, dir, 0)
+
+	if err := s.DeadLetter(spoolSamples(0, 2), errors.New("rejected")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeadLetter(spoolSamples(2, 1), errors.New("rejected again")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readDeadLetters(t, dir)
+	if len(entries) != 3 {
+		t.Fatalf("%d dead letters, want 3", len(entries))
+	}
+	for i, e := range entries {
+		want := "rejected"
+		if i == 2 {
+			want = "rejected again"
+		}
+		if e.Sample.NodeId != fmt.Sprintf("node-%d", i) || e.Error != want {
+			t.Errorf("dead letter %d is %+v, want node-%d with %q", i, e, i, want)
+		}
+	}
+
+	// the dead letters are never replayed
+	if !s.Empty() {
+		t.Errorf("stats %+v, want the spool empty", s.Stats())
+	}
+}
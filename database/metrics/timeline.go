@@ -0,0 +1,259 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+const (
+	TimelineRestart       = "restart"
+	TimelineVersionChange = "version_change"
+	TimelineHeartbeatGap  = "heartbeat_gap"
+	TimelineSyncStall     = "sync_stall"
+)
+
+const (
+	// How long the head of a node may stay still while the network moves on before it is a sync stall
+	DefaultSyncStallThreshold = 5 * time.Minute
+
+	// Number of samples read at once while building a timeline
+	timelineChunk = 5000
+)
+
+// timelineSample is the part of a sample the timeline is built from
+type timelineSample struct {
+	ID                          uint
+	CreatedAt                   time.Time
+	Version                     string
+	Head                        uint64
+	NetworkHeight               uint64
+	StartTime                   time.Time
+	LastRestartTime             time.Time
+	NodeRuntimeCounterInSeconds uint64
+}
+
+var timelineColumns = []string{
+	`"id"`, `"created_at"`, `"version"`, `"head"`, `"network_height"`,
+	`"start_time"`, `"last_restart_time"`, `"node_runtime_counter_in_seconds"`,
+}
+
+// timelineBuilder walks through the samples of a node in order and emits the events it finds
+type timelineBuilder struct {
+	gapThreshold   time.Duration
+	stallThreshold time.Duration
+
+	events []models.NodeTimelineEvent
+	prev   *timelineSample
+
+	// the sample the head of the node last moved at, the sync stall candidate starts there
+	stallStart *timelineSample
+}
+
+// GetNodeTimeline rebuilds what happened to a node in [from, to): its restarts, version changes,
+// heartbeat gaps and sync stalls, in the order they happened. A zero to means up to now,
+// a zero stallThreshold means DefaultSyncStallThreshold.
+func (m *Metrics) GetNodeTimeline(nodeId string, from, to time.Time, stallThreshold time.Duration) ([]models.NodeTimelineEvent, error) {
+
+	now := time.Now()
+	upToNow := to.IsZero() || !to.Before(now)
+	if to.IsZero() {
+		to = now
+	}
+	if stallThreshold <= 0 {
+		stallThreshold = DefaultSyncStallThreshold
+	}
+
+	b := &timelineBuilder{
+		gapThreshold:   m.heartbeatGapThreshold,
+		stallThreshold: stallThreshold,
+		events:         []models.NodeTimelineEvent{},
+	}
+
+	// the sample before the window tells what the node was running when the window starts
+	var before []timelineSample
+	err := m.db.Model(&models.CelestiaNode{}).Select(timelineColumns).
+		Where(`"node_id" = ? AND "created_at" < ?`, nodeId, from.UTC()).
+		Order(`"id" DESC`).Limit(1).Find(&before).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(before) > 0 {
+		b.prev = &before[0]
+		b.stallStart, err = m.stallStartOf(nodeId, &before[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lastId := uint(0)
+	for {
+		var chunk []timelineSample
+		err := m.db.Model(&models.CelestiaNode{}).Select(timelineColumns).
+			Where(`"node_id" = ? AND "created_at" >= ? AND "created_at" < ? AND "id" > ?`, nodeId, from.UTC(), to.UTC(), lastId).
+			Order(`"id" ASC`).Limit(timelineChunk).Find(&chunk).Error
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range chunk {
+			b.add(&chunk[i], from)
+		}
+		if len(chunk) < timelineChunk {
+			break
+		}
+		lastId = chunk[len(chunk)-1].ID
+	}
+
+	b.finish(to, upToNow)
+
+	sort.SliceStable(b.events, func(i, j int) bool {
+		return b.events[i].Time.Before(b.events[j].Time)
+	})
+	return b.events, nil
+}
+
+// stallStartOf returns the first sample of the run of samples the head of the node stayed at up to last,
+// so a stall going on when the window starts is reported from when it began
+func (m *Metrics) stallStartOf(nodeId string, last *timelineSample) (*timelineSample, error) {
+
+	var moved []timelineSample
+	err := m.db.Model(&models.CelestiaNode{}).Select(`"id"`).
+		Where(`"node_id" = ? AND "id" < ? AND "head" != ?`, nodeId, last.ID, last.Head).
+		Order(`"id" DESC`).Limit(1).Find(&moved).Error
+	if err != nil {
+		return nil, err
+	}
+	afterId := uint(0)
+	if len(moved) > 0 {
+		afterId = moved[0].ID
+	}
+
+	var first []timelineSample
+	err = m.db.Model(&models.CelestiaNode{}).Select(timelineColumns).
+		Where(`"node_id" = ? AND "id" > ? AND "id" <= ?`, nodeId, afterId, last.ID).
+		Order(`"id" ASC`).Limit(1).Find(&first).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(first) == 0 {
+		return last, nil
+	}
+	return &first[0], nil
+}
+
+func (b *timelineBuilder) add(cur *timelineSample, from time.Time) {
+
+	prev := b.prev
+	b.prev = cur
+
+	if prev == nil {
+		b.stallStart = cur
+		return
+	}
+
+	// the gap may have started before the window, it is only reported if it ends in it
+	if gap := cur.CreatedAt.Sub(prev.CreatedAt); gap >= b.gapThreshold {
+		b.addSpan(TimelineHeartbeatGap, prev.CreatedAt, cur.CreatedAt, false,
+			fmt.Sprintf("no metrics for %s", gap.Round(time.Second)))
+
+		// the node was down, not stalled, during the gap
+		b.endStall(prev, prev.CreatedAt, false)
+		b.stallStart = cur
+	}
+
+	if restartTime, detail, ok := restartOf(prev, cur); ok {
+		if restartTime.Before(from) {
+			restartTime = cur.CreatedAt // the restart time reported by the node is off, keep it in the window
+		}
+		b.events = append(b.events, models.NodeTimelineEvent{
+			Type:   TimelineRestart,
+			Time:   restartTime,
+			Detail: detail,
+		})
+	}
+
+	if cur.Version != prev.Version && cur.Version != "" {
+		b.events = append(b.events, models.NodeTimelineEvent{
+			Type:   TimelineVersionChange,
+			Time:   cur.CreatedAt,
+			From:   prev.Version,
+			To:     cur.Version,
+			Detail: fmt.Sprintf("version changed from %q to %q", prev.Version, cur.Version),
+		})
+	}
+
+	if cur.Head != b.stallStart.Head {
+		b.endStall(cur, cur.CreatedAt, false)
+		b.stallStart = cur
+	}
+}
+
+// finish reports the gap and the stall still going on at the end of the window, they are cut at the end
+// of the window and they are ongoing if the window reaches now
+func (b *timelineBuilder) finish(end time.Time, ongoing bool) {
+
+	if b.prev == nil {
+		return
+	}
+
+	if gap := end.Sub(b.prev.CreatedAt); gap >= b.gapThreshold {
+		detail := fmt.Sprintf("no metrics for %s", gap.Round(time.Second))
+		if ongoing {
+			detail += " so far"
+		}
+		b.addSpan(TimelineHeartbeatGap, b.prev.CreatedAt, end, ongoing, detail)
+
+		// the node was down, not stalled, after its last sample
+		b.endStall(b.prev, b.prev.CreatedAt, false)
+		return
+	}
+
+	b.endStall(b.prev, end, ongoing)
+}
+
+// endStall reports the stall candidate lasting until end, last being its latest sample,
+// if the head stayed still long enough while the network moved on
+func (b *timelineBuilder) endStall(last *timelineSample, end time.Time, ongoing bool) {
+
+	start := b.stallStart
+	if start == nil || last.NetworkHeight <= start.NetworkHeight || end.Sub(start.CreatedAt) < b.stallThreshold {
+		return
+	}
+
+	detail := fmt.Sprintf("head stuck at %d while the network went from %d to %d", start.Head, start.NetworkHeight, last.NetworkHeight)
+	b.addSpan(TimelineSyncStall, start.CreatedAt, end, ongoing, detail)
+}
+
+func (b *timelineBuilder) addSpan(eventType string, start, end time.Time, ongoing bool, detail string) {
+	b.events = append(b.events, models.NodeTimelineEvent{
+		Type:            eventType,
+		Time:            start,
+		End:             &end,
+		DurationSeconds: end.Sub(start).Seconds(),
+		Ongoing:         ongoing,
+		Detail:          detail,
+	})
+}
+
+// restartOf tells if the node restarted between two samples and when. The restart time reported
+// by the node is used when it has one, the reset of the runtime counter gives it away otherwise.
+func restartOf(prev, cur *timelineSample) (time.Time, string, bool) {
+
+	if !prev.LastRestartTime.IsZero() && cur.LastRestartTime.After(prev.LastRestartTime) {
+		return cur.LastRestartTime, "restart reported by the node", true
+	}
+	if !prev.StartTime.IsZero() && cur.StartTime.After(prev.StartTime) {
+		return cur.StartTime, "start time moved forward", true
+	}
+	if cur.NodeRuntimeCounterInSeconds < prev.NodeRuntimeCounterInSeconds {
+		restartTime := cur.CreatedAt.Add(-time.Duration(cur.NodeRuntimeCounterInSeconds) * time.Second)
+		if restartTime.Before(prev.CreatedAt) {
+			restartTime = prev.CreatedAt
+		}
+		return restartTime, "runtime counter reset", true
+	}
+	return time.Time{}, "", false
+}
@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// describeEvent writes an event with its times relative to base, e.g. heartbeat_gap 1m0s-6m0s
+func describeEvent(ev models.NodeTimelineEvent, base time.Time) string {
+	s := fmt.Sprintf("%s %v", ev.Type, ev.Time.Sub(base))
+	if ev.End != nil {
+		s += fmt.Sprintf("-%v", ev.End.Sub(base))
+	}
+	if ev.To != "" {
+		s += fmt.Sprintf(" %s>%s", ev.From, ev.To)
+	}
+	if ev.Ongoing {
+		s += " ongoing"
+	}
+	return s
+}
+
+// timelineSamples makes a sample every 30s from begin on, for each of the given heads,
+// with the network height moving on by one every sample
+func timelineSamples(begin time.Time, heads ...uint64) []*models.CelestiaNode {
+	batch := make([]*models.CelestiaNode, len(heads))
+	for i, head := range heads {
+		batch[i] = &models.CelestiaNode{
+			NodeId:        "node-1",
+			Version:       "v1",
+			Head:          head,
+			NetworkHeight: uint64(100 + i),
+			CreatedAt:     begin.Add(time.Duration(i) * 30 * time.Second),
+		}
+	}
+	return batch
+}
+
+// repeat gives n times the head
+func repeat(head uint64, n int) []uint64 {
+	heads := make([]uint64, n)
+	for i := range heads {
+		heads[i] = head
+	}
+	return heads
+}
+
+func TestNodeTimeline(t *testing.T) {
+
+	base := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	min := func(n float64) time.Duration { return time.Duration(n * float64(time.Minute)) }
+
+	tests := []struct {
+		name    string
+		samples func() []*models.CelestiaNode
+		from    time.Duration // relative to base
+		to      time.Duration
+		want    []string
+	}{
+		{
+			name:    "nothing happened",
+			samples: func() []*models.CelestiaNode { return timelineSamples(base, 1, 2, 3, 4) },
+			to:      min(10),
+			want:    []string{"heartbeat_gap 1m30s-10m0s"},
+		},
+		{
+			name: "restart reported by the node",
+			samples: func() []*models.CelestiaNode {
+				batch := timelineSamples(base, 1, 2, 3)
+				for _, d := range batch {
+					d.LastRestartTime = base.Add(-time.Hour)
+				}
+				batch[2].LastRestartTime = base.Add(50 * time.Second)
+				return batch
+			},
+			to:   min(1.5),
+			want: []string{"restart 50s"},
+		},
+		{
+			name: "start time moved forward",
+			samples: func() []*models.CelestiaNode {
+				batch := timelineSamples(base, 1, 2, 3)
+				batch[0].StartTime = base.Add(-time.Hour)
+				batch[1].StartTime = base.Add(-time.Hour)
+				batch[2].StartTime = base.Add(45 * time.Second)
+				return batch
+			},
+			to:   min(1.5),
+			want: []string{"restart 45s"},
+		},
+		{
+			name: "runtime counter reset",
+			samples: func() []*models.CelestiaNode {
+				batch := timelineSamples(base, 1, 2, 3)
+				batch[0].NodeRuntimeCounterInSeconds = 1000
+				batch[1].NodeRuntimeCounterInSeconds = 1030
+				batch[2].NodeRuntimeCounterInSeconds = 10
+				return batch
+			},
+			to:   min(1.5),
+			want: []string{"restart 50s"},
+		},
+		{
+			name: "version change",
+			samples: func() []*models.CelestiaNode {
+				batch := timelineSamples(base, 1, 2, 3)
+				batch[2].Version = "v2"
+				return batch
+			},
+			to:   min(1.5),
+			want: []string{"version_change 1m0s v1>v2"},
+		},
+		{
+			name: "version before the window",
+			samples: func() []*models.CelestiaNode {
+				batch := timelineSamples(base, 1, 2, 3)
+				batch[1].Version = "v2"
+				batch[2].Version = "v2"
+				return batch
+			},
+			from: min(0.5),
+			to:   min(1.5),
+			want: []string{"version_change 30s v1>v2"},
+		},
+		{
+			name: "gap in the window",
+			samples: func() []*models.CelestiaNode {
+				return append(timelineSamples(base, 1, 2), timelineSamples(base.Add(min(6)), 3, 4)...)
+			},
+			to:   min(6.5),
+			want: []string{"heartbeat_gap 30s-6m0s"},
+		},
+		{
+			name: "gap starting before the window",
+			samples: func() []*models.CelestiaNode {
+				return append(timelineSamples(base, 1, 2), timelineSamples(base.Add(min(6)), 3, 4)...)
+			},
+			from: min(2),
+			to:   min(6.5),
+			want: []string{"heartbeat_gap 30s-6m0s"},
+		},
+		{
+			name: "gap going on at the end of a past window",
+			samples: func() []*models.CelestiaNode {
+				return append(timelineSamples(base, 1, 2), timelineSamples(base.Add(time.Hour), 3, 4)...)
+			},
+			to:   min(10),
+			want: []string{"heartbeat_gap 30s-10m0s"},
+		},
+		{
+			name: "gap too short at the end of a past window",
+			samples: func() []*models.CelestiaNode {
+				return append(timelineSamples(base, 1, 2), timelineSamples(base.Add(time.Hour), 3, 4)...)
+			},
+			to:   min(1.5),
+			want: []string{},
+		},
+		{
+			name: "stall in the window",
+			samples: func() []*models.CelestiaNode {
+				return timelineSamples(base, append(append([]uint64{1}, repeat(2, 12)...), 3)...)
+			},
+			to:   min(7),
+			want: []string{"sync_stall 30s-6m30s"},
+		},
+		{
+			name: "stall too short",
+			samples: func() []*models.CelestiaNode {
+				return timelineSamples(base, append(append([]uint64{1}, repeat(2, 5)...), 3)...)
+			},
+			to:   min(3.5),
+			want: []string{},
+		},
+		{
+			name: "stall starting before the window",
+			samples: func() []*models.CelestiaNode {
+				return timelineSamples(base, append(append([]uint64{1}, repeat(2, 12)...), 3)...)
+			},
+			from: min(4),
+			to:   min(7),
+			want: []string{"sync_stall 30s-6m30s"},
+		},
+		{
+			name: "stall going on at the end of a past window",
+			samples: func() []*models.CelestiaNode {
+				return timelineSamples(base, append([]uint64{1}, repeat(2, 30)...)...)
+			},
+			to:   min(10),
+			want: []string{"sync_stall 30s-10m0s"},
+		},
+		{
+			name: "stall then down at the end of a past window",
+			samples: func() []*models.CelestiaNode {
+				return timelineSamples(base, append([]uint64{1}, repeat(2, 14)...)...)
+			},
+			to:   min(20),
+			want: []string{"sync_stall 30s-7m0s", "heartbeat_gap 7m0s-20m0s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			m := newSQLiteMetrics(t)
+			if err := m.AddNodeDataBatch(tt.samples()); err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := m.GetNodeTimeline("node-1", base.Add(tt.from), base.Add(tt.to), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, ev := range events {
+				got = append(got, describeEvent(ev, base))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("events %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// The gap and the stall still going on are ongoing when the window reaches now
+func TestNodeTimelineOngoing(t *testing.T) {
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	m := newSQLiteMetrics(t)
+	if err := m.AddNodeDataBatch(timelineSamples(base, 1, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := m.GetNodeTimeline("node-1", base, time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != TimelineHeartbeatGap || !events[0].Ongoing || events[0].End.Before(base.Add(59*time.Minute)) {
+		t.Errorf("events %+v, want an ongoing gap up to now", events)
+	}
+
+	// still reporting, with the head stuck
+	m = newSQLiteMetrics(t)
+	stuck := timelineSamples(time.Now().Add(-10*time.Minute), append([]uint64{1}, repeat(2, 20)...)...)
+	if err := m.AddNodeDataBatch(stuck); err != nil {
+		t.Fatal(err)
+	}
+	events, err = m.GetNodeTimeline("node-1", time.Now().Add(-time.Hour), time.Time{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != TimelineSyncStall || !events[0].Ongoing {
+		t.Errorf("events %+v, want an ongoing stall", events)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/celestiaorg/leaderboard-backend/receiver"
 	"github.com/celestiaorg/nodelogger/database"
 	"github.com/celestiaorg/nodelogger/database/models"
 )
@@ -20,20 +19,83 @@ func (m *Metrics) GetNodeUpTime(nodeId string) (float32, error) {
 		return 0, tx.Error
 	}
 
-	return nodeInfo.Uptime, nil
+	if m.uptimeStartTime.IsZero() {
+		return nodeInfo.Uptime, nil // No uptime window is set, so we rely on what the receiver computed
+	}
+
+	endTime := time.Now()
+	networkHeight := nodeInfo.NetworkHeight
+	if !m.uptimeEndTime.IsZero() && m.uptimeEndTime.Before(endTime) {
+		endTime = m.uptimeEndTime
+
+		var err error
+		networkHeight, err = m.getNetworkHeightAtTime(endTime)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	runtime, err := m.runtimeUntil(nodeId, endTime)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.uptimeScorer.Score(UptimeInput{
+		Node:          nodeInfo,
+		Runtime:       uint64(runtime),
+		NetworkHeight: networkHeight,
+		StartTime:     m.uptimeStartTime,
+		EndTime:       endTime,
+	}), nil
+}
+
+// ScoreReportedUptime computes the uptime of a pushed sample from the runtime counters the node reports,
+// as the Prometheus receiver does for the polled ones
+func (m *Metrics) ScoreReportedUptime(node models.CelestiaNode) float32 {
+
+	startTime := m.uptimeStartTime
+	if startTime.IsZero() {
+		startTime = node.StartTime
+	}
+
+	endTime := node.CreatedAt
+	if endTime.IsZero() {
+		endTime = time.Now()
+	}
+	if !endTime.After(startTime) {
+		return 0
+	}
+
+	return m.uptimeScorer.Score(UptimeInput{
+		Node:          node,
+		Runtime:       node.LastAccumulativeNodeRuntimeCounterInSeconds + node.NodeRuntimeCounterInSeconds,
+		NetworkHeight: node.NetworkHeight,
+		StartTime:     startTime,
+		EndTime:       endTime,
+	})
 }
 
 func (m *Metrics) RecomputeUptimeForAll(uptimeStartTime, uptimeEndTime time.Time) ([]models.CelestiaNode, error) {
 
 	nodesList := []models.CelestiaNode{}
 
+	fmt.Printf("Updating the runtime checkpoints...\n")
+	if err := m.UpdateRuntimeCheckpoints(nil); err != nil {
+		return nodesList, err
+	}
+
 	rows := []string{}
-	SQL := `SELECT DISTINCT "node_id" from "celestia_nodes"`
-	if err := database.CachedQuery(m.db, SQL, &rows); err != nil {
+	SQL := `SELECT "node_id" FROM "node_runtime_checkpoints" ORDER BY "node_id"`
+	if err := database.Query(m.db, SQL, &rows); err != nil {
 		return nodesList, err
 	}
 	// rows := getPredefinedNodeIdsList()
 
+	networkHeight, err := m.getNetworkHeightAtTime(uptimeEndTime)
+	if err != nil {
+		return nodesList, err
+	}
+
 	for i, nodeId := range rows {
 		fmt.Printf("[ %d / %d ] nodeId: %v ", i+1, len(rows), nodeId)
 		latestNodeData, err := m.GetNodeDataByMetricTime(nodeId, uptimeEndTime)
@@ -44,16 +106,18 @@ func (m *Metrics) RecomputeUptimeForAll(uptimeStartTime, uptimeEndTime time.Time
 			}
 			return nodesList, err
 		}
-		newRunTime, err := m.recomputeRuntime(nodeId, 0, uptimeEndTime)
-		if err != nil {
-			return nodesList, err
-		}
-		networkHeight, err := m.getNetworkHeightAtTime(uptimeEndTime)
+		newRunTime, err := m.runtimeUntil(nodeId, uptimeEndTime)
 		if err != nil {
 			return nodesList, err
 		}
 
-		newUptime := nodeUptime(latestNodeData, uint64(newRunTime), networkHeight, uptimeStartTime, uptimeEndTime)
+		newUptime := m.uptimeScorer.Score(UptimeInput{
+			Node:          latestNodeData,
+			Runtime:       uint64(newRunTime),
+			NetworkHeight: networkHeight,
+			StartTime:     uptimeStartTime,
+			EndTime:       uptimeEndTime,
+		})
 		fmt.Printf("\toldUptime: %v\tnewUptime: %v\n", latestNodeData.Uptime, newUptime)
 
 		latestNodeData.NewUptime = newUptime
@@ -66,205 +130,25 @@ func (m *Metrics) RecomputeUptimeForAll(uptimeStartTime, uptimeEndTime time.Time
 	return nodesList, nil
 }
 
-// This one processes everything in the DB and so it avoids transferring huge amount of data to the client and so it is faster
-// It stores the outcome in cache and if re-execute it again, it reads the already processed data from cache in sequences
-func (m *Metrics) recomputeRuntime(nodeId string, networkHeightBegin uint64, endTime time.Time) (int64, error) {
-
-	startTime := time.Now().Unix()
-
-	var rows []models.CelestiaNode
-
-	latestIdFromCache := uint(0)
-	latestRuntimeFromCache := int64(0)
-
-	SQLTxt := `
-		SELECT 
-			MAX("id") AS "id",
-			CAST(ROUND(SUM("time_gap_seconds")::NUMERIC) AS BIGINT) + %d AS "new_runtime"
-		FROM (
-		SELECT 
-			t1."id", 
-			t1."node_id", 
-			t1."created_at", 
-			EXTRACT(EPOCH FROM (MIN(t2."created_at") - t1."created_at")) AS "time_gap_seconds"
-		FROM 
-			"celestia_nodes" t1 
-			LEFT JOIN "celestia_nodes" t2 ON t1.node_id = t2.node_id AND t1."created_at" < t2."created_at" 
-		WHERE 
-			t1."id" > %d
-			AND t1."network_height" > %d 	
-			AND t1."created_at" < CAST('%s' AS TIMESTAMP)
-			AND t1."node_id" = '%s'
-		GROUP BY 
-			t1."id", t1."node_id", t1."created_at" 
-		ORDER BY 
-			t1."id" ASC
-		) AS subquery
-		WHERE "time_gap_seconds" < 100`
-
-	SQL := fmt.Sprintf(SQLTxt, latestRuntimeFromCache, latestIdFromCache, networkHeightBegin, endTime.Format("2006-01-02 15:04:05-07:00"), nodeId)
-	for database.ExistCachedQuery(SQL) {
-		if err := database.CachedQuery(m.db, SQL, &rows); err != nil {
-			return 0, err
-		}
-		if len(rows) != 0 {
-			if latestIdFromCache == rows[0].ID {
-				break // no new rows to process
-			}
-
-			latestIdFromCache = rows[0].ID
-			latestRuntimeFromCache = rows[0].NewRuntime
-			SQL = fmt.Sprintf(SQLTxt, latestRuntimeFromCache, latestIdFromCache, networkHeightBegin, endTime.Format("2006-01-02 15:04:05-07:00"), nodeId)
-		} else {
-
-			break // no results
-		}
-	}
-
-	if err := database.CachedQuery(m.db, SQL, &rows); err != nil {
-		return 0, err
-	}
-	if len(rows) == 0 || rows[0].ID == 0 {
-		return latestRuntimeFromCache, nil
-	}
-
-	timeLapse := time.Now().Unix() - startTime
-	fmt.Printf("\tTimeLapse: %v s", timeLapse)
-
-	return rows[0].NewRuntime, nil
-}
-
-// This is not very optimized
-func (m *Metrics) recomputeRuntime_old(nodeId string, networkHeightBegin uint64) (int64, error) {
-
-	const limit = 100
-	offset := int64(0)
-	totalNodeRuntime := int64(0)
-	var nodeStartTime time.Time
-	var nodeLastRestartTime time.Time
-	var lastMetricTime time.Time
-	var latestNodeData models.CelestiaNode
-
-	for {
-
-		var rows []models.CelestiaNode
-
-		SQL := fmt.Sprintf(`
-		SELECT * 
-		FROM "celestia_nodes" 
-		WHERE 
-			"network_height" > %d 
-			AND "node_id" = '%s' 
-		ORDER BY "id" ASC
-		LIMIT %d OFFSET %d`, networkHeightBegin, nodeId, limit, offset)
-		if err := database.CachedQuery(m.db, SQL, &rows); err != nil {
-			return 0, err
-		}
-		if len(rows) == 0 {
-			break // hit the final row
-		}
-
-		offset += int64(len(rows))
-		for _, node := range rows {
-
-			if nodeStartTime.IsZero() {
-				nodeStartTime = node.StartTime
-			}
-
-			if nodeStartTime != node.StartTime {
-				return 0, fmt.Errorf("start time change! expected %v got %v", nodeStartTime, node.StartTime)
-			}
-
-			if nodeLastRestartTime.IsZero() {
-				nodeLastRestartTime = node.LastRestartTime
-				if node.LastRestartTime.IsZero() {
-					nodeLastRestartTime = node.StartTime
-				}
-			}
-
-			if lastMetricTime.IsZero() || lastMetricTime.Before(node.CreatedAt) {
-				metricsGap := node.CreatedAt.Unix() - lastMetricTime.Unix()
-				if metricsGap < 100 { // The time when there is no metrics (heartbeat) we consider the node down (seconds)
-					totalNodeRuntime += metricsGap
-				}
-				lastMetricTime = node.CreatedAt
-			}
-
-			latestNodeData = node
-		}
-
-	}
-
-	// If the node has never been restarted
-	if totalNodeRuntime == 0 {
-		totalNodeRuntime = latestNodeData.CreatedAt.Unix() - nodeStartTime.Unix()
-	}
-
-	return totalNodeRuntime, nil
-}
-
-func nodeUptime(node models.CelestiaNode, totalRunTime uint64, networkHeight uint64, uptimeStartTime, uptimeEndTime time.Time) float32 {
-
-	totalSyncedBlocks := node.DasTotalSampledHeaders // full & light nodes
-	if node.NodeType == receiver.BridgeNodeType {
-		totalSyncedBlocks = node.Head
-	}
-
-	// for nodes that started late
-	nodeStartTime := node.StartTime
-	if nodeStartTime.After(uptimeStartTime) {
-		nodeStartTime = uptimeStartTime
-	}
-
-	syncUptime := float64(totalSyncedBlocks) / float64(networkHeight)
-	tsUptime := float64(totalRunTime) / float64(uptimeEndTime.Unix()-nodeStartTime.Unix())
-
-	// tools.PrintJson(node)
-	// fmt.Printf("syncUptime: %v\n", syncUptime)
-	// fmt.Printf("tsUptime: %v\n", tsUptime)
-
-	if syncUptime < tsUptime || node.StartTime.IsZero() {
-		return float32(100 * syncUptime)
-	}
-	return float32(100 * tsUptime)
-
-	// ref: uptime = minimum(total_block_sampled_or_synced/network_head, total_node_uptime_in_seconds/(current_time-node_Start_time))
-}
-
-func (m *Metrics) GetLatestNodeData(nodeId string) (models.CelestiaNode, error) {
+func (m *Metrics) GetNodeDataByMetricTime(nodeId string, metricTime time.Time) (models.CelestiaNode, error) {
 
 	var rows []models.CelestiaNode
 
-	SQL := fmt.Sprintf(`
-		SELECT * 
-		FROM "celestia_nodes" 
-		WHERE 
-			"node_id" = '%s' 
-		ORDER BY "id" DESC
-		LIMIT 1`, nodeId)
-	if err := database.Query(m.db, SQL, &rows); err != nil {
-		return models.CelestiaNode{}, err
-	}
-	if len(rows) == 0 {
-		return models.CelestiaNode{}, fmt.Errorf("node not found")
+	// SQLite has no time type to cast to, it compares the times as text
+	timeSQL, timeArg := `CAST(? AS TIMESTAMP)`, interface{}(metricTime.Format("2006-01-02 15:04:05-07:00"))
+	if m.isSQLite() {
+		timeSQL, timeArg = `?`, m.timeArg(metricTime)
 	}
-	return rows[0], nil
-
-}
-
-func (m *Metrics) GetNodeDataByMetricTime(nodeId string, metricTime time.Time) (models.CelestiaNode, error) {
-
-	var rows []models.CelestiaNode
 
-	SQL := fmt.Sprintf(`
+	SQL := `
 		SELECT *
 		FROM "celestia_nodes" 
 		WHERE 
-			"node_id" = '%s'
-			AND "created_at" >= CAST('%s' AS TIMESTAMP)
+			"node_id" = ?
+			AND "created_at" >= ` + timeSQL + `
 		ORDER BY "id" ASC
-		LIMIT 1`, nodeId, metricTime.Format("2006-01-02 15:04:05-07:00"))
-	if err := database.CachedQuery(m.db, SQL, &rows); err != nil {
+		LIMIT 1`
+	if err := database.CachedQuery(m.db, SQL, &rows, nodeId, timeArg); err != nil {
 		return models.CelestiaNode{}, err
 	}
 	if len(rows) == 0 {
@@ -274,42 +158,6 @@ func (m *Metrics) GetNodeDataByMetricTime(nodeId string, metricTime time.Time) (
 
 }
 
-func (m *Metrics) getTheLatestNetworkHeight() (uint64, error) {
-
-	var rows []models.CelestiaNode
-
-	SQL := `
-		SELECT 
-			MAX("network_height") AS "network_height"
-		FROM "celestia_nodes"`
-
-	if err := database.Query(m.db, SQL, &rows); err != nil {
-		return 0, err
-	}
-	if len(rows) == 0 {
-		return 0, fmt.Errorf("node not found")
-	}
-	return rows[0].NetworkHeight, nil
-}
-
-func (m *Metrics) getNetworkHeightAtTime(metricTime time.Time) (uint64, error) {
-
-	var rows []models.CelestiaNode
-
-	SQL := fmt.Sprintf(`
-		SELECT MAX("network_height") AS "network_height"
-		FROM "celestia_nodes"
-		WHERE "created_at" < CAST('%s' AS TIMESTAMP)`, metricTime.Format("2006-01-02 15:04:05-07:00"))
-
-	if err := database.CachedQuery(m.db, SQL, &rows); err != nil {
-		return 0, err
-	}
-	if len(rows) == 0 {
-		return 0, fmt.Errorf("node not found")
-	}
-	return rows[0].NetworkHeight, nil
-}
-
 // This list is extracted from the knack portal to make the calculation faster
 // This is a dirty hack to make it quickly processed
 func getPredefinedNodeIdsList() []string {
@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+type UptimeBucket string
+
+const (
+	UptimeBucketDay   UptimeBucket = "day"
+	UptimeBucketWeek  UptimeBucket = "week"
+	UptimeBucketMonth UptimeBucket = "month"
+
+	// MaxUptimeBuckets caps how many buckets can be computed in one call
+	MaxUptimeBuckets = 400
+)
+
+var ErrTooManyBuckets = fmt.Errorf("too many buckets, at most %d are allowed", MaxUptimeBuckets)
+
+var uptimeBuckets = []UptimeBucket{UptimeBucketDay, UptimeBucketWeek, UptimeBucketMonth}
+
+func ParseUptimeBucket(bucket string) (UptimeBucket, error) {
+	switch b := UptimeBucket(bucket); b {
+	case UptimeBucketDay, UptimeBucketWeek, UptimeBucketMonth:
+		return b, nil
+	}
+	return "", fmt.Errorf("unknown bucket %q, expected one of day, week, month", bucket)
+}
+
+// Truncate returns the beginning of the bucket t falls in, in UTC. Weeks start on Monday.
+func (b UptimeBucket) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch b {
+	case UptimeBucketWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case UptimeBucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// Next returns the beginning of the bucket after the one starting at t
+func (b UptimeBucket) Next(t time.Time) time.Time {
+	switch b {
+	case UptimeBucketWeek:
+		return t.AddDate(0, 0, 7)
+	case UptimeBucketMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// Count returns the number of buckets overlapping [from, to), without going through them
+func (b UptimeBucket) Count(from, to time.Time) int64 {
+
+	start := b.Truncate(from)
+	if !start.Before(to) {
+		return 0
+	}
+
+	switch b {
+	case UptimeBucketMonth:
+		end := b.Truncate(to)
+		n := int64(end.Year()-start.Year())*12 + int64(end.Month()-start.Month())
+		if end.Before(to) {
+			n++
+		}
+		return n
+	case UptimeBucketWeek:
+		return ceilDiv(to.Sub(start), 7*24*time.Hour)
+	}
+	// the buckets are in UTC, all the days are 24 hours long
+	return ceilDiv(to.Sub(start), 24*time.Hour)
+}
+
+// ceilDiv rounds up, to.Sub(from) saturates on the far apart times so it must not be added to
+func ceilDiv(d, unit time.Duration) int64 {
+	n := int64(d / unit)
+	if d%unit != 0 {
+		n++
+	}
+	return n
+}
+
+// checkBucketCount tells if [from, to) can be split in buckets in one call
+func checkBucketCount(bucket UptimeBucket, from, to time.Time) error {
+	if bucket.Count(from, to) > MaxUptimeBuckets {
+		return ErrTooManyBuckets
+	}
+	return nil
+}
+
+// isBucketWindow tells if [from, to) is exactly one bucket, the only windows worth caching
+func isBucketWindow(from, to time.Time) bool {
+	for _, b := range uptimeBuckets {
+		if b.Truncate(from).Equal(from) && b.Next(from).Equal(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNodeUptimeInWindow computes the uptime of a node in [from, to) from its raw samples,
+// with the same runtime calculation and the same scorer used for the whole uptime window.
+// The runtime only counts the samples in the window and the node is expected to be up for the whole window.
+func (m *Metrics) GetNodeUptimeInWindow(nodeId string, from, to time.Time) (models.NodeUptime, error) {
+
+	res := models.NodeUptime{
+		From: from,
+		To:   to,
+	}
+
+	// The future is not counted
+	if now := time.Now(); to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return res, nil
+	}
+
+	var rows []models.CelestiaNode
+
+	SQL := `
+		SELECT *
+		FROM "celestia_nodes"
+		WHERE
+			"node_id" = ?
+			AND "created_at" >= ?
+			AND "created_at" < ?
+			AND "deleted_at" IS NULL
+		ORDER BY "id" DESC
+		LIMIT 1`
+	if err := database.Query(m.db, SQL, &rows, nodeId, m.timeArg(from), m.timeArg(to)); err != nil {
+		return res, err
+	}
+	if len(rows) == 0 {
+		return res, nil // no data in this window, the node was down
+	}
+	latest := rows[0]
+
+	var stats []struct {
+		Runtime       int64
+		Samples       int64
+		NetworkHeight uint64
+	}
+
+	SQL = `
+		SELECT
+			` + runtimeGapsSumSQL + ` AS "runtime",
+			COUNT(*) AS "samples",
+			COALESCE(MAX("network_height"), 0) AS "network_height"
+		FROM (
+		SELECT
+			"network_height",
+			` + m.secondsBetweenSQL(`"created_at"`, `LAG("created_at") OVER w`) + ` AS "time_gap_seconds",
+			LAG("network_height") OVER w AS "prev_network_height"
+		FROM "celestia_nodes"
+		WHERE
+			"node_id" = ?
+			AND "created_at" >= ?
+			AND "created_at" < ?
+			AND "deleted_at" IS NULL
+		WINDOW w AS (ORDER BY "id")
+		) AS subquery`
+	args := []interface{}{m.heartbeatGapThreshold.Seconds(), nodeId, m.timeArg(from), m.timeArg(to)}
+
+	var err error
+	if isBucketWindow(res.From, res.To) && to.Before(time.Now().Add(-m.heartbeatGapThreshold)) {
+		// The samples of a past bucket do not change anymore, the other windows are not cached
+		// as there would be one entry for every from and to ever asked
+		err = database.CachedQuery(m.db, SQL, &stats, args...)
+	} else {
+		err = database.Query(m.db, SQL, &stats, args...)
+	}
+	if err != nil {
+		return res, err
+	}
+	if len(stats) == 0 {
+		return res, nil
+	}
+
+	// The node is expected to be up for the whole window, even if it started before it
+	if !latest.StartTime.IsZero() && latest.StartTime.Before(from) {
+		latest.StartTime = from
+	}
+
+	res.Runtime = stats[0].Runtime
+	res.Samples = stats[0].Samples
+	res.Uptime = m.uptimeScorer.Score(UptimeInput{
+		Node:          latest,
+		Runtime:       uint64(stats[0].Runtime),
+		NetworkHeight: stats[0].NetworkHeight,
+		StartTime:     from,
+		EndTime:       to,
+	})
+
+	return res, nil
+}
+
+// GetNodeUptimeBuckets computes the uptime of a node for every bucket overlapping [from, to)
+func (m *Metrics) GetNodeUptimeBuckets(nodeId string, bucket UptimeBucket, from, to time.Time) ([]models.NodeUptime, error) {
+
+	res := []models.NodeUptime{}
+
+	if err := checkBucketCount(bucket, from, to); err != nil {
+		return res, err
+	}
+
+	for start := bucket.Truncate(from); start.Before(to); {
+		end := bucket.Next(start)
+		uptime, err := m.GetNodeUptimeInWindow(nodeId, start, end)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, uptime)
+
+		start = end
+	}
+
+	return res, nil
+}
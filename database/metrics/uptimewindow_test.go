@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUptimeInWindowOnSQLite(t *testing.T) {
+
+	m := newSQLiteMetrics(t)
+
+	now := time.Now()
+	begin := now.Add(-20 * time.Minute).Truncate(time.Second)
+	addSamples(t, m, "node-1", every(begin, 30*time.Second, 38)...)
+
+	uptime, err := m.GetNodeUptimeInWindow("node-1", now.Add(-30*time.Minute), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uptime.Samples != 38 || uptime.Runtime != 37*30 {
+		t.Errorf("%d samples and a runtime of %d, want 38 and %d", uptime.Samples, uptime.Runtime, 37*30)
+	}
+	if uptime.Uptime <= 0 || uptime.Uptime > 100 {
+		t.Errorf("uptime %v, want a percentage", uptime.Uptime)
+	}
+
+	empty, err := m.GetNodeUptimeInWindow("node-1", now.Add(-2*time.Hour), now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.Samples != 0 || empty.Uptime != 0 {
+		t.Errorf("uptime %+v of a window without samples, want none", empty)
+	}
+}
+
+func TestUptimeBucketCount(t *testing.T) {
+
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		bucket   UptimeBucket
+		from, to time.Time
+		want     int64
+	}{
+		{UptimeBucketDay, day(2024, 5, 1), day(2024, 5, 2), 1},
+		{UptimeBucketDay, day(2024, 5, 1).Add(time.Hour), day(2024, 5, 2).Add(time.Hour), 2},
+		{UptimeBucketDay, day(2024, 5, 1), day(2024, 5, 1), 0},
+		{UptimeBucketDay, day(2024, 1, 1), day(2025, 1, 1), 366},
+		// 2024-05-01 is a Wednesday, its week starts on Monday 2024-04-29
+		{UptimeBucketWeek, day(2024, 5, 1), day(2024, 5, 6), 1},
+		{UptimeBucketWeek, day(2024, 5, 1), day(2024, 5, 7), 2},
+		{UptimeBucketMonth, day(2024, 5, 15), day(2024, 6, 1), 1},
+		{UptimeBucketMonth, day(2024, 5, 15), day(2024, 6, 2), 2},
+		{UptimeBucketMonth, day(2023, 11, 1), day(2024, 2, 1), 3},
+		{UptimeBucketDay, day(1, 1, 1), day(9999, 1, 1), MaxUptimeBuckets + 1},
+	}
+
+	for _, tt := range tests {
+		got := tt.bucket.Count(tt.from, tt.to)
+		if tt.want > MaxUptimeBuckets {
+			if got <= MaxUptimeBuckets {
+				t.Errorf("%s buckets of [%v, %v): %d, want more than %d", tt.bucket, tt.from, tt.to, got, MaxUptimeBuckets)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s buckets of [%v, %v): %d, want %d", tt.bucket, tt.from, tt.to, got, tt.want)
+		}
+
+		// the same count as going through them
+		n := int64(0)
+		for start := tt.bucket.Truncate(tt.from); start.Before(tt.to); start = tt.bucket.Next(start) {
+			n++
+		}
+		if got != n {
+			t.Errorf("%s buckets of [%v, %v): %d, %d going through them", tt.bucket, tt.from, tt.to, got, n)
+		}
+	}
+
+	m := newSQLiteMetrics(t)
+	if _, err := m.GetNodeUptimeBuckets("node-1", UptimeBucketDay, day(2000, 1, 1), day(2024, 1, 1)); !errors.Is(err, ErrTooManyBuckets) {
+		t.Errorf("error %v, want ErrTooManyBuckets", err)
+	}
+	if _, err := m.GetVersionAdoption(UptimeBucketDay, day(2000, 1, 1), day(2024, 1, 1), nil); !errors.Is(err, ErrTooManyBuckets) {
+		t.Errorf("error %v, want ErrTooManyBuckets", err)
+	}
+}
+
+func TestIsBucketWindow(t *testing.T) {
+
+	monday := time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		from, to time.Time
+		want     bool
+	}{
+		{monday, monday.AddDate(0, 0, 1), true},
+		{monday, monday.AddDate(0, 0, 7), true},
+		{time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), true},
+		{monday, monday.AddDate(0, 0, 2), false},
+		{monday.Add(time.Minute), monday.AddDate(0, 0, 1).Add(time.Minute), false},
+		{monday.AddDate(0, 0, 1), monday.AddDate(0, 0, 8), false}, // a week not starting on Monday
+	}
+	for _, tt := range tests {
+		if got := isBucketWindow(tt.from, tt.to); got != tt.want {
+			t.Errorf("[%v, %v) is a bucket: %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
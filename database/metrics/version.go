@@ -1,30 +0,0 @@
-package metrics
-
-import (
-	"fmt"
-
-	"github.com/celestiaorg/nodelogger/database"
-	"github.com/celestiaorg/nodelogger/database/models"
-)
-
-func (m *Metrics) GetNodesVersions(nodeId string) ([]models.NodeVersion, error) {
-
-	var rows []models.NodeVersion
-
-	SQL := fmt.Sprintf(`
-		SELECT 
-			"node_id",
-			"version",
-			MIN("created_at") AS "created_at"
-		FROM "celestia_nodes"
-		WHERE 
-			"node_id" = '%s'
-			AND "version" != ''
-		GROUP BY "node_id", "version"
-		ORDER BY "created_at" DESC`, nodeId)
-	if err := database.Query(m.db, SQL, &rows); err != nil {
-		return rows, err
-	}
-	return rows, nil
-
-}
@@ -0,0 +1,328 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// A node counts in the version figures if it sent metrics in this window before the time asked
+const DefaultVersionActiveWindow = 24 * time.Hour
+
+// Version is a semantic version as reported by the nodes, e.g. v0.9.4 or 0.10.0-rc1
+type Version struct {
+	Major, Minor, Patch uint64
+	Pre                 string // pre-release, e.g. rc1
+}
+
+// ParseVersion reads a semantic version, the leading v and the build metadata are ignored
+func ParseVersion(s string) (Version, error) {
+
+	var v Version
+
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		str, v.Pre = str[:i], str[i+1:]
+		if !validPrerelease(v.Pre) {
+			return v, fmt.Errorf("malformed version %q, expected e.g. v0.10.0-rc1", s)
+		}
+	}
+
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 || str == "" {
+		return v, fmt.Errorf("malformed version %q, expected e.g. v0.9.4", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return v, fmt.Errorf("malformed version %q, expected e.g. v0.9.4", s)
+		}
+		switch i {
+		case 0:
+			v.Major = n
+		case 1:
+			v.Minor = n
+		case 2:
+			v.Patch = n
+		}
+	}
+
+	return v, nil
+}
+
+// validPrerelease tells if the pre-release is a list of non empty dot separated identifiers of [0-9A-Za-z-]
+func validPrerelease(pre string) bool {
+	for _, id := range strings.Split(pre, ".") {
+		if id == "" {
+			return false
+		}
+		for _, c := range id {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Less tells if v comes before o, a pre-release comes before its release
+func (v Version) Less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor < o.Minor
+	}
+	if v.Patch != o.Patch {
+		return v.Patch < o.Patch
+	}
+	if v.Pre == "" || o.Pre == "" {
+		return v.Pre != "" && o.Pre == ""
+	}
+	return prereleaseLess(v.Pre, o.Pre)
+}
+
+// prereleaseLess compares the dot separated identifiers, the numeric ones numerically and before the others
+func prereleaseLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			return an < bn
+		case aErr == nil:
+			return true
+		case bErr == nil:
+			return false
+		}
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
+
+// VersionRange is a list of constraints a version must all meet, e.g. >=v0.9.0,<v0.10.0
+type VersionRange []VersionConstraint
+
+type VersionConstraint struct {
+	Op      string // one of >=, <=, >, <, =, !=
+	Version Version
+}
+
+// the longer operators first, so >= is not read as >
+var versionOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+// IsVersionRange tells if s is a range rather than an exact version
+func IsVersionRange(s string) bool {
+	s = strings.TrimSpace(s)
+	return s != "" && strings.ContainsAny(s[:1], "<>=!")
+}
+
+// ParseVersionRange reads a comma separated list of constraints, e.g. >=v0.9.0,<v0.10.0
+func ParseVersionRange(s string) (VersionRange, error) {
+
+	var r VersionRange
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+
+		op := ""
+		for _, o := range versionOps {
+			if strings.HasPrefix(part, o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("malformed version constraint %q, expected e.g. >=v0.9.0", part)
+		}
+
+		v, err := ParseVersion(strings.TrimPrefix(part, op))
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, VersionConstraint{Op: op, Version: v})
+	}
+
+	return r, nil
+}
+
+// Contains tells if v meets all the constraints of the range
+func (r VersionRange) Contains(v Version) bool {
+	for _, c := range r {
+		ok := true
+		switch c.Op {
+		case ">=":
+			ok = !v.Less(c.Version)
+		case "<=":
+			ok = !c.Version.Less(v)
+		case ">":
+			ok = c.Version.Less(v)
+		case "<":
+			ok = v.Less(c.Version)
+		case "=":
+			ok = v == c.Version
+		case "!=":
+			ok = v != c.Version
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// GetVersionCounts returns how many nodes ran every version in [from, to),
+// a node counts once, with the latest version it sent. A nil nType means all the node types.
+func (m *Metrics) GetVersionCounts(from, to time.Time, nType *receiver.NodeType) ([]models.VersionCount, error) {
+
+	rows := []models.VersionCount{}
+
+	typeFilter := ""
+	args := []interface{}{from.UTC(), to.UTC()}
+	if nType != nil {
+		typeFilter = `AND "node_type" = ?`
+		args = append(args, *nType)
+	}
+
+	// Written for both Postgres and SQLite
+	SQL := `
+		SELECT n."version", n."node_type", COUNT(*) AS "nodes"
+		FROM "celestia_nodes" n
+		WHERE n."id" IN (
+			SELECT MAX("id")
+			FROM "celestia_nodes"
+			WHERE
+				"created_at" >= ?
+				AND "created_at" < ?
+				AND "deleted_at" IS NULL
+				` + typeFilter + `
+			GROUP BY "node_id"
+		)
+		GROUP BY n."version", n."node_type"
+		ORDER BY "nodes" DESC, n."version" ASC, n."node_type" ASC`
+
+	err := database.Query(m.db, SQL, &rows, args...)
+	return rows, err
+}
+
+// GetVersionAdoption returns the number of nodes per version in every bucket of [from, to)
+func (m *Metrics) GetVersionAdoption(bucket UptimeBucket, from, to time.Time, nType *receiver.NodeType) ([]models.VersionAdoption, error) {
+
+	res := []models.VersionAdoption{}
+
+	if err := checkBucketCount(bucket, from, to); err != nil {
+		return res, err
+	}
+
+	for start := bucket.Truncate(from); start.Before(to); {
+		end := bucket.Next(start)
+		counts, err := m.GetVersionCounts(start, end, nType)
+		if err != nil {
+			return res, err
+		}
+
+		adoption := models.VersionAdoption{BucketStart: start, Versions: counts}
+		for _, c := range counts {
+			adoption.Nodes += c.Nodes
+		}
+		res = append(res, adoption)
+
+		start = end
+	}
+
+	return res, nil
+}
+
+// GetVersionReleases returns when every version was first seen on the network, the oldest first
+func (m *Metrics) GetVersionReleases(nType *receiver.NodeType) ([]models.VersionRelease, error) {
+
+	rows := []models.VersionRelease{}
+
+	typeFilter := ""
+	args := []interface{}{}
+	if nType != nil {
+		typeFilter = `AND "node_type" = ?`
+		args = append(args, *nType)
+	}
+
+	// The first row of every version rather than MIN("created_at"), SQLite loses the type of an aggregated time
+	SQL := `
+		SELECT
+			"version",
+			"created_at" AS "first_seen"
+		FROM "celestia_nodes"
+		WHERE "id" IN (
+			SELECT MIN("id")
+			FROM "celestia_nodes"
+			WHERE
+				"version" != ''
+				AND "deleted_at" IS NULL
+				` + typeFilter + `
+			GROUP BY "version"
+		)
+		ORDER BY "first_seen" ASC`
+
+	err := database.Query(m.db, SQL, &rows, args...)
+	return rows, err
+}
+
+// GetOutdatedNodes returns the nodes seen since activeSince whose latest version is older than minVersion,
+// the oldest versions first. The nodes whose version cannot be parsed are left out.
+func (m *Metrics) GetOutdatedNodes(minVersion Version, activeSince time.Time, nType *receiver.NodeType) ([]models.LatestNodeState, error) {
+
+	var states []models.LatestNodeState
+
+	tx := m.db.Where(`"last_seen_at" >= ?`, activeSince.UTC())
+	if nType != nil {
+		tx = tx.Where(`"node_type" = ?`, *nType)
+	}
+	if err := tx.Find(&states).Error; err != nil {
+		return nil, err
+	}
+
+	type outdated struct {
+		state   models.LatestNodeState
+		version Version
+	}
+	var found []outdated
+	for _, s := range states {
+		v, err := ParseVersion(s.Version)
+		if err == nil && v.Less(minVersion) {
+			found = append(found, outdated{s, v})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].version != found[j].version {
+			return found[i].version.Less(found[j].version)
+		}
+		return found[i].state.NodeId < found[j].state.NodeId
+	})
+
+	res := make([]models.LatestNodeState, 0, len(found))
+	for _, f := range found {
+		res = append(res, f.state)
+	}
+	return res, nil
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want Version
+		err  bool
+	}{
+		{in: "v0.9.4", want: Version{Minor: 9, Patch: 4}},
+		{in: "0.10.0", want: Version{Minor: 10}},
+		{in: " v1.2.3 ", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{in: "v1", want: Version{Major: 1}},
+		{in: "v1.2", want: Version{Major: 1, Minor: 2}},
+		{in: "v0.10.0-rc1", want: Version{Minor: 10, Pre: "rc1"}},
+		{in: "v0.10.0-rc.1", want: Version{Minor: 10, Pre: "rc.1"}},
+		{in: "v0.10.0-rc-1", want: Version{Minor: 10, Pre: "rc-1"}},
+		{in: "v0.10.0+build.5", want: Version{Minor: 10}},
+		{in: "v0.10.0-rc1+build-5", want: Version{Minor: 10, Pre: "rc1"}},
+		{in: "v0.10.0+", want: Version{Minor: 10}},
+
+		{in: "", err: true},
+		{in: "v", err: true},
+		{in: "+build", err: true},
+		{in: "latest", err: true},
+		{in: "v1.2.3.4", err: true},
+		{in: "v1..3", err: true},
+		{in: "v1.2.x", err: true},
+		{in: "v-1.2.3", err: true},
+		{in: "v1.2.3-", err: true},
+		{in: "v1.2.3-rc..1", err: true},
+		{in: "v1.2.3-rc_1", err: true},
+		{in: "v99999999999999999999.0.0", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseVersion(%q) error %v, want an error %v", tt.in, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func mustParseVersion(t *testing.T, s string) Version {
+	t.Helper()
+
+	v, err := ParseVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVersionLess(t *testing.T) {
+
+	// in the semver order, after the example of semver.org
+	ordered := []string{
+		"v0.9.0",
+		"v0.9.4",
+		"v0.10.0-alpha",
+		"v0.10.0-alpha.1",
+		"v0.10.0-alpha.beta",
+		"v0.10.0-beta",
+		"v0.10.0-beta.2",
+		"v0.10.0-beta.11",
+		"v0.10.0-rc.1",
+		"v0.10.0",
+		"v0.10.1",
+		"v1.0.0",
+	}
+
+	for i, a := range ordered {
+		for j, b := range ordered {
+			if got := mustParseVersion(t, a).Less(mustParseVersion(t, b)); got != (i < j) {
+				t.Errorf("%s < %s is %v, want %v", a, b, got, i < j)
+			}
+		}
+	}
+
+	// the build metadata does not take part in the order
+	if a, b := mustParseVersion(t, "v1.0.0+a"), mustParseVersion(t, "v1.0.0+b"); a.Less(b) || b.Less(a) {
+		t.Error("the versions differing by their build metadata only are ordered")
+	}
+}
+
+func TestPrereleaseLess(t *testing.T) {
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: "1", b: "2", want: true},
+		{a: "2", b: "10", want: true},     // numerically
+		{a: "10", b: "2", want: false},    // not as text
+		{a: "99", b: "alpha", want: true}, // the numeric identifiers first
+		{a: "alpha", b: "99", want: false},
+		{a: "alpha", b: "beta", want: true},
+		{a: "rc10", b: "rc9", want: true}, // a single identifier is compared as text
+		{a: "alpha", b: "alpha.1", want: true},
+		{a: "alpha.1", b: "alpha", want: false},
+		{a: "alpha.1", b: "alpha.1", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := prereleaseLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("prereleaseLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionRange(t *testing.T) {
+
+	tests := []struct {
+		in       string
+		contains []string
+		excludes []string
+		err      bool
+	}{
+		{in: ">=v0.9.0", contains: []string{"v0.9.0", "v0.9.1", "v1.0.0"}, excludes: []string{"v0.8.9", "v0.9.0-rc1"}},
+		{in: ">v0.9.0", contains: []string{"v0.9.1"}, excludes: []string{"v0.9.0", "v0.8.0"}},
+		{in: "<=v0.9.0", contains: []string{"v0.9.0", "v0.9.0-rc1", "v0.1.0"}, excludes: []string{"v0.9.1"}},
+		{in: "<v0.10.0", contains: []string{"v0.9.9", "v0.10.0-rc1"}, excludes: []string{"v0.10.0", "v0.10.1"}},
+		{in: "=v0.10.0", contains: []string{"v0.10.0", "0.10.0+build.1"}, excludes: []string{"v0.10.0-rc1", "v0.10.1"}},
+		{in: "!=v0.10.0", contains: []string{"v0.10.0-rc1", "v0.10.1"}, excludes: []string{"v0.10.0"}},
+		{in: ">=v0.9.0,<v0.10.0", contains: []string{"v0.9.0", "v0.9.9", "v0.10.0-rc1"}, excludes: []string{"v0.8.0", "v0.10.0"}}, // the pre-releases come before their release
+		{in: " >= v0.9.0 , != v0.9.2 ", contains: []string{"v0.9.1", "v0.9.3"}, excludes: []string{"v0.9.2"}},
+
+		{in: "", err: true},
+		{in: "v0.9.0", err: true},
+		{in: "~v0.9.0", err: true},
+		{in: "=>v0.9.0", err: true},
+		{in: ">=v0.9.0,", err: true},
+		{in: ">=", err: true},
+		{in: ">=latest", err: true},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseVersionRange(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseVersionRange(%q) error %v, want an error %v", tt.in, err, tt.err)
+			continue
+		}
+		for _, v := range tt.contains {
+			if !r.Contains(mustParseVersion(t, v)) {
+				t.Errorf("%q does not contain %s", tt.in, v)
+			}
+		}
+		for _, v := range tt.excludes {
+			if r.Contains(mustParseVersion(t, v)) {
+				t.Errorf("%q contains %s", tt.in, v)
+			}
+		}
+	}
+}
+
+func TestIsVersionRange(t *testing.T) {
+
+	for s, want := range map[string]bool{"": false, "v0.9.0": false, " >=v0.9.0": true, "<v1": true, "=v1": true, "!=v1": true} {
+		if got := IsVersionRange(s); got != want {
+			t.Errorf("IsVersionRange(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
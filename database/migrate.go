@@ -0,0 +1,325 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The migrations are embedded SQL files named <version>_<name>.up.sql and <version>_<name>.down.sql,
+// they run in the order of their versions. A migration without a down file, like the baseline, is irreversible.
+// A migration starting with the line
+//
+//	-- migrate: no-transaction
+//
+// runs outside of a transaction, one statement at a time, e.g. for CREATE INDEX CONCURRENTLY.
+// Such an index is built on every partition when the table is partitioned, and an invalid one
+// left over by an interrupted build is built again, see createIndexConcurrently.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const (
+	migrationsTable = "schema_migrations"
+
+	noTransactionMarker = "-- migrate: no-transaction"
+
+	// Key of the advisory lock taken while migrating, so only one instance migrates at a time
+	migrationLockKey = 7361626931
+)
+
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   uint64     `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"` // nil if pending
+	Missing   bool       `json:"missing"`    // applied but not known to this build
+}
+
+type appliedMigration struct {
+	Version   uint64
+	Name      string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return migrationsTable
+}
+
+// LoadMigrations returns the embedded migrations sorted by version
+func LoadMigrations() ([]Migration, error) {
+
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, e := range entries {
+		fileName := e.Name()
+
+		base, direction := "", ""
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			base, direction = strings.TrimSuffix(fileName, ".up.sql"), "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			base, direction = strings.TrimSuffix(fileName, ".down.sql"), "down"
+		default:
+			return nil, fmt.Errorf("migration %q: expected a .up.sql or .down.sql file", fileName)
+		}
+
+		versionStr, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(versionStr, 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %q: malformed version", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+
+	return res, nil
+}
+
+func ensureMigrationsTable(db *gorm.DB) error {
+	return db.Exec(`
+		CREATE TABLE IF NOT EXISTS "` + migrationsTable + `" (
+			"version" bigint PRIMARY KEY,
+			"name" varchar(255) NOT NULL,
+			"applied_at" timestamptz NOT NULL DEFAULT NOW()
+		)`).Error
+}
+
+func appliedMigrations(db *gorm.DB) (map[uint64]appliedMigration, error) {
+
+	var rows []appliedMigration
+	if err := db.Order(`"version" ASC`).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	res := map[uint64]appliedMigration{}
+	for _, r := range rows {
+		res[r.Version] = r
+	}
+	return res, nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration lock
+func withMigrationLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+
+	return db.Connection(func(conn *gorm.DB) error {
+
+		if err := conn.Exec(`SELECT PG_ADVISORY_LOCK(?)`, migrationLockKey).Error; err != nil {
+			return err
+		}
+		defer conn.Exec(`SELECT PG_ADVISORY_UNLOCK(?)`, migrationLockKey)
+
+		if err := ensureMigrationsTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// runMigrationSQL runs a migration script in a transaction along with recording it,
+// or statement by statement if the script asks for no transaction
+func runMigrationSQL(db *gorm.DB, SQL string, record func(tx *gorm.DB) error) error {
+
+	if !strings.HasPrefix(strings.TrimSpace(SQL), noTransactionMarker) {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(SQL).Error; err != nil {
+				return err
+			}
+			return record(tx)
+		})
+	}
+
+	for _, stmt := range strings.Split(SQL, ";\n") {
+		if strings.TrimSpace(stripSQLComments(stmt)) == "" {
+			continue
+		}
+		if err := runMigrationStatement(db, stmt); err != nil {
+			return err
+		}
+	}
+	return record(db)
+}
+
+func stripSQLComments(SQL string) string {
+	lines := strings.Split(SQL, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "--") {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// MigrateUp applies the pending migrations up to the target version, a zero target means all of them
+func MigrateUp(db *gorm.DB, target uint64) ([]Migration, error) {
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if target != 0 && m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			m := m
+			err := runMigrationSQL(conn, m.Up, func(tx *gorm.DB) error {
+				return tx.Create(&appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %v", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// MigrateDown rolls back the latest applied migrations, steps of them
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint64]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	done := []Migration{}
+	err = withMigrationLock(db, func(conn *gorm.DB) error {
+
+		applied, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]uint64, 0, len(applied))
+		for v := range applied {
+			versions = append(versions, v)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		if steps > len(versions) {
+			steps = len(versions)
+		}
+		versions = versions[:steps]
+
+		// nothing is rolled back if one of the steps can not be
+		for _, v := range versions {
+			m, ok := byVersion[v]
+			if !ok {
+				return fmt.Errorf("migration %d_%s is not known to this build, it can not be rolled back", v, applied[v].Name)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s is irreversible, it has no down file", m.Version, m.Name)
+			}
+		}
+
+		for _, v := range versions {
+			m := byVersion[v]
+			err := runMigrationSQL(conn, m.Down, func(tx *gorm.DB) error {
+				return tx.Where(`"version" = ?`, m.Version).Delete(&appliedMigration{}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %v", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// GetMigrationStatus lists all the known and applied migrations sorted by version
+func GetMigrationStatus(db *gorm.DB) ([]MigrationStatus, error) {
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []MigrationStatus{}
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			appliedAt := a.AppliedAt
+			s.AppliedAt = &appliedAt
+			delete(applied, m.Version)
+		}
+		res = append(res, s)
+	}
+	for _, a := range applied {
+		appliedAt := a.AppliedAt
+		res = append(res, MigrationStatus{Version: a.Version, Name: a.Name, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+
+	return res, nil
+}
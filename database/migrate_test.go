@@ -0,0 +1,151 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrations(t *testing.T) {
+
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range migrations {
+		if m.Version != uint64(i+1) {
+			t.Errorf("migration %d_%s: expected version %d, the versions follow each other", m.Version, m.Name, i+1)
+		}
+		if i > 0 && m.Down == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+
+		for direction, SQL := range map[string]string{"up": m.Up, "down": m.Down} {
+			if !strings.HasPrefix(strings.TrimSpace(SQL), noTransactionMarker) {
+				if strings.Contains(strings.ToUpper(SQL), "CONCURRENTLY") {
+					t.Errorf("migration %d_%s %s: CONCURRENTLY can not run in a transaction", m.Version, m.Name, direction)
+				}
+				continue
+			}
+
+			statements := 0
+			for _, stmt := range strings.Split(SQL, ";\n") {
+				stmt = strings.TrimSuffix(strings.TrimSpace(stripSQLComments(stmt)), ";")
+				if stmt == "" {
+					continue
+				}
+				statements++
+
+				// these go through the partition aware runner
+				if strings.Contains(strings.ToUpper(stmt), "CONCURRENTLY") && !createIndexRe.MatchString(stmt) && !dropIndexRe.MatchString(stmt) {
+					t.Errorf("migration %d_%s %s: %q is not recognized as a concurrent index build or drop", m.Version, m.Name, direction, stmt)
+				}
+				if createIndexRe.MatchString(stmt) && strings.Contains(strings.ToUpper(stmt), "IF NOT EXISTS") {
+					t.Errorf("migration %d_%s %s: IF NOT EXISTS would keep an invalid index", m.Version, m.Name, direction)
+				}
+			}
+			if statements != 1 {
+				t.Errorf("migration %d_%s %s: %d statements outside of a transaction, expected one", m.Version, m.Name, direction, statements)
+			}
+		}
+	}
+
+	if len(migrations) == 0 || migrations[0].Down != "" {
+		t.Error("the baseline must be irreversible")
+	}
+}
+
+func TestConcurrentIndexStatements(t *testing.T) {
+
+	m := createIndexRe.FindStringSubmatch(`CREATE UNIQUE INDEX CONCURRENTLY "idx_celestia_nodes_node_id_id" ON "celestia_nodes" ("node_id", "id")`)
+	if m == nil || m[1] == "" || m[2] != "idx_celestia_nodes_node_id_id" || m[3] != "celestia_nodes" || m[4] != `("node_id", "id")` {
+		t.Errorf("create statement parsed as %q", m)
+	}
+	if m := dropIndexRe.FindStringSubmatch(`DROP INDEX CONCURRENTLY IF EXISTS "idx_celestia_nodes_node_id_id"`); m == nil || m[1] != "idx_celestia_nodes_node_id_id" {
+		t.Errorf("drop statement parsed as %q", m)
+	}
+	if createIndexRe.MatchString(`CREATE INDEX "idx_celestia_nodes_node_id_id" ON "celestia_nodes" ("node_id", "id")`) {
+		t.Error("a plain CREATE INDEX is taken as a concurrent one")
+	}
+
+	idx := concurrentIndex{name: "idx_celestia_nodes_node_id_id", table: "celestia_nodes"}
+	if name := partitionIndexName(idx, "celestia_nodes_p202401"); name != "celestia_nodes_p202401_node_id_id" {
+		t.Errorf("partition index named %q", name)
+	}
+}
+
+// The concurrent indexes are built on the partitions of a converted table and attached to the parent index
+func TestMigrateUpPartitioned(t *testing.T) {
+
+	db := newTestPostgres(t)
+
+	if _, err := MigrateUp(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	insertTestSamples(t, db, "node-1", now.AddDate(0, -2, 0), now.AddDate(0, -1, 0), now)
+	if err := ConvertToPartitioned(db, PartitionedTable, DefaultPartitionsAhead); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	partitions, err := listPartitionTables(db, PartitionedTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"idx_celestia_nodes_node_id_id", "idx_celestia_nodes_uptime_id", "idx_celestia_nodes_node_type_uptime_id", "idx_celestia_nodes_node_id_created_at_id"} {
+		state, err := getIndexState(db, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !state.Exists || !state.Valid || !state.Partitioned {
+			t.Errorf("index %s: %+v, want a valid partitioned index", name, state)
+		}
+		for _, p := range partitions {
+			child := partitionIndexName(concurrentIndex{name: name, table: PartitionedTable}, p)
+			if state, err := getIndexState(db, child); err != nil || !state.Valid {
+				t.Errorf("index %s of partition %s: %+v (err: %v), want a valid one", child, p, state, err)
+			}
+		}
+	}
+
+	// and they can be rolled back
+	if _, err := MigrateDown(db, 8); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := getIndexState(db, "idx_celestia_nodes_node_id_id"); err != nil || state.Exists {
+		t.Errorf("index still there after the rollback: %+v (err: %v)", state, err)
+	}
+}
+
+// An index left invalid by an interrupted build is built again instead of being recorded as applied
+func TestMigrateUpInvalidIndex(t *testing.T) {
+
+	db := newTestPostgres(t)
+
+	if _, err := MigrateUp(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	insertTestSamples(t, db, "node-1", now, now)
+
+	// a unique index on duplicated rows fails half way and stays behind, invalid
+	SQL := `CREATE UNIQUE INDEX CONCURRENTLY "idx_celestia_nodes_node_id_id" ON "celestia_nodes" ("node_id")`
+	if err := db.Exec(SQL).Error; err == nil {
+		t.Fatal("the unique index was built on duplicated rows")
+	}
+	if state, err := getIndexState(db, "idx_celestia_nodes_node_id_id"); err != nil || !state.Exists || state.Valid {
+		t.Fatalf("index %+v (err: %v), want an invalid one left behind", state, err)
+	}
+
+	if _, err := MigrateUp(db, 3); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := getIndexState(db, "idx_celestia_nodes_node_id_id"); err != nil || !state.Valid {
+		t.Errorf("index %+v (err: %v), want it built again and valid", state, err)
+	}
+}
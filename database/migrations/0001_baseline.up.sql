@@ -0,0 +1,33 @@
+-- The schema gorm AutoMigrate used to create, the existing databases already have it
+CREATE TABLE IF NOT EXISTS "celestia_nodes" (
+	"id" bigserial,
+	"created_at" timestamptz,
+	"updated_at" timestamptz,
+	"deleted_at" timestamptz,
+	"node_id" varchar(255) NOT NULL,
+	"node_type" bigint,
+	"version" varchar(255),
+	"last_pfb_timestamp" timestamptz,
+	"pfb_count" bigint,
+	"head" bigint,
+	"network_height" bigint,
+	"das_latest_sampled_timestamp" timestamptz,
+	"das_network_head" bigint,
+	"das_sampled_chain_head" bigint,
+	"das_sampled_headers_counter" bigint,
+	"das_total_sampled_headers" bigint,
+	"total_synced_headers" bigint,
+	"start_time" timestamptz,
+	"last_restart_time" timestamptz,
+	"node_runtime_counter_in_seconds" bigint,
+	"last_accumulative_node_runtime_counter_in_seconds" bigint,
+	"uptime" decimal,
+	"new_uptime" decimal,
+	"new_runtime" bigint,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_celestia_nodes_network_height" ON "celestia_nodes" ("network_height");
+CREATE INDEX IF NOT EXISTS "idx_celestia_nodes_version" ON "celestia_nodes" ("version");
+CREATE INDEX IF NOT EXISTS "idx_celestia_nodes_node_id" ON "celestia_nodes" ("node_id");
+CREATE INDEX IF NOT EXISTS "idx_celestia_nodes_deleted_at" ON "celestia_nodes" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_celestia_nodes_created_at" ON "celestia_nodes" ("created_at");
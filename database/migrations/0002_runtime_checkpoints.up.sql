@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "node_runtime_checkpoints" (
+	"node_id" varchar(255),
+	"last_row_id" bigint NOT NULL DEFAULT 0,
+	"last_created_at" timestamptz,
+	"last_network_height" bigint,
+	"runtime" bigint NOT NULL DEFAULT 0,
+	"gap_threshold" decimal NOT NULL DEFAULT 0.000000,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("node_id")
+);
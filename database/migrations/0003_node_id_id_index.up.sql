@@ -0,0 +1,3 @@
+-- migrate: no-transaction
+-- the runtime checkpoints read the new rows of a node from its last row id
+CREATE INDEX CONCURRENTLY "idx_celestia_nodes_node_id_id" ON "celestia_nodes" ("node_id", "id");
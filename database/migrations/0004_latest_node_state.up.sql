@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "latest_node_state" (
+	"node_id" varchar(255),
+	"last_row_id" bigint NOT NULL DEFAULT 0,
+	"node_type" bigint,
+	"version" varchar(255),
+	"uptime" decimal,
+	"last_seen_at" timestamptz,
+	PRIMARY KEY ("node_id")
+);
+CREATE INDEX IF NOT EXISTS "idx_latest_node_state_rank" ON "latest_node_state" ("node_type", "uptime" DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "node_rollups_daily";
+DROP TABLE IF EXISTS "node_rollups_hourly";
@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS "node_rollups_hourly" (
+	"node_id" varchar(255),
+	"bucket_start" timestamptz,
+	"node_type" bigint,
+	"samples" bigint,
+	"online_seconds" bigint,
+	"head_min" bigint,
+	"head_max" bigint,
+	"head_last" bigint,
+	"network_height_min" bigint,
+	"network_height_max" bigint,
+	"network_height_last" bigint,
+	"das_sampled_chain_head_min" bigint,
+	"das_sampled_chain_head_max" bigint,
+	"das_sampled_chain_head_last" bigint,
+	"pfb_count_min" bigint,
+	"pfb_count_max" bigint,
+	"pfb_count_last" bigint,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("node_id", "bucket_start")
+);
+
+CREATE TABLE IF NOT EXISTS "node_rollups_daily" (
+	"node_id" varchar(255),
+	"bucket_start" timestamptz,
+	"node_type" bigint,
+	"samples" bigint,
+	"online_seconds" bigint,
+	"head_min" bigint,
+	"head_max" bigint,
+	"head_last" bigint,
+	"network_height_min" bigint,
+	"network_height_max" bigint,
+	"network_height_last" bigint,
+	"das_sampled_chain_head_min" bigint,
+	"das_sampled_chain_head_max" bigint,
+	"das_sampled_chain_head_last" bigint,
+	"pfb_count_min" bigint,
+	"pfb_count_max" bigint,
+	"pfb_count_last" bigint,
+	"updated_at" timestamptz,
+	PRIMARY KEY ("node_id", "bucket_start")
+);
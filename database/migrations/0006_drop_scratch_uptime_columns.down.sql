@@ -0,0 +1,3 @@
+ALTER TABLE "celestia_nodes"
+	ADD COLUMN IF NOT EXISTS "new_uptime" decimal,
+	ADD COLUMN IF NOT EXISTS "new_runtime" bigint;
@@ -0,0 +1,4 @@
+-- new_uptime and new_runtime only hold the scratch work of `uptime recompute`, they are never stored
+ALTER TABLE "celestia_nodes"
+	DROP COLUMN IF EXISTS "new_uptime",
+	DROP COLUMN IF EXISTS "new_runtime";
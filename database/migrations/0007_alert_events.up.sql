@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "alert_events" (
+	"id" bigserial,
+	"rule" varchar(255) NOT NULL,
+	"node_id" varchar(255) NOT NULL,
+	"node_type" bigint,
+	"state" varchar(16) NOT NULL,
+	"value" decimal,
+	"threshold" decimal,
+	"message" text,
+	"created_at" timestamptz,
+	PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_alert_events_rule_node" ON "alert_events" ("rule", "node_id");
+CREATE INDEX IF NOT EXISTS "idx_alert_events_created_at" ON "alert_events" ("created_at");
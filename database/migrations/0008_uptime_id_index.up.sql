@@ -0,0 +1,3 @@
+-- migrate: no-transaction
+-- the keyset pages of the samples ranked by uptime
+CREATE INDEX CONCURRENTLY "idx_celestia_nodes_uptime_id" ON "celestia_nodes" ("uptime", "id");
@@ -0,0 +1,2 @@
+-- migrate: no-transaction
+DROP INDEX CONCURRENTLY IF EXISTS "idx_celestia_nodes_node_type_uptime_id";
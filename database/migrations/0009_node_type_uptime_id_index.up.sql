@@ -0,0 +1,3 @@
+-- migrate: no-transaction
+-- the keyset pages of the samples of a node type ranked by uptime
+CREATE INDEX CONCURRENTLY "idx_celestia_nodes_node_type_uptime_id" ON "celestia_nodes" ("node_type", "uptime", "id");
@@ -0,0 +1,3 @@
+-- migrate: no-transaction
+-- the keyset pages of the samples of a node listed by time
+CREATE INDEX CONCURRENTLY "idx_celestia_nodes_node_id_created_at_id" ON "celestia_nodes" ("node_id", "created_at", "id");
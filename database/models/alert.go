@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+)
+
+// AlertEvent is a transition of an alert, it fires when a node meets the condition of a rule
+// and resolves when it does not anymore
+type AlertEvent struct {
+	ID        uint              `gorm:"primarykey" json:"id"`
+	Rule      string            `gorm:"index:idx_alert_events_rule_node,priority:1;type:varchar(255);not null" json:"rule"`
+	NodeId    string            `gorm:"index:idx_alert_events_rule_node,priority:2;type:varchar(255);not null" json:"node_id"`
+	NodeType  receiver.NodeType `json:"node_type"`
+	State     string            `gorm:"type:varchar(16);not null" json:"state"` // firing or resolved
+	Value     float64           `json:"value"`
+	Threshold float64           `json:"threshold"`
+	Message   string            `json:"message"`
+	CreatedAt time.Time         `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// NodeRuntimeCheckpoint keeps the runtime accumulated by a node up to a given row,
+// so the runtime can be extended with the new rows only, instead of going through the whole history
+type NodeRuntimeCheckpoint struct {
+	NodeId            string    `gorm:"primarykey;type:varchar(255)" json:"node_id"`
+	LastRowId         uint      `gorm:"not null;default:0" json:"last_row_id"`
+	LastCreatedAt     time.Time `json:"last_created_at"`
+	LastNetworkHeight uint64    `json:"last_network_height"`
+	Runtime           int64     `gorm:"not null;default:0" json:"runtime"`       // seconds
+	GapThreshold      float64   `gorm:"not null;default:0" json:"gap_threshold"` // seconds, the heartbeat gap threshold the runtime is computed with
+	UpdatedAt         time.Time `json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+)
+
+// LatestNodeState points at the latest sample of every node, so the leaderboard
+// does not have to go through the whole history of the nodes
+type LatestNodeState struct {
+	NodeId     string            `gorm:"primarykey;type:varchar(255)" json:"node_id"`
+	LastRowId  uint              `gorm:"not null;default:0" json:"last_row_id"` // id of the latest sample in celestia_nodes
+	NodeType   receiver.NodeType `gorm:"index:idx_latest_node_state_rank,priority:1" json:"node_type"`
+	Version    string            `gorm:"type:varchar(255)" json:"version"`
+	Uptime     float32           `gorm:"index:idx_latest_node_state_rank,priority:2,sort:desc" json:"uptime"`
+	LastSeenAt time.Time         `json:"last_seen_at"`
+}
+
+func (LatestNodeState) TableName() string {
+	return "latest_node_state"
+}
+
+// LeaderboardEntry is the latest sample of a node along with its rank
+type LeaderboardEntry struct {
+	Rank int `json:"rank"`
+	CelestiaNode
+}
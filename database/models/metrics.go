@@ -9,14 +9,14 @@ import (
 
 type CelestiaNode struct {
 	// gorm.Model:
-	ID        uint      `gorm:"primarykey"`
-	CreatedAt time.Time `gorm:"index"`
+	ID        uint      `gorm:"primarykey;index:idx_celestia_nodes_node_id_id,priority:2;index:idx_celestia_nodes_uptime_id,priority:2;index:idx_celestia_nodes_node_type_uptime_id,priority:3;index:idx_celestia_nodes_node_id_created_at_id,priority:3"`
+	CreatedAt time.Time `gorm:"index;index:idx_celestia_nodes_node_id_created_at_id,priority:2"`
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 	// ----------
-	NodeId                                      string `gorm:"index;type:varchar(255);not null"`
-	NodeType                                    receiver.NodeType
-	Version                                     string `gorm:"index;type:varchar(255);"`
+	NodeId                                      string            `gorm:"index;index:idx_celestia_nodes_node_id_id,priority:1;index:idx_celestia_nodes_node_id_created_at_id,priority:1;type:varchar(255);not null"`
+	NodeType                                    receiver.NodeType `gorm:"index:idx_celestia_nodes_node_type_uptime_id,priority:1"`
+	Version                                     string            `gorm:"index;type:varchar(255);"`
 	LastPfbTimestamp                            time.Time
 	PfbCount                                    uint64
 	Head                                        uint64
@@ -31,12 +31,62 @@ type CelestiaNode struct {
 	LastRestartTime                             time.Time
 	NodeRuntimeCounterInSeconds                 uint64
 	LastAccumulativeNodeRuntimeCounterInSeconds uint64
-	Uptime                                      float32
-	NewUptime                                   float32
-	NewRuntime                                  int64
+	Uptime                                      float32 `gorm:"index:idx_celestia_nodes_uptime_id,priority:1;index:idx_celestia_nodes_node_type_uptime_id,priority:2"`
+	NewUptime                                   float32 `gorm:"-"` // scratch work of the uptime recompute, not stored
+	NewRuntime                                  int64   `gorm:"-"`
 }
 type NodeVersion struct {
 	NodeId    string    `json:"node_id"`
 	Version   string    `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// VersionCount is the number of nodes of a type running a version
+type VersionCount struct {
+	Version  string            `json:"version"`
+	NodeType receiver.NodeType `json:"node_type"`
+	Nodes    int64             `json:"nodes"`
+}
+
+// VersionAdoption is the number of nodes running every version in a bucket of time,
+// a node counts once, with the latest version it sent in the bucket
+type VersionAdoption struct {
+	BucketStart time.Time      `json:"bucket_start"`
+	Nodes       int64          `json:"nodes"` // all the nodes seen in the bucket
+	Versions    []VersionCount `json:"versions"`
+}
+
+// VersionRelease is when a version was first seen on the network
+type VersionRelease struct {
+	Version   string    `json:"version"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// NodeGap is a period the node has not sent any metrics (heartbeat) for longer than the threshold
+type NodeGap struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Ongoing         bool      `json:"ongoing"` // no metrics received since Start
+}
+
+// NodeUptime is the uptime of a node in a time window
+type NodeUptime struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Uptime  float32   `json:"uptime"`
+	Runtime int64     `json:"runtime_seconds"`
+	Samples int64     `json:"samples"` // the number of metrics received in the window
+}
+
+// NodeTimelineEvent is something that happened to a node, the gaps and stalls last from Time to End
+type NodeTimelineEvent struct {
+	Type            string     `json:"type"`
+	Time            time.Time  `json:"time"`
+	End             *time.Time `json:"end,omitempty"`
+	DurationSeconds float64    `json:"duration_seconds,omitempty"`
+	Ongoing         bool       `json:"ongoing,omitempty"` // still going on at the end of the window
+	From            string     `json:"from,omitempty"`    // the value before the event, e.g. the previous version
+	To              string     `json:"to,omitempty"`
+	Detail          string     `json:"detail"`
+}
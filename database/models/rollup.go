@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+)
+
+// NodeRollup summarizes the samples of a node in one bucket of time
+type NodeRollup struct {
+	NodeId        string            `gorm:"primarykey;type:varchar(255)" json:"node_id"`
+	BucketStart   time.Time         `gorm:"primarykey" json:"bucket_start"`
+	NodeType      receiver.NodeType `json:"node_type"`
+	Samples       int64             `json:"samples"`
+	OnlineSeconds int64             `json:"online_seconds"`
+
+	HeadMin                 uint64 `json:"head_min"`
+	HeadMax                 uint64 `json:"head_max"`
+	HeadLast                uint64 `json:"head_last"`
+	NetworkHeightMin        uint64 `json:"network_height_min"`
+	NetworkHeightMax        uint64 `json:"network_height_max"`
+	NetworkHeightLast       uint64 `json:"network_height_last"`
+	DasSampledChainHeadMin  uint64 `json:"das_sampled_chain_head_min"`
+	DasSampledChainHeadMax  uint64 `json:"das_sampled_chain_head_max"`
+	DasSampledChainHeadLast uint64 `json:"das_sampled_chain_head_last"`
+	PfbCountMin             uint64 `json:"pfb_count_min"`
+	PfbCountMax             uint64 `json:"pfb_count_max"`
+	PfbCountLast            uint64 `json:"pfb_count_last"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type HourlyNodeRollup struct {
+	NodeRollup
+}
+
+func (HourlyNodeRollup) TableName() string {
+	return "node_rollups_hourly"
+}
+
+type DailyNodeRollup struct {
+	NodeRollup
+}
+
+func (DailyNodeRollup) TableName() string {
+	return "node_rollups_daily"
+}
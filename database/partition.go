@@ -0,0 +1,418 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The metrics table is partitioned by month on `created_at`, the partitions are named <table>_pYYYYMM
+const (
+	PartitionedTable = "celestia_nodes"
+
+	partitionNameLayout  = "200601"
+	partitionBoundLayout = "2006-01-02 15:04:05Z07:00"
+
+	// Partitions are created this far ahead, so there is always one for the incoming rows
+	DefaultPartitionsAhead = 31 * 24 * time.Hour
+
+	DefaultPartitionMaintenanceInterval = time.Hour
+)
+
+// RetentionPolicy tells how long the rows are kept, zero days means forever.
+// With Detach the old partitions are detached and kept as standalone tables instead of being dropped.
+type RetentionPolicy struct {
+	Days   int
+	Detach bool
+}
+
+type Partition struct {
+	Name      string    `json:"name"`
+	From      time.Time `json:"from"` // inclusive
+	To        time.Time `json:"to"`   // exclusive
+	Rows      int64     `json:"rows"` // estimated
+	SizeBytes int64     `json:"size_bytes"`
+}
+
+// MonthStart returns the beginning of the month t falls in, in UTC
+func MonthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func partitionName(table string, monthStart time.Time) string {
+	return table + "_p" + monthStart.Format(partitionNameLayout)
+}
+
+// parsePartitionName returns the month of a monthly partition of the table, false for the other tables
+func parsePartitionName(table, name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, table+"_p") {
+		return time.Time{}, false
+	}
+	month, err := time.Parse(partitionNameLayout, strings.TrimPrefix(name, table+"_p"))
+	return month, err == nil
+}
+
+// partitionBounds returns the bounds of the partition of the month as SQL literals, the upper one is exclusive
+func partitionBounds(month time.Time) (lower, upper string) {
+	return month.Format(partitionBoundLayout), month.AddDate(0, 1, 0).Format(partitionBoundLayout)
+}
+
+// retentionCutoff returns the time the rows are kept from with the given retention
+func retentionCutoff(now time.Time, days int) time.Time {
+	return now.AddDate(0, 0, -days)
+}
+
+// expired tells if all the rows the partition can hold are before cutoff
+func (p Partition) expired(cutoff time.Time) bool {
+	return !p.To.After(cutoff)
+}
+
+// The default partition takes the rows no monthly partition covers, e.g. a sample with a skewed clock,
+// they are moved to their monthly partitions once these are created
+func defaultPartitionName(table string) string {
+	return table + "_default"
+}
+
+// oldestInDefault returns the creation time of the oldest row of the default partition,
+// zero if it is empty or was not created yet
+func oldestInDefault(db *gorm.DB, table string) (time.Time, error) {
+
+	var exists []struct{ Exists bool }
+	if err := Query(db, `SELECT TO_REGCLASS(?) IS NOT NULL AS "exists"`, &exists, defaultPartitionName(table)); err != nil {
+		return time.Time{}, err
+	}
+	if len(exists) == 0 || !exists[0].Exists {
+		return time.Time{}, nil
+	}
+
+	var rows []struct{ CreatedAt time.Time }
+
+	SQL := fmt.Sprintf(`SELECT "created_at" FROM %q WHERE "created_at" IS NOT NULL ORDER BY "created_at" ASC LIMIT 1`, defaultPartitionName(table))
+	if err := Query(db, SQL, &rows); err != nil {
+		return time.Time{}, err
+	}
+	if len(rows) == 0 {
+		return time.Time{}, nil
+	}
+	return rows[0].CreatedAt, nil
+}
+
+// IsPartitioned tells if the table is a partitioned table
+func IsPartitioned(db *gorm.DB, table string) (bool, error) {
+
+	var rows []struct{ Relkind string }
+
+	SQL := `SELECT "relkind" FROM "pg_class" WHERE "oid" = TO_REGCLASS(?)`
+	if err := Query(db, SQL, &rows, table); err != nil {
+		return false, err
+	}
+	if len(rows) == 0 {
+		return false, fmt.Errorf("table %q not found", table)
+	}
+
+	return rows[0].Relkind == "p", nil
+}
+
+// ListPartitions lists the monthly partitions of the table sorted by time,
+// the partitions which do not follow the naming are skipped
+func ListPartitions(db *gorm.DB, table string) ([]Partition, error) {
+
+	var rows []struct {
+		Name      string
+		Rows      float64
+		SizeBytes int64
+	}
+
+	SQL := `
+		SELECT
+			c."relname" AS "name",
+			c."reltuples" AS "rows",
+			PG_TOTAL_RELATION_SIZE(c."oid") AS "size_bytes"
+		FROM
+			"pg_inherits" i
+			INNER JOIN "pg_class" c ON c."oid" = i."inhrelid"
+		WHERE i."inhparent" = TO_REGCLASS(?)
+		ORDER BY c."relname" ASC`
+	if err := Query(db, SQL, &rows, table); err != nil {
+		return nil, err
+	}
+
+	res := []Partition{}
+	for _, r := range rows {
+		month, ok := parsePartitionName(table, r.Name)
+		if !ok {
+			continue
+		}
+
+		// reltuples is -1 for the tables never analyzed
+		estimatedRows := int64(r.Rows)
+		if estimatedRows < 0 {
+			estimatedRows = 0
+		}
+
+		res = append(res, Partition{
+			Name:      r.Name,
+			From:      month,
+			To:        month.AddDate(0, 1, 0),
+			Rows:      estimatedRows,
+			SizeBytes: r.SizeBytes,
+		})
+	}
+
+	return res, nil
+}
+
+// EnsurePartitions creates the default partition and the missing monthly partitions of the table covering [from, to].
+// The rows of a new partition's month which landed in the default partition are moved into it.
+func EnsurePartitions(db *gorm.DB, table string, from, to time.Time) ([]string, error) {
+
+	created := []string{}
+
+	SQL := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q PARTITION OF %q DEFAULT`, defaultPartitionName(table), table)
+	if err := db.Exec(SQL).Error; err != nil {
+		return created, fmt.Errorf("creating the default partition: %v", err)
+	}
+
+	for month := MonthStart(from); !month.After(to); month = month.AddDate(0, 1, 0) {
+
+		name := partitionName(table, month)
+
+		var exists []struct{ Exists bool }
+		if err := Query(db, `SELECT TO_REGCLASS(?) IS NOT NULL AS "exists"`, &exists, name); err != nil {
+			return created, err
+		}
+		if len(exists) > 0 && exists[0].Exists {
+			continue
+		}
+
+		if err := createPartition(db, table, name, month); err != nil {
+			return created, fmt.Errorf("creating partition %q: %v", name, err)
+		}
+		created = append(created, name)
+	}
+
+	return created, nil
+}
+
+// createPartition creates the partition of the month. Postgres refuses a new partition while the default one
+// holds rows of its range, so these rows are moved into the partition before it is attached.
+func createPartition(db *gorm.DB, table, name string, month time.Time) error {
+
+	// DDL does not take bound parameters, the names and bounds are built from times only
+	lower, upper := partitionBounds(month)
+	defaultName := defaultPartitionName(table)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		// no row can land in the default partition until the new one is attached
+		if err := tx.Exec(fmt.Sprintf(`LOCK TABLE %q IN SHARE ROW EXCLUSIVE MODE`, defaultName)).Error; err != nil {
+			return err
+		}
+
+		var stray []struct{ Exists bool }
+		SQL := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %q WHERE "created_at" >= '%s' AND "created_at" < '%s') AS "exists"`, defaultName, lower, upper)
+		if err := Query(tx, SQL, &stray); err != nil {
+			return err
+		}
+
+		if len(stray) == 0 || !stray[0].Exists {
+			SQL = fmt.Sprintf(`CREATE TABLE %q PARTITION OF %q FOR VALUES FROM ('%s') TO ('%s')`, name, table, lower, upper)
+			return tx.Exec(SQL).Error
+		}
+
+		// attaching creates the indexes of the parent table on the partition
+		stmts := []string{
+			fmt.Sprintf(`CREATE TABLE %q (LIKE %q INCLUDING DEFAULTS)`, name, table),
+			fmt.Sprintf(`WITH "moved" AS (DELETE FROM %q WHERE "created_at" >= '%s' AND "created_at" < '%s' RETURNING *) INSERT INTO %q SELECT * FROM "moved"`,
+				defaultName, lower, upper, name),
+			fmt.Sprintf(`ALTER TABLE %q ATTACH PARTITION %q FOR VALUES FROM ('%s') TO ('%s')`, table, name, lower, upper),
+		}
+		for _, SQL := range stmts {
+			if err := tx.Exec(SQL).Error; err != nil {
+				return fmt.Errorf("%s: %v", SQL, err)
+			}
+		}
+		return nil
+	})
+}
+
+// RemovePartitionsBefore drops the partitions whose whole range is before cutoff.
+// If detach is set, the partitions are detached instead and kept as standalone tables for archiving.
+// The old rows of the default partition are first moved to their monthly partitions, so they are removed the same way.
+func RemovePartitionsBefore(db *gorm.DB, table string, cutoff time.Time, detach bool) ([]string, error) {
+
+	removed := []string{}
+
+	oldest, err := oldestInDefault(db, table)
+	if err != nil {
+		return removed, err
+	}
+	if !oldest.IsZero() && oldest.Before(cutoff) {
+		if _, err := EnsurePartitions(db, table, oldest, cutoff); err != nil {
+			return removed, err
+		}
+	}
+
+	partitions, err := ListPartitions(db, table)
+	if err != nil {
+		return removed, err
+	}
+
+	for _, p := range partitions {
+		if !p.expired(cutoff) {
+			continue
+		}
+
+		SQL := fmt.Sprintf(`DROP TABLE %q`, p.Name)
+		if detach {
+			SQL = fmt.Sprintf(`ALTER TABLE %q DETACH PARTITION %q`, table, p.Name)
+		}
+		if err := db.Exec(SQL).Error; err != nil {
+			return removed, fmt.Errorf("removing partition %q: %v", p.Name, err)
+		}
+		removed = append(removed, p.Name)
+	}
+
+	return removed, nil
+}
+
+// ConvertToPartitioned turns an existing plain table into a table partitioned by month on `created_at`
+// and moves all its rows into the new partitions, in one transaction.
+// The id sequence is kept, so the ids keep growing from where they were.
+// The primary key becomes (id, created_at) since the partition key must be a part of it,
+// the other indexes are created again on the new table.
+func ConvertToPartitioned(db *gorm.DB, table string, ahead time.Duration) error {
+
+	partitioned, err := IsPartitioned(db, table)
+	if err != nil {
+		return err
+	}
+	if partitioned {
+		return fmt.Errorf("table %q is already partitioned", table)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+
+		var seq []struct{ Name string }
+		if err := Query(tx, `SELECT PG_GET_SERIAL_SEQUENCE(?, 'id') AS "name"`, &seq, table); err != nil {
+			return err
+		}
+		if len(seq) == 0 || seq[0].Name == "" {
+			return fmt.Errorf("no id sequence found for table %q", table)
+		}
+		seqName := seq[0].Name // already quoted where needed
+
+		var bounds []struct{ MinCreatedAt time.Time }
+		SQL := fmt.Sprintf(`SELECT COALESCE(MIN("created_at"), NOW()) AS "min_created_at" FROM %q`, table)
+		if err := Query(tx, SQL, &bounds); err != nil {
+			return err
+		}
+
+		var indexes []struct{ Indexdef string }
+		SQL = `SELECT "indexdef" FROM "pg_indexes" WHERE "schemaname" = CURRENT_SCHEMA() AND "tablename" = ? AND "indexname" != ?`
+		if err := Query(tx, SQL, &indexes, table, table+"_pkey"); err != nil {
+			return err
+		}
+
+		old := table + "_unpartitioned"
+		stmts := []string{
+			// Stop the sequence from being dropped along with the old table
+			fmt.Sprintf(`ALTER SEQUENCE %s OWNED BY NONE`, seqName),
+			fmt.Sprintf(`ALTER TABLE %q RENAME TO %q`, table, old),
+			fmt.Sprintf(`ALTER INDEX %q RENAME TO %q`, table+"_pkey", old+"_pkey"),
+			fmt.Sprintf(`CREATE TABLE %q (LIKE %q INCLUDING DEFAULTS) PARTITION BY RANGE ("created_at")`, table, old),
+			fmt.Sprintf(`ALTER TABLE %q ADD PRIMARY KEY ("id", "created_at")`, table),
+		}
+		for _, SQL := range stmts {
+			if err := tx.Exec(SQL).Error; err != nil {
+				return fmt.Errorf("%s: %v", SQL, err)
+			}
+		}
+
+		if _, err := EnsurePartitions(tx, table, bounds[0].MinCreatedAt, time.Now().Add(ahead)); err != nil {
+			return err
+		}
+
+		stmts = []string{
+			fmt.Sprintf(`INSERT INTO %q SELECT * FROM %q`, table, old),
+			fmt.Sprintf(`DROP TABLE %q`, old),
+			fmt.Sprintf(`ALTER SEQUENCE %s OWNED BY %q."id"`, seqName, table),
+		}
+		for _, idx := range indexes {
+			stmts = append(stmts, idx.Indexdef)
+		}
+		for _, SQL := range stmts {
+			if err := tx.Exec(SQL).Error; err != nil {
+				return fmt.Errorf("%s: %v", SQL, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// MaintainPartitions creates the upcoming partitions and applies the retention policy,
+// it does nothing if the table is not partitioned
+func MaintainPartitions(db *gorm.DB, table string, policy RetentionPolicy) (created, removed []string, err error) {
+
+	partitioned, err := IsPartitioned(db, table)
+	if err != nil || !partitioned {
+		return nil, nil, err
+	}
+
+	// the past rows which landed in the default partition get their partitions too
+	now := time.Now()
+	from := now
+	if oldest, err := oldestInDefault(db, table); err != nil {
+		return nil, nil, err
+	} else if !oldest.IsZero() && oldest.Before(from) {
+		from = oldest
+	}
+
+	created, err = EnsurePartitions(db, table, from, now.Add(DefaultPartitionsAhead))
+	if err != nil {
+		return created, nil, err
+	}
+
+	if policy.Days > 0 {
+		cutoff := retentionCutoff(now, policy.Days)
+		removed, err = RemovePartitionsBefore(db, table, cutoff, policy.Detach)
+	}
+
+	return created, removed, err
+}
+
+// RunPartitionMaintainer runs MaintainPartitions every interval until ctx is done
+func RunPartitionMaintainer(ctx context.Context, db *gorm.DB, table string, policy RetentionPolicy, interval time.Duration) {
+
+	if interval <= 0 {
+		interval = DefaultPartitionMaintenanceInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		created, removed, err := MaintainPartitions(db, table, policy)
+		if err != nil {
+			log.Printf("partition maintenance: %v\n", err)
+		}
+		if len(created) > 0 {
+			log.Printf("partitions created: %v\n", created)
+		}
+		if len(removed) > 0 {
+			log.Printf("partitions removed by the retention policy: %v\n", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
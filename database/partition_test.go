@@ -0,0 +1,280 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartitionNames(t *testing.T) {
+
+	// a time early in a month east of UTC is still in the previous month in UTC
+	at := time.Date(2024, 3, 1, 0, 30, 0, 0, time.FixedZone("EET", 2*3600))
+	month := MonthStart(at)
+	if want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !month.Equal(want) || month.Location() != time.UTC {
+		t.Errorf("MonthStart(%v) = %v, want %v", at, month, want)
+	}
+
+	name := partitionName(PartitionedTable, month)
+	if name != "celestia_nodes_p202402" {
+		t.Errorf("partition name %q, want celestia_nodes_p202402", name)
+	}
+	if got, ok := parsePartitionName(PartitionedTable, name); !ok || !got.Equal(month) {
+		t.Errorf("parsePartitionName(%q) = %v, %v, want %v", name, got, ok, month)
+	}
+
+	for _, other := range []string{
+		defaultPartitionName(PartitionedTable),
+		PartitionedTable + "_unpartitioned",
+		PartitionedTable + "_p2024",
+		PartitionedTable + "_p202413",
+		"other_p202402",
+	} {
+		if _, ok := parsePartitionName(PartitionedTable, other); ok {
+			t.Errorf("%q taken for a monthly partition", other)
+		}
+	}
+}
+
+func TestPartitionBounds(t *testing.T) {
+
+	tests := []struct {
+		month        time.Time
+		lower, upper string
+	}{
+		{month: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), lower: "2024-02-01 00:00:00Z", upper: "2024-03-01 00:00:00Z"},
+		{month: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), lower: "2024-12-01 00:00:00Z", upper: "2025-01-01 00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		if lower, upper := partitionBounds(tt.month); lower != tt.lower || upper != tt.upper {
+			t.Errorf("partitionBounds(%v) = [%s, %s), want [%s, %s)", tt.month, lower, upper, tt.lower, tt.upper)
+		}
+	}
+}
+
+func TestRetentionCutoff(t *testing.T) {
+
+	partitionOf := func(year int, month time.Month) Partition {
+		from := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return Partition{Name: partitionName(PartitionedTable, from), From: from, To: from.AddDate(0, 1, 0)}
+	}
+
+	tests := []struct {
+		now     time.Time
+		days    int
+		expired []Partition
+		kept    []Partition
+	}{
+		{
+			now:     time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC),
+			days:    30, // 2024-02-14 12:00
+			expired: []Partition{partitionOf(2023, 12), partitionOf(2024, 1)},
+			kept:    []Partition{partitionOf(2024, 2), partitionOf(2024, 3)},
+		},
+		{
+			now:     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			days:    29, // 2024-02-01, a leap year, the January partition ends right at the cutoff
+			expired: []Partition{partitionOf(2024, 1)},
+			kept:    []Partition{partitionOf(2024, 2)},
+		},
+		{
+			now:     time.Date(2024, 3, 1, 0, 0, 1, 0, time.UTC),
+			days:    0, // the current month only
+			expired: []Partition{partitionOf(2024, 2)},
+			kept:    []Partition{partitionOf(2024, 3)},
+		},
+	}
+
+	for _, tt := range tests {
+		cutoff := retentionCutoff(tt.now, tt.days)
+		for _, p := range tt.expired {
+			if !p.expired(cutoff) {
+				t.Errorf("%s kept with the cutoff %v", p.Name, cutoff)
+			}
+		}
+		for _, p := range tt.kept {
+			if p.expired(cutoff) {
+				t.Errorf("%s removed with the cutoff %v", p.Name, cutoff)
+			}
+		}
+	}
+}
+
+func TestConvertToPartitioned(t *testing.T) {
+
+	db := newTestPostgres(t)
+
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	insertTestSamples(t, db, "node-1", now.AddDate(0, -2, 0), now.AddDate(0, -1, 0), now)
+
+	var before []struct{ MaxId uint }
+	if err := Query(db, `SELECT MAX("id") AS "max_id" FROM "celestia_nodes"`, &before); err != nil {
+		t.Fatal(err)
+	}
+	var indexes []struct{ Indexname string }
+	SQL := `SELECT "indexname" FROM "pg_indexes" WHERE "schemaname" = CURRENT_SCHEMA() AND "tablename" = ? AND "indexname" != ?`
+	if err := Query(db, SQL, &indexes, PartitionedTable, PartitionedTable+"_pkey"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ConvertToPartitioned(db, PartitionedTable, DefaultPartitionsAhead); err != nil {
+		t.Fatal(err)
+	}
+
+	if partitioned, err := IsPartitioned(db, PartitionedTable); err != nil || !partitioned {
+		t.Fatalf("partitioned %v (err: %v)", partitioned, err)
+	}
+	if count := countRows(t, db, PartitionedTable); count != 3 {
+		t.Errorf("%d rows after the conversion, want 3", count)
+	}
+
+	// a partition for every month from the oldest sample to the partitions created ahead
+	partitions, err := ListPartitions(db, PartitionedTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	months := map[string]bool{}
+	for _, p := range partitions {
+		months[p.Name] = true
+	}
+	for month := MonthStart(now.AddDate(0, -2, 0)); !month.After(now.Add(DefaultPartitionsAhead)); month = month.AddDate(0, 1, 0) {
+		if !months[partitionName(PartitionedTable, month)] {
+			t.Errorf("no partition for %s in %v", month.Format("2006-01"), partitions)
+		}
+	}
+
+	// the ids keep growing from where they were
+	insertTestSamples(t, db, "node-1", now)
+	var after []struct{ MaxId uint }
+	if err := Query(db, `SELECT MAX("id") AS "max_id" FROM "celestia_nodes"`, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after[0].MaxId <= before[0].MaxId {
+		t.Errorf("id %d of the new row, want more than %d", after[0].MaxId, before[0].MaxId)
+	}
+
+	// the indexes are created again on the new table
+	for _, idx := range indexes {
+		if state, err := getIndexState(db, idx.Indexname); err != nil || !state.Exists || !state.Partitioned {
+			t.Errorf("index %s: %+v (err: %v), want it on the partitioned table", idx.Indexname, state, err)
+		}
+	}
+
+	if err := ConvertToPartitioned(db, PartitionedTable, DefaultPartitionsAhead); err == nil {
+		t.Error("a partitioned table was converted again")
+	}
+}
+
+func TestEnsurePartitionsMovesDefaultRows(t *testing.T) {
+
+	db := newTestPostgres(t)
+
+	if _, err := MigrateUp(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UTC()
+	insertTestSamples(t, db, "node-1", now)
+	if err := ConvertToPartitioned(db, PartitionedTable, DefaultPartitionsAhead); err != nil {
+		t.Fatal(err)
+	}
+
+	// a sample with a clock far ahead lands in the default partition
+	future := now.AddDate(0, 6, 0)
+	insertTestSamples(t, db, "node-1", future)
+	if count := countRows(t, db, defaultPartitionName(PartitionedTable)); count != 1 {
+		t.Fatalf("%d rows in the default partition, want 1", count)
+	}
+
+	created, err := EnsurePartitions(db, PartitionedTable, future, future)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := partitionName(PartitionedTable, MonthStart(future))
+	if len(created) != 1 || created[0] != name {
+		t.Errorf("created %v, want %s", created, name)
+	}
+	if count := countRows(t, db, defaultPartitionName(PartitionedTable)); count != 0 {
+		t.Errorf("%d rows left in the default partition, want 0", count)
+	}
+	if count := countRows(t, db, name); count != 1 {
+		t.Errorf("%d rows in %s, want the moved one", count, name)
+	}
+
+	// nothing to do the second time
+	if created, err := EnsurePartitions(db, PartitionedTable, future, future); err != nil || len(created) != 0 {
+		t.Errorf("created %v (err: %v), want nothing", created, err)
+	}
+}
+
+func TestRemovePartitionsBefore(t *testing.T) {
+
+	for _, detach := range []bool{false, true} {
+		name := "drop"
+		if detach {
+			name = "detach"
+		}
+		t.Run(name, func(t *testing.T) {
+
+			db := newTestPostgres(t)
+
+			if _, err := MigrateUp(db, 2); err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now().UTC()
+			insertTestSamples(t, db, "node-1", now.AddDate(0, -3, 0), now.AddDate(0, -2, 0), now)
+			if err := ConvertToPartitioned(db, PartitionedTable, DefaultPartitionsAhead); err != nil {
+				t.Fatal(err)
+			}
+
+			// an old sample arriving late lands in the default partition, it is removed along with its month
+			old := now.AddDate(-1, 0, 0)
+			insertTestSamples(t, db, "node-1", old)
+
+			cutoff := MonthStart(now.AddDate(0, -1, 0))
+			removed, err := RemovePartitionsBefore(db, PartitionedTable, cutoff, detach)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gone := []string{
+				partitionName(PartitionedTable, MonthStart(old)),
+				partitionName(PartitionedTable, MonthStart(now.AddDate(0, -3, 0))),
+				partitionName(PartitionedTable, MonthStart(now.AddDate(0, -2, 0))),
+			}
+			list := strings.Join(removed, ",")
+			for _, p := range gone {
+				if !strings.Contains(list, p) {
+					t.Errorf("%s not removed, removed %v", p, removed)
+				}
+			}
+			if strings.Contains(list, partitionName(PartitionedTable, MonthStart(now))) {
+				t.Errorf("the current partition was removed: %v", removed)
+			}
+
+			if count := countRows(t, db, PartitionedTable); count != 1 {
+				t.Errorf("%d rows left, want the current one", count)
+			}
+			if count := countRows(t, db, defaultPartitionName(PartitionedTable)); count != 0 {
+				t.Errorf("%d rows left in the default partition, want 0", count)
+			}
+
+			// the detached partitions are kept as tables with their rows
+			var exists []struct{ Exists bool }
+			if err := Query(db, `SELECT TO_REGCLASS(?) IS NOT NULL AS "exists"`, &exists, gone[0]); err != nil {
+				t.Fatal(err)
+			}
+			if exists[0].Exists != detach {
+				t.Errorf("table %s exists %v, want %v", gone[0], exists[0].Exists, detach)
+			}
+			if detach {
+				if count := countRows(t, db, gone[0]); count != 1 {
+					t.Errorf("%d rows in the detached %s, want 1", count, gone[0])
+				}
+			}
+		})
+	}
+}
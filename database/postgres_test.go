@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The tests needing Postgres run only when this variable holds a connection string,
+// each of them works in a schema of its own which is dropped at the end
+const testPostgresEnv = "NODELOGGER_TEST_POSTGRES_DSN"
+
+func newTestPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv(testPostgresEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testPostgresEnv)
+	}
+
+	admin, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := admin.Exec(fmt.Sprintf(`CREATE SCHEMA %q`, schema)).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(fmt.Sprintf(`DROP SCHEMA %q CASCADE`, schema))
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	db, err := Open(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// insertTestSamples writes a sample of node at every given time
+func insertTestSamples(t *testing.T, db *gorm.DB, nodeId string, times ...time.Time) {
+	t.Helper()
+
+	for _, at := range times {
+		SQL := `INSERT INTO "celestia_nodes" ("node_id", "created_at") VALUES (?, ?)`
+		if err := db.Exec(SQL, nodeId, at).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countRows(t *testing.T, db *gorm.DB, table string) int64 {
+	t.Helper()
+
+	var rows []struct{ Count int64 }
+	if err := Query(db, fmt.Sprintf(`SELECT COUNT(*) AS "count" FROM %q`, table), &rows); err != nil {
+		t.Fatal(err)
+	}
+	return rows[0].Count
+}
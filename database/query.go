@@ -2,46 +2,79 @@ package database
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 
+	"github.com/celestiaorg/nodelogger/telemetry"
 	"github.com/celestiaorg/tools/cache"
 	"gorm.io/gorm"
 )
 
-func Query(db *gorm.DB, SQL string, rows interface{}) error {
-	return db.Raw(SQL).Scan(rows).Error
+// Query runs a raw SQL query, the values must be passed in args and referred to as `?` in the SQL
+func Query(db *gorm.DB, SQL string, rows interface{}, args ...interface{}) error {
+	return db.Raw(SQL, args...).Scan(rows).Error
 }
 
-func CachedQuery(db *gorm.DB, SQL string, rows interface{}) error {
+func CachedQuery(db *gorm.DB, SQL string, rows interface{}, args ...interface{}) error {
 
-	sqlHash := fmt.Sprint(sha256.Sum256([]byte(SQL)))
+	sqlHash, err := queryHash(SQL, args)
+	if err != nil {
+		return err
+	}
 
 	diskStorage := cache.New()
 
-	err := diskStorage.ReadAny(sqlHash, rows)
+	err = diskStorage.ReadAny(sqlHash, rows)
 	if err != nil {
-		if err := Query(db, SQL, rows); err != nil {
+		telemetry.CachedQueries.WithLabelValues("miss").Inc()
+		if err := Query(db, SQL, rows, args...); err != nil {
 			return err
 		}
 		return diskStorage.StoreAny(sqlHash, rows)
 	}
+	telemetry.CachedQueries.WithLabelValues("hit").Inc()
 
 	return nil
 }
 
-func ExistCachedQuery(SQL string) bool {
+func ExistCachedQuery(SQL string, args ...interface{}) bool {
 
-	sqlHash := fmt.Sprint(sha256.Sum256([]byte(SQL)))
+	sqlHash, err := queryHash(SQL, args)
+	if err != nil {
+		return false
+	}
 
 	diskStorage := cache.New()
-	_, err := diskStorage.Read(sqlHash)
+	_, err = diskStorage.Read(sqlHash)
 	return err == nil
 }
 
-func RemoveCachedQuery(SQL string) error {
+func RemoveCachedQuery(SQL string, args ...interface{}) error {
 
-	sqlHash := fmt.Sprint(sha256.Sum256([]byte(SQL)))
+	sqlHash, err := queryHash(SQL, args)
+	if err != nil {
+		return err
+	}
 
 	diskStorage := cache.New()
 	return diskStorage.Remove(sqlHash)
 }
+
+// queryHash is the cache key of a query, it covers both the SQL text and the arguments
+func queryHash(SQL string, args []interface{}) (string, error) {
+
+	if len(args) == 0 {
+		// Keeps the keys of the queries without arguments compatible with the existing cache files
+		return fmt.Sprint(sha256.Sum256([]byte(SQL))), nil
+	}
+
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("query cache key: %v", err)
+	}
+
+	// The separator can't be part of a valid SQL text, so SQL and args can't be mixed up
+	key := append([]byte(SQL), 0)
+	key = append(key, argsJSON...)
+	return fmt.Sprint(sha256.Sum256(key)), nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	sqlite "github.com/glebarez/go-sqlite"
+	"github.com/jackc/pgconn"
+)
+
+// the primary result codes of SQLite, the extended codes keep them in the low byte
+const (
+	sqliteBusy       = 5
+	sqliteLocked     = 6
+	sqliteIOErr      = 10
+	sqliteFull       = 13
+	sqliteCantOpen   = 14
+	sqliteTooBig     = 18
+	sqliteConstraint = 19
+	sqliteMismatch   = 20
+	sqliteRange      = 25
+)
+
+// IsPermanentError tells if the DB rejects the written data itself, e.g. a constraint violation
+// or a value out of range, so writing the same data again fails the same way
+func IsPermanentError(err error) bool {
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 22: data exception, 23: integrity constraint violation
+		return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqliteTooBig, sqliteConstraint, sqliteMismatch, sqliteRange:
+			return true
+		}
+	}
+	return false
+}
+
+// IsConnectionError tells if err comes from the DB being unreachable, shut down or overloaded,
+// the same write may succeed once it is back
+func IsConnectionError(err error) bool {
+
+	if errors.Is(err, driver.ErrBadConn) || pgconn.Timeout(err) || pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// 08: connection exception, 53: insufficient resources, 57P: the server is shutting down or starting,
+		// 40001 and 40P01: serialization failure and deadlock
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "53") ||
+			strings.HasPrefix(pgErr.Code, "57P") || pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqliteBusy, sqliteLocked, sqliteIOErr, sqliteFull, sqliteCantOpen:
+			return true
+		}
+	}
+	return false
+}
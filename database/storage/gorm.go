@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultLimit = 100
+
+// maxBindParameters is the number of parameters one statement can take on all the backends,
+// Postgres allows 65535 and SQLite 32766
+const maxBindParameters = 32766
+
+// gormStorage implements the queries which are the same on all the SQL backends
+type gormStorage struct {
+	db *gorm.DB
+}
+
+func (s *gormStorage) DB() *gorm.DB {
+	return s.db
+}
+
+func (s *gormStorage) AddNodeData(data []*models.CelestiaNode) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	rows, err := rowsPerStatement(s.db, &models.CelestiaNode{})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(data, rows).Error; err != nil {
+			return err
+		}
+		return updateLatestNodeStates(tx, data)
+	})
+}
+
+// rowsPerStatement is how many rows of the model fit in one insert without going over the bind parameters limit
+func rowsPerStatement(db *gorm.DB, model interface{}) (int, error) {
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return 0, err
+	}
+	if len(stmt.Schema.DBNames) == 0 {
+		return 0, fmt.Errorf("%T has no columns", model)
+	}
+	return maxBindParameters / len(stmt.Schema.DBNames), nil
+}
+
+// updateLatestNodeStates moves the latest state of the nodes forward to the given rows,
+// the rows must already have their ids
+func updateLatestNodeStates(tx *gorm.DB, data []*models.CelestiaNode) error {
+
+	latest := map[string]*models.CelestiaNode{}
+	for _, d := range data {
+		if l, ok := latest[d.NodeId]; !ok || l.ID < d.ID {
+			latest[d.NodeId] = d
+		}
+	}
+
+	states := make([]models.LatestNodeState, 0, len(latest))
+	for _, d := range latest {
+		states = append(states, models.LatestNodeState{
+			NodeId:     d.NodeId,
+			LastRowId:  d.ID,
+			NodeType:   d.NodeType,
+			Version:    d.Version,
+			Uptime:     d.Uptime,
+			LastSeenAt: d.CreatedAt,
+		})
+	}
+
+	// the same order for every writer, so concurrent upserts can not deadlock
+	sort.Slice(states, func(i, j int) bool { return states[i].NodeId < states[j].NodeId })
+
+	rows, err := rowsPerStatement(tx, &models.LatestNodeState{})
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(states); start += rows {
+		end := start + rows
+		if end > len(states) {
+			end = len(states)
+		}
+		chunk := states[start:end]
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "node_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"last_row_id", "node_type", "version", "uptime", "last_seen_at"}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				clause.Expr{SQL: `"latest_node_state"."last_row_id" < EXCLUDED."last_row_id"`},
+			}},
+		}).Create(&chunk).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *gormStorage) FindByNodeId(nodeId string, offset, limit int) ([]models.CelestiaNode, int64, error) {
+
+	var res []models.CelestiaNode
+
+	var count int64
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	tx := s.db.Model(&models.CelestiaNode{}).Where(&models.CelestiaNode{NodeId: nodeId}).Count(&count)
+	if tx.Error != nil {
+		return res, count, tx.Error
+	}
+
+	tx = s.db.Offset(offset).Limit(limit).
+		Where(&models.CelestiaNode{NodeId: nodeId}).Find(&res)
+	return res, count, tx.Error
+}
+
+func (s *gormStorage) GetAllNodes(offset, limit int) ([]models.CelestiaNode, int64, error) {
+
+	var res []models.CelestiaNode
+
+	var count int64
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	tx := s.db.Model(&models.CelestiaNode{}).Count(&count)
+	if tx.Error != nil {
+		return res, count, tx.Error
+	}
+
+	tx = s.db.Offset(offset).Limit(limit).
+		Order(clause.OrderByColumn{
+			Column: clause.Column{Name: "uptime"},
+			Desc:   true,
+		}).Find(&res)
+	return res, count, tx.Error
+}
+
+func (s *gormStorage) GetNodesByType(nType receiver.NodeType, offset, limit int) ([]models.CelestiaNode, int64, error) {
+
+	var res []models.CelestiaNode
+
+	var count int64
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	tx := s.db.Model(&models.CelestiaNode{}).Where(&models.CelestiaNode{NodeType: nType}).Count(&count)
+	if tx.Error != nil {
+		return res, count, tx.Error
+	}
+
+	tx = s.db.Offset(offset).Limit(limit).
+		Where(&models.CelestiaNode{NodeType: nType}).
+		Order(clause.OrderByColumn{
+			Column: clause.Column{Name: "uptime"},
+			Desc:   true,
+		}).Find(&res)
+	return res, count, tx.Error
+}
+
+func (s *gormStorage) FindByNodeIdAtNetworkHeight(nodeId string, networkHeight uint64) ([]models.CelestiaNode, error) {
+
+	var res []models.CelestiaNode
+
+	tx := s.db.Where(&models.CelestiaNode{NodeId: nodeId, NetworkHeight: networkHeight}).Find(&res)
+	return res, tx.Error
+}
+
+func (s *gormStorage) FindByNodeIdAtNetworkHeightRange(nodeId string, networkHeightBegin, networkHeightEnd uint64) ([]models.CelestiaNode, error) {
+
+	var res []models.CelestiaNode
+
+	tx := s.db.Where("node_id = ? AND ( network_height BETWEEN ? AND ? )", nodeId, networkHeightBegin, networkHeightEnd).Find(&res)
+	return res, tx.Error
+}
+
+func (s *gormStorage) GetNodesVersions(nodeId string) ([]models.NodeVersion, error) {
+
+	var rows []models.NodeVersion
+
+	SQL := `
+		SELECT 
+			"node_id",
+			"version",
+			MIN("created_at") AS "created_at"
+		FROM "celestia_nodes"
+		WHERE 
+			"node_id" = ?
+			AND "version" != ''
+		GROUP BY "node_id", "version"
+		ORDER BY "created_at" DESC`
+	if err := database.Query(s.db, SQL, &rows, nodeId); err != nil {
+		return rows, err
+	}
+	return rows, nil
+
+}
+
+func (s *gormStorage) GetLatestNodeData(nodeId string) (models.CelestiaNode, error) {
+
+	var rows []models.CelestiaNode
+
+	SQL := `
+		SELECT * 
+		FROM "celestia_nodes" 
+		WHERE 
+			"node_id" = ? 
+		ORDER BY "id" DESC
+		LIMIT 1`
+	if err := database.Query(s.db, SQL, &rows, nodeId); err != nil {
+		return models.CelestiaNode{}, err
+	}
+	if len(rows) == 0 {
+		return models.CelestiaNode{}, fmt.Errorf("node not found")
+	}
+	return rows[0], nil
+
+}
+
+func (s *gormStorage) GetLatestNetworkHeight() (uint64, error) {
+
+	var rows []models.CelestiaNode
+
+	SQL := `
+		SELECT 
+			MAX("network_height") AS "network_height"
+		FROM "celestia_nodes"`
+
+	if err := database.Query(s.db, SQL, &rows); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("node not found")
+	}
+	return rows[0].NetworkHeight, nil
+}
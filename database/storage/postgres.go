@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"gorm.io/gorm"
+)
+
+// Postgres is the main storage backend, the whole feature set is available on it
+type Postgres struct {
+	gormStorage
+}
+
+var _ Storage = (*Postgres)(nil)
+
+// NewPostgres wraps a database opened by database.Init
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{gormStorage{db: db}}
+}
+
+func (*Postgres) Dialect() Dialect {
+	return DialectPostgres
+}
+
+func (s *Postgres) GetNetworkHeightAtTime(metricTime time.Time) (uint64, error) {
+
+	var rows []models.CelestiaNode
+
+	SQL := `
+		SELECT MAX("network_height") AS "network_height"
+		FROM "celestia_nodes"
+		WHERE "created_at" < CAST(? AS TIMESTAMP)`
+
+	if err := database.CachedQuery(s.db, SQL, &rows, metricTime.Format("2006-01-02 15:04:05-07:00")); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("node not found")
+	}
+	return rows[0].NetworkHeight, nil
+}
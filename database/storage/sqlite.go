@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SQLite is an embedded storage backend for local development and small deployments,
+// it needs no database server. The partitions and their retention rely on Postgres and are not available on it.
+type SQLite struct {
+	gormStorage
+}
+
+var _ Storage = (*SQLite)(nil)
+
+// OpenSQLite opens or creates the database file and brings its schema up to date.
+// The versioned migrations are written for Postgres, so the schema is derived from the models here.
+func OpenSQLite(path string) (*SQLite, error) {
+
+	newLogger := logger.New(
+		log.New(os.Stdout, "\r\n", log.LstdFlags),
+		logger.Config{
+			SlowThreshold:             10 * time.Second,
+			LogLevel:                  logger.Silent,
+			IgnoreRecordNotFoundError: true,
+		},
+	)
+
+	// WAL lets the API read while the samples are being written
+	dsn := path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: newLogger})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.AutoMigrate(
+		&models.CelestiaNode{},
+		&models.LatestNodeState{},
+		&models.AlertEvent{},
+		&models.NodeRuntimeCheckpoint{},
+		&models.HourlyNodeRollup{},
+		&models.DailyNodeRollup{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite schema: %v", err)
+	}
+
+	return &SQLite{gormStorage{db: db}}, nil
+}
+
+func (*SQLite) Dialect() Dialect {
+	return DialectSQLite
+}
+
+// AddNodeData stores the times in UTC, SQLite compares them as text
+func (s *SQLite) AddNodeData(data []*models.CelestiaNode) error {
+
+	for _, d := range data {
+		if d.CreatedAt.IsZero() {
+			d.CreatedAt = time.Now()
+		}
+		d.CreatedAt = d.CreatedAt.UTC()
+		d.UpdatedAt = d.CreatedAt
+		d.StartTime = d.StartTime.UTC()
+		d.LastRestartTime = d.LastRestartTime.UTC()
+		d.LastPfbTimestamp = d.LastPfbTimestamp.UTC()
+		d.DasLatestSampledTimestamp = d.DasLatestSampledTimestamp.UTC()
+	}
+
+	return s.gormStorage.AddNodeData(data)
+}
+
+func (s *SQLite) GetNetworkHeightAtTime(metricTime time.Time) (uint64, error) {
+
+	var rows []models.CelestiaNode
+
+	SQL := `
+		SELECT MAX("network_height") AS "network_height"
+		FROM "celestia_nodes"
+		WHERE "created_at" < ?`
+
+	if err := database.Query(s.db, SQL, &rows, metricTime.UTC()); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("node not found")
+	}
+	return rows[0].NetworkHeight, nil
+}
+
+// GetNodesVersions reads the first row of every version, SQLite loses the type of an aggregated time
+func (s *SQLite) GetNodesVersions(nodeId string) ([]models.NodeVersion, error) {
+
+	var rows []models.NodeVersion
+
+	SQL := `
+		SELECT
+			"node_id",
+			"version",
+			"created_at"
+		FROM "celestia_nodes"
+		WHERE "id" IN (
+			SELECT MIN("id")
+			FROM "celestia_nodes"
+			WHERE
+				"node_id" = ?
+				AND "version" != ''
+			GROUP BY "version"
+		)
+		ORDER BY "created_at" DESC`
+	if err := database.Query(s.db, SQL, &rows, nodeId); err != nil {
+		return rows, err
+	}
+	return rows, nil
+}
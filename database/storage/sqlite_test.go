@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func TestSQLite(t *testing.T) {
+
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "nodelogger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the times are given in another time zone than UTC, they must compare by instant
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	begin := time.Date(2024, 5, 1, 12, 0, 0, 0, zone)
+
+	versions := []string{"v0.1.0", "v0.1.0", "v0.2.0", "v0.2.0", ""}
+	batch := []*models.CelestiaNode{}
+	for i, version := range versions {
+		batch = append(batch, &models.CelestiaNode{
+			NodeId:        "node-1",
+			Version:       version,
+			NetworkHeight: uint64(100 + i),
+			CreatedAt:     begin.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	batch = append(batch, &models.CelestiaNode{NodeId: "node-2", NetworkHeight: 90, CreatedAt: begin})
+	if err := s.AddNodeData(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, total, err := s.FindByNodeId("node-1", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(rows) != 5 {
+		t.Errorf("%d rows of %d, want 5", len(rows), total)
+	}
+
+	latest, err := s.GetLatestNodeData("node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.NetworkHeight != 104 || !latest.CreatedAt.Equal(begin.Add(4*time.Minute)) {
+		t.Errorf("latest sample %+v, want the one at %v", latest, begin.Add(4*time.Minute))
+	}
+
+	tests := []struct {
+		at   time.Time
+		want uint64
+	}{
+		{at: begin.Add(150 * time.Second), want: 102},
+		{at: begin.Add(150 * time.Second).UTC(), want: 102},
+		{at: begin.Add(time.Hour), want: 104},
+		{at: begin.Add(time.Second), want: 100},
+	}
+	for _, tt := range tests {
+		height, err := s.GetNetworkHeightAtTime(tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if height != tt.want {
+			t.Errorf("network height at %v: %d, want %d", tt.at, height, tt.want)
+		}
+	}
+
+	nodeVersions, err := s.GetNodesVersions("node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodeVersions) != 2 || nodeVersions[0].Version != "v0.2.0" || !nodeVersions[0].CreatedAt.Equal(begin.Add(2*time.Minute)) ||
+		nodeVersions[1].Version != "v0.1.0" || !nodeVersions[1].CreatedAt.Equal(begin) {
+		t.Errorf("versions %+v, want v0.2.0 first seen at %v then v0.1.0 at %v", nodeVersions, begin.Add(2*time.Minute), begin)
+	}
+}
+
+// A batch larger than the bind parameters limit is written in several statements
+func TestSQLiteLargeBatch(t *testing.T) {
+
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "nodelogger.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 5000
+	batch := make([]*models.CelestiaNode, 0, n)
+	for i := 0; i < n; i++ {
+		batch = append(batch, &models.CelestiaNode{NodeId: fmt.Sprintf("node-%d", i), NetworkHeight: uint64(i)})
+	}
+	if err := s.AddNodeData(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows, states int64
+	s.DB().Model(&models.CelestiaNode{}).Count(&rows)
+	s.DB().Model(&models.LatestNodeState{}).Count(&states)
+	if rows != n || states != n {
+		t.Errorf("%d rows and %d latest states, want %d", rows, states, n)
+	}
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"gorm.io/gorm"
+)
+
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+// Storage keeps the samples of the nodes
+type Storage interface {
+	// AddNodeData writes all the given samples in one transaction, so either all of them are stored or none
+	// and moves the latest state of their nodes forward
+	AddNodeData(data []*models.CelestiaNode) error
+
+	FindByNodeId(nodeId string, offset, limit int) ([]models.CelestiaNode, int64, error)
+	GetAllNodes(offset, limit int) ([]models.CelestiaNode, int64, error)
+	GetNodesByType(nType receiver.NodeType, offset, limit int) ([]models.CelestiaNode, int64, error)
+	FindByNodeIdAtNetworkHeight(nodeId string, networkHeight uint64) ([]models.CelestiaNode, error)
+	FindByNodeIdAtNetworkHeightRange(nodeId string, networkHeightBegin, networkHeightEnd uint64) ([]models.CelestiaNode, error)
+	GetNodesVersions(nodeId string) ([]models.NodeVersion, error)
+	GetLatestNodeData(nodeId string) (models.CelestiaNode, error)
+
+	// GetNetworkHeightAtTime returns the highest network height seen before the given time
+	GetNetworkHeightAtTime(t time.Time) (uint64, error)
+	GetLatestNetworkHeight() (uint64, error)
+
+	// DB gives access to the underlying database for the queries beyond this interface
+	DB() *gorm.DB
+	Dialect() Dialect
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	c := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	c.w.Write(Columns()) // buffered, the errors are returned by Close
+	return c
+}
+
+func (c *csvWriter) Write(node *models.CelestiaNode) error {
+
+	for i, col := range columns {
+		switch v := col.value(node).(type) {
+		case uint64:
+			c.record[i] = strconv.FormatUint(v, 10)
+		case float32:
+			c.record[i] = strconv.FormatFloat(float64(v), 'f', -1, 32)
+		case string:
+			c.record[i] = v
+		case time.Time:
+			c.record[i] = ""
+			if !v.IsZero() {
+				c.record[i] = formatTime(v)
+			}
+		}
+	}
+
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,113 @@
+// Package export writes the node samples as CSV, newline-delimited JSON or Parquet, one sample at a time,
+// so an export of any size is written with constant memory
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat reads an export format, csv if empty
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown export format %q, expected csv, ndjson or parquet", s)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "text/csv"
+}
+
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Writer writes the samples in an export format, Close completes the output but does not close the underlying writer
+type Writer interface {
+	Write(node *models.CelestiaNode) error
+	Close() error
+}
+
+func NewWriter(f Format, w io.Writer) Writer {
+	switch f {
+	case FormatNDJSON:
+		return newNDJSONWriter(w)
+	case FormatParquet:
+		return newParquetWriter(w)
+	}
+	return newCSVWriter(w)
+}
+
+type columnKind int
+
+const (
+	kindUint columnKind = iota
+	kindFloat
+	kindString
+	kindTime // a zero time is written as null, or as an empty CSV field
+)
+
+// column is an exported column of the samples, value reads it from a sample as a uint64, float32, string or time.Time
+type column struct {
+	name  string
+	kind  columnKind
+	value func(n *models.CelestiaNode) interface{}
+}
+
+var columns = []column{
+	{"id", kindUint, func(n *models.CelestiaNode) interface{} { return uint64(n.ID) }},
+	{"created_at", kindTime, func(n *models.CelestiaNode) interface{} { return n.CreatedAt }},
+	{"node_id", kindString, func(n *models.CelestiaNode) interface{} { return n.NodeId }},
+	{"node_type", kindString, func(n *models.CelestiaNode) interface{} { return n.NodeType.String() }},
+	{"version", kindString, func(n *models.CelestiaNode) interface{} { return n.Version }},
+	{"uptime", kindFloat, func(n *models.CelestiaNode) interface{} { return n.Uptime }},
+	{"head", kindUint, func(n *models.CelestiaNode) interface{} { return n.Head }},
+	{"network_height", kindUint, func(n *models.CelestiaNode) interface{} { return n.NetworkHeight }},
+	{"last_pfb_timestamp", kindTime, func(n *models.CelestiaNode) interface{} { return n.LastPfbTimestamp }},
+	{"pfb_count", kindUint, func(n *models.CelestiaNode) interface{} { return n.PfbCount }},
+	{"das_latest_sampled_timestamp", kindTime, func(n *models.CelestiaNode) interface{} { return n.DasLatestSampledTimestamp }},
+	{"das_network_head", kindUint, func(n *models.CelestiaNode) interface{} { return n.DasNetworkHead }},
+	{"das_sampled_chain_head", kindUint, func(n *models.CelestiaNode) interface{} { return n.DasSampledChainHead }},
+	{"das_sampled_headers_counter", kindUint, func(n *models.CelestiaNode) interface{} { return n.DasSampledHeadersCounter }},
+	{"das_total_sampled_headers", kindUint, func(n *models.CelestiaNode) interface{} { return n.DasTotalSampledHeaders }},
+	{"total_synced_headers", kindUint, func(n *models.CelestiaNode) interface{} { return n.TotalSyncedHeaders }},
+	{"start_time", kindTime, func(n *models.CelestiaNode) interface{} { return n.StartTime }},
+	{"last_restart_time", kindTime, func(n *models.CelestiaNode) interface{} { return n.LastRestartTime }},
+	{"node_runtime_counter_in_seconds", kindUint, func(n *models.CelestiaNode) interface{} { return n.NodeRuntimeCounterInSeconds }},
+	{"last_accumulative_node_runtime_counter_in_seconds", kindUint, func(n *models.CelestiaNode) interface{} { return n.LastAccumulativeNodeRuntimeCounterInSeconds }},
+}
+
+// Columns returns the names of the exported columns, in the order they are written
+func Columns() []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,255 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// testNode is the i-th sample of the tests, every other one has no PFB timestamp nor restart
+func testNode(i int) *models.CelestiaNode {
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Add(time.Duration(i) * time.Second)
+	node := &models.CelestiaNode{
+		NodeId:        fmt.Sprintf("node-%d, \"é\"", i%7),
+		Version:       "v0.11.0",
+		Uptime:        float32(i) / 4,
+		Head:          uint64(1<<40 + i),
+		NetworkHeight: uint64(1<<40 + i + 1),
+		PfbCount:      uint64(i),
+		StartTime:     created.Add(-time.Hour),
+	}
+	node.ID = uint(i + 1)
+	node.CreatedAt = created
+	if i%2 == 0 {
+		node.LastPfbTimestamp = created.Add(-time.Minute)
+		node.LastRestartTime = created.Add(-30 * time.Minute)
+	}
+	return node
+}
+
+func writeAll(t *testing.T, f Format, nodes []*models.CelestiaNode) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := NewWriter(f, &buf)
+	for _, n := range nodes {
+		if err := w.Write(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testNodes(n int) []*models.CelestiaNode {
+	nodes := make([]*models.CelestiaNode, n)
+	for i := range nodes {
+		nodes[i] = testNode(i)
+	}
+	return nodes
+}
+
+// bytesFile is a read only parquet source over an in memory file
+type bytesFile struct {
+	*bytes.Reader
+	data []byte
+}
+
+func newBytesFile(data []byte) bytesFile {
+	return bytesFile{Reader: bytes.NewReader(data), data: data}
+}
+
+func (b bytesFile) Write([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+func (b bytesFile) Close() error              { return nil }
+
+func (b bytesFile) Open(string) (source.ParquetFile, error) {
+	return newBytesFile(b.data), nil
+}
+
+func (b bytesFile) Create(string) (source.ParquetFile, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+// The file written is read back by a third party Parquet reader, across several row groups
+func TestParquetRoundTrip(t *testing.T) {
+
+	const rows = 2*parquetRowGroupRows + 123
+	nodes := testNodes(rows)
+	data := writeAll(t, FormatParquet, nodes)
+
+	pr, err := reader.NewParquetColumnReader(newBytesFile(data), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+
+	if got := pr.GetNumRows(); got != rows {
+		t.Fatalf("%d rows, want %d", got, rows)
+	}
+	if got := len(pr.Footer.RowGroups); got != 3 {
+		t.Errorf("%d row groups, want 3", got)
+	}
+
+	// the schema, the reader renamed the columns so the names are read from its schema handler
+	elements := pr.Footer.Schema
+	if len(elements) != len(columns)+1 {
+		t.Fatalf("%d schema elements, want the root and %d columns", len(elements), len(columns))
+	}
+	for i, col := range columns {
+		el := elements[i+1]
+		physical, repetition, converted := parquetTypeOf(col.kind)
+		if name := pr.SchemaHandler.Infos[i+1].ExName; name != col.name {
+			t.Errorf("column %d named %q, want %q", i, name, col.name)
+		}
+		if el.GetType() != parquet.Type(physical) || el.GetRepetitionType() != parquet.FieldRepetitionType(repetition) {
+			t.Errorf("column %s is %v %v, want %v %v", col.name, el.GetRepetitionType(), el.GetType(), parquet.FieldRepetitionType(repetition), parquet.Type(physical))
+		}
+		if converted >= 0 && (!el.IsSetConvertedType() || el.GetConvertedType() != parquet.ConvertedType(converted)) {
+			t.Errorf("column %s converted type %v, want %v", col.name, el.GetConvertedType(), parquet.ConvertedType(converted))
+		}
+	}
+
+	root := pr.SchemaHandler.GetRootExName()
+	for _, col := range columns {
+		values, _, dls, err := pr.ReadColumnByPath(root+common.PAR_GO_PATH_DELIMITER+col.name, rows)
+		if err != nil {
+			t.Fatalf("column %s: %v", col.name, err)
+		}
+		if len(values) != rows {
+			t.Fatalf("column %s: %d values, want %d", col.name, len(values), rows)
+		}
+
+		for i, node := range nodes {
+			var want interface{}
+			switch v := col.value(node).(type) {
+			case uint64:
+				want = int64(v)
+			case float32, string:
+				want = v
+			case time.Time:
+				if v.IsZero() {
+					if values[i] != nil || dls[i] != 0 {
+						t.Fatalf("column %s row %d: %v (definition level %d), want null", col.name, i, values[i], dls[i])
+					}
+					continue
+				}
+				want = v.UnixMicro()
+			}
+			if values[i] != want {
+				t.Fatalf("column %s row %d: %#v, want %#v", col.name, i, values[i], want)
+			}
+		}
+	}
+}
+
+// An export without a sample is still a valid file
+func TestParquetEmpty(t *testing.T) {
+
+	data := writeAll(t, FormatParquet, nil)
+
+	pr, err := reader.NewParquetColumnReader(newBytesFile(data), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+
+	if pr.GetNumRows() != 0 || len(pr.Footer.Schema) != len(columns)+1 {
+		t.Errorf("%d rows and %d schema elements, want none and %d", pr.GetNumRows(), len(pr.Footer.Schema), len(columns)+1)
+	}
+}
+
+func TestCSV(t *testing.T) {
+
+	nodes := testNodes(3)
+	records, err := csv.NewReader(bytes.NewReader(writeAll(t, FormatCSV, nodes))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 4 || strings.Join(records[0], ",") != strings.Join(Columns(), ",") {
+		t.Fatalf("records %q, want the header and 3 rows", records)
+	}
+
+	want := map[string][]string{
+		"id":                 {"1", "2", "3"},
+		"created_at":         {"2024-01-02T03:04:05Z", "2024-01-02T03:04:06Z", "2024-01-02T03:04:07Z"},
+		"node_id":            {`node-0, "é"`, `node-1, "é"`, `node-2, "é"`},
+		"uptime":             {"0", "0.25", "0.5"},
+		"head":               {"1099511627776", "1099511627777", "1099511627778"},
+		"last_pfb_timestamp": {"2024-01-02T03:03:05Z", "", "2024-01-02T03:03:07Z"},
+	}
+	for i, name := range records[0] {
+		for row, value := range want[name] {
+			if got := records[row+1][i]; got != value {
+				t.Errorf("%s of row %d is %q, want %q", name, row, got, value)
+			}
+		}
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+
+	nodes := testNodes(2)
+	lines := strings.Split(strings.TrimSuffix(string(writeAll(t, FormatNDJSON, nodes)), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("%d lines, want 2", len(lines))
+	}
+
+	for i, line := range lines {
+		// the keys are in the order of the columns
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.UseNumber()
+		if _, err := dec.Token(); err != nil {
+			t.Fatal(err)
+		}
+		obj := map[string]interface{}{}
+		for _, col := range columns {
+			key, err := dec.Token()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != col.name {
+				t.Fatalf("line %d: key %v, want %s", i, key, col.name)
+			}
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			obj[col.name] = v
+		}
+
+		node := nodes[i]
+		if obj["node_id"] != node.NodeId || obj["created_at"] != formatTime(node.CreatedAt) || obj["head"] != json.Number(fmt.Sprint(node.Head)) {
+			t.Errorf("line %d: %s", i, line)
+		}
+		if pfb := obj["last_pfb_timestamp"]; (i%2 == 0) != (pfb != nil) {
+			t.Errorf("line %d: last_pfb_timestamp %v, want null only when not set", i, pfb)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+
+	for in, want := range map[string]Format{"": FormatCSV, "CSV": FormatCSV, " ndjson ": FormatNDJSON, "parquet": FormatParquet} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xlsx"); err == nil {
+		t.Error("ParseFormat(xlsx) did not fail")
+	}
+}
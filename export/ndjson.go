@@ -0,0 +1,63 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// ndjsonWriter writes a JSON object per line, with the keys in the order of the columns
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	line []byte
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (j *ndjsonWriter) Write(node *models.CelestiaNode) error {
+
+	line := append(j.line[:0], '{')
+	for i, col := range columns {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = strconv.AppendQuote(line, col.name)
+		line = append(line, ':')
+
+		switch v := col.value(node).(type) {
+		case uint64:
+			line = strconv.AppendUint(line, v, 10)
+		case float32:
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				line = append(line, "null"...)
+			} else {
+				line = strconv.AppendFloat(line, float64(v), 'g', -1, 32)
+			}
+		case string:
+			s, _ := json.Marshal(v) // cannot fail for a string
+			line = append(line, s...)
+		case time.Time:
+			if v.IsZero() {
+				line = append(line, "null"...)
+			} else {
+				line = strconv.AppendQuote(line, formatTime(v))
+			}
+		}
+	}
+	line = append(line, '}', '\n')
+	j.line = line
+
+	_, err := j.w.Write(line)
+	return err
+}
+
+func (j *ndjsonWriter) Close() error {
+	return j.w.Flush()
+}
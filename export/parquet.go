@@ -0,0 +1,298 @@
+package export
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// A minimal Parquet writer: a flat schema, PLAIN encoded values and one snappy compressed data page
+// per column and row group, which is all the samples need
+
+const (
+	parquetMagic = "PAR1"
+
+	// Rows buffered before a row group is written, it bounds the memory used by an export
+	parquetRowGroupRows = 10000
+)
+
+// Parquet format enums
+const (
+	parquetInt64     = 2
+	parquetFloat     = 4
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+	parquetUint64          = 14
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecSnappy = 1
+
+	parquetDataPage = 0
+)
+
+// parquetColumn buffers the values of a column for the current row group
+type parquetColumn struct {
+	values  []byte // PLAIN encoded, the nulls are left out
+	defined []bool // of the optional columns, false for a null
+}
+
+// parquetChunk is where a column of a row group was written
+type parquetChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetChunk
+}
+
+type parquetWriter struct {
+	w       io.Writer
+	offset  int64
+	err     error
+	started bool
+
+	buffers   []parquetColumn
+	rows      int // in the current row group
+	rowGroups []parquetRowGroup
+	page      []byte // scratch buffers
+	scratch   []byte
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{w: w, buffers: make([]parquetColumn, len(columns))}
+}
+
+func parquetTypeOf(kind columnKind) (physical, repetition, converted int32) {
+	switch kind {
+	case kindFloat:
+		return parquetFloat, parquetRequired, -1
+	case kindString:
+		return parquetByteArray, parquetRequired, parquetUTF8
+	case kindTime:
+		return parquetInt64, parquetOptional, parquetTimestampMicros
+	}
+	return parquetInt64, parquetRequired, parquetUint64
+}
+
+func (p *parquetWriter) Write(node *models.CelestiaNode) error {
+
+	for i, col := range columns {
+		buf := &p.buffers[i]
+
+		switch v := col.value(node).(type) {
+		case uint64:
+			buf.values = binary.LittleEndian.AppendUint64(buf.values, v)
+		case float32:
+			buf.values = binary.LittleEndian.AppendUint32(buf.values, math.Float32bits(v))
+		case string:
+			buf.values = binary.LittleEndian.AppendUint32(buf.values, uint32(len(v)))
+			buf.values = append(buf.values, v...)
+		case time.Time:
+			buf.defined = append(buf.defined, !v.IsZero())
+			if !v.IsZero() {
+				buf.values = binary.LittleEndian.AppendUint64(buf.values, uint64(v.UnixMicro()))
+			}
+		}
+	}
+
+	p.rows++
+	if p.rows >= parquetRowGroupRows {
+		p.flushRowGroup()
+	}
+	return p.err
+}
+
+func (p *parquetWriter) Close() error {
+
+	if p.rows > 0 {
+		p.flushRowGroup()
+	}
+	p.start()
+
+	footer := p.footer()
+	p.write(footer)
+	p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	p.write([]byte(parquetMagic))
+
+	return p.err
+}
+
+func (p *parquetWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// start writes the header of the file, only once there is something to write
+func (p *parquetWriter) start() {
+	if !p.started {
+		p.started = true
+		p.write([]byte(parquetMagic))
+	}
+}
+
+func (p *parquetWriter) flushRowGroup() {
+
+	p.start()
+
+	rowGroup := parquetRowGroup{rows: int64(p.rows)}
+
+	for i := range columns {
+		buf := &p.buffers[i]
+
+		// the definition levels of an optional column come first, then the values
+		page := p.page[:0]
+		if buf.defined != nil {
+			page = appendLevels(page, buf.defined)
+		}
+		page = append(page, buf.values...)
+		p.page = page
+
+		p.scratch = snappy.Encode(p.scratch[:cap(p.scratch)], page)
+		header := pageHeader(len(page), len(p.scratch), p.rows)
+
+		chunk := parquetChunk{
+			offset:           p.offset,
+			uncompressedSize: int64(len(header) + len(page)),
+			compressedSize:   int64(len(header) + len(p.scratch)),
+		}
+		p.write(header)
+		p.write(p.scratch)
+		rowGroup.chunks = append(rowGroup.chunks, chunk)
+
+		buf.values = buf.values[:0]
+		if buf.defined != nil {
+			buf.defined = buf.defined[:0]
+		}
+	}
+
+	p.rowGroups = append(p.rowGroups, rowGroup)
+	p.rows = 0
+}
+
+// appendLevels writes the definition levels with the RLE hybrid encoding, prefixed by their length
+func appendLevels(dst []byte, defined []bool) []byte {
+
+	start := len(dst)
+	dst = append(dst, 0, 0, 0, 0)
+
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+		dst = protowire.AppendVarint(dst, uint64(j-i)<<1) // a run of j-i equal levels
+		if defined[i] {
+			dst = append(dst, 1)
+		} else {
+			dst = append(dst, 0)
+		}
+		i = j
+	}
+
+	binary.LittleEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
+	return dst
+}
+
+func pageHeader(uncompressedSize, compressedSize, rows int) []byte {
+
+	t := &thriftWriter{}
+	t.I32(1, parquetDataPage)
+	t.I32(2, int32(uncompressedSize))
+	t.I32(3, int32(compressedSize))
+	t.Struct(5) // data_page_header
+	t.I32(1, int32(rows))
+	t.I32(2, parquetEncodingPlain)
+	t.I32(3, parquetEncodingRLE) // definition levels
+	t.I32(4, parquetEncodingRLE) // repetition levels
+	t.End()
+	t.End()
+
+	return t.buf
+}
+
+// footer encodes the FileMetaData
+func (p *parquetWriter) footer() []byte {
+
+	t := &thriftWriter{}
+	t.I32(1, 1) // version
+
+	t.List(2, thriftStruct, len(columns)+1) // schema, the root then the columns
+	t.Begin()
+	t.String(4, "schema")
+	t.I32(5, int32(len(columns)))
+	t.End()
+	for _, col := range columns {
+		physical, repetition, converted := parquetTypeOf(col.kind)
+		t.Begin()
+		t.I32(1, physical)
+		t.I32(3, repetition)
+		t.String(4, col.name)
+		if converted >= 0 {
+			t.I32(6, converted)
+		}
+		t.End()
+	}
+
+	var rows int64
+	for _, rg := range p.rowGroups {
+		rows += rg.rows
+	}
+	t.I64(3, rows)
+
+	t.List(4, thriftStruct, len(p.rowGroups))
+	for _, rg := range p.rowGroups {
+		t.Begin()
+
+		var size int64
+		t.List(1, thriftStruct, len(rg.chunks))
+		for i, chunk := range rg.chunks {
+			physical, _, _ := parquetTypeOf(columns[i].kind)
+			size += chunk.uncompressedSize
+
+			t.Begin()
+			t.I64(2, chunk.offset) // file_offset
+			t.Struct(3)            // meta_data
+			t.I32(1, physical)
+			t.List(2, thriftI32, 2)
+			t.I32Elem(parquetEncodingPlain)
+			t.I32Elem(parquetEncodingRLE)
+			t.List(3, thriftBinary, 1)
+			t.StringElem(columns[i].name)
+			t.I32(4, parquetCodecSnappy)
+			t.I64(5, rg.rows)
+			t.I64(6, chunk.uncompressedSize)
+			t.I64(7, chunk.compressedSize)
+			t.I64(9, chunk.offset) // data_page_offset
+			t.End()
+			t.End()
+		}
+
+		t.I64(2, size)
+		t.I64(3, rg.rows)
+		t.End()
+	}
+
+	t.String(6, "nodelogger")
+	t.End()
+
+	return t.buf
+}
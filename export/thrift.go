@@ -0,0 +1,87 @@
+package export
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Thrift compact protocol types, the Parquet metadata is encoded with it
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a struct with the Thrift compact protocol, the fields must be written by increasing id
+type thriftWriter struct {
+	buf   []byte
+	last  int16   // id of the last field of the current struct
+	stack []int16 // of the enclosing structs
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = protowire.AppendVarint(t.buf, protowire.EncodeZigZag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) I32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.I32Elem(v)
+}
+
+func (t *thriftWriter) I64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = protowire.AppendVarint(t.buf, protowire.EncodeZigZag(v))
+}
+
+func (t *thriftWriter) String(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.StringElem(s)
+}
+
+// List starts a list field, its n elements are written next with the Elem methods or Begin and End
+func (t *thriftWriter) List(id int16, elemType byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elemType)
+	} else {
+		t.buf = append(t.buf, 0xf0|elemType)
+		t.buf = protowire.AppendVarint(t.buf, uint64(n))
+	}
+}
+
+// Struct starts a struct field, it is ended by End
+func (t *thriftWriter) Struct(id int16) {
+	t.field(id, thriftStruct)
+	t.Begin()
+}
+
+// Begin starts a struct element of a list, it is ended by End
+func (t *thriftWriter) Begin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+// End ends the current struct, the top level one included
+func (t *thriftWriter) End() {
+	t.buf = append(t.buf, 0) // stop field
+	if n := len(t.stack); n > 0 {
+		t.last = t.stack[n-1]
+		t.stack = t.stack[:n-1]
+	}
+}
+
+func (t *thriftWriter) I32Elem(v int32) {
+	t.buf = protowire.AppendVarint(t.buf, protowire.EncodeZigZag(int64(v)))
+}
+
+func (t *thriftWriter) StringElem(s string) {
+	t.buf = protowire.AppendVarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
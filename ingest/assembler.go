@@ -0,0 +1,223 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+const (
+	DefaultMergeWindow = 5 * time.Second
+
+	// the head of a node that has not pushed for this long no longer counts for the network height
+	headReportTTL = 10 * time.Minute
+)
+
+var ErrAssemblerClosed = errors.New("ingestion is stopped")
+
+type sampleKey struct {
+	nodeId string
+	time   int64 // unix milliseconds
+}
+
+type headReport struct {
+	height  uint64
+	sampled time.Time // time of the sample it comes from
+	seen    time.Time // when it was received
+}
+
+type pendingSample struct {
+	node      *models.CelestiaNode
+	firstSeen time.Time
+}
+
+// Assembler merges the series of a node scraped at the same time into one sample.
+// The pushers split the series of a scrape across several requests, so a sample is kept
+// for the merge window after its first series arrives before it is handed to the sink.
+type Assembler struct {
+	sink        func(*models.CelestiaNode) error
+	score       func(models.CelestiaNode) float32
+	prefix      string // namespace prefix of the metric names
+	mergeWindow time.Duration
+
+	mu      sync.Mutex
+	pending map[sampleKey]*pendingSample
+	closed  bool
+
+	// the latest head reported by every node, their median stands for the network height
+	heads map[string]headReport
+}
+
+// NewAssembler hands the merged samples to sink, score computes their uptime if it is set
+func NewAssembler(sink func(*models.CelestiaNode) error, score func(models.CelestiaNode) float32, prefix string, mergeWindow time.Duration) *Assembler {
+	if mergeWindow <= 0 {
+		mergeWindow = DefaultMergeWindow
+	}
+	return &Assembler{
+		sink:        sink,
+		score:       score,
+		prefix:      prefix,
+		mergeWindow: mergeWindow,
+		pending:     map[sampleKey]*pendingSample{},
+		heads:       map[string]headReport{},
+	}
+}
+
+// Ingest maps the series onto the samples of their nodes and returns how many data points were used,
+// and how many series were rejected for a node id or a version the database can not store
+func (a *Assembler) Ingest(series []Series) (used, rejected int, err error) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return 0, 0, ErrAssemblerClosed
+	}
+
+	for _, s := range series {
+		nodeId := firstLabel(s.Labels, nodeIdLabels)
+		if nodeId == "" {
+			continue
+		}
+		version := firstLabel(s.Labels, versionLabels)
+		if !validLabel(nodeId) || !validLabel(version) {
+			rejected++
+			continue
+		}
+
+		name := metricBaseName(s.Name, a.prefix)
+		setter, known := fieldSetters[name]
+		if !known && name != buildInfoMetric {
+			continue
+		}
+
+		for _, p := range s.Samples {
+			node := a.pendingNode(nodeId, p.Time)
+			if nType, ok := nodeTypeFromLabels(s.Labels); ok {
+				node.NodeType = nType
+			}
+			if version != "" {
+				node.Version = version
+			}
+			if known {
+				setter(node, p.Value)
+			}
+			used++
+		}
+	}
+
+	return used, rejected, nil
+}
+
+func (a *Assembler) pendingNode(nodeId string, t time.Time) *models.CelestiaNode {
+
+	key := sampleKey{nodeId: nodeId, time: t.UnixMilli()}
+	if p, ok := a.pending[key]; ok {
+		return p.node
+	}
+
+	node := &models.CelestiaNode{
+		NodeId:    nodeId,
+		CreatedAt: t,
+	}
+	a.pending[key] = &pendingSample{node: node, firstSeen: time.Now()}
+	return node
+}
+
+// Flush hands the samples waiting for longer than the merge window to the sink,
+// or all of them if all is set
+func (a *Assembler) Flush(all bool) error {
+
+	a.mu.Lock()
+	ready := []*models.CelestiaNode{}
+	for key, p := range a.pending {
+		if all || time.Since(p.firstSeen) >= a.mergeWindow {
+			ready = append(ready, p.node)
+			delete(a.pending, key)
+		}
+	}
+	for _, node := range ready {
+		a.reportHead(node)
+	}
+	networkHeight := a.networkHeight()
+	a.mu.Unlock()
+
+	var firstErr error
+	for _, node := range ready {
+		node.NetworkHeight = networkHeight
+		if a.score != nil && networkHeight > 0 {
+			node.Uptime = a.score(*node)
+		}
+		if err := a.sink(node); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// reportHead keeps the head a node claims, the highest of its own head and the network head it sees
+func (a *Assembler) reportHead(node *models.CelestiaNode) {
+
+	head := node.Head
+	if node.DasNetworkHead > head {
+		head = node.DasNetworkHead
+	}
+	if head == 0 {
+		return
+	}
+
+	if last, ok := a.heads[node.NodeId]; ok && last.sampled.After(node.CreatedAt) {
+		return // an older sample arriving late
+	}
+	a.heads[node.NodeId] = headReport{height: head, sampled: node.CreatedAt, seen: time.Now()}
+}
+
+// networkHeight is the median of the heads reported by the nodes within headReportTTL,
+// so a few nodes reporting a bogus head cannot move it and the nodes gone for long are forgotten
+func (a *Assembler) networkHeight() uint64 {
+
+	heights := make([]uint64, 0, len(a.heads))
+	for nodeId, report := range a.heads {
+		if time.Since(report.seen) > headReportTTL {
+			delete(a.heads, nodeId)
+			continue
+		}
+		heights = append(heights, report.height)
+	}
+	if len(heights) == 0 {
+		return 0
+	}
+
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights[len(heights)/2]
+}
+
+// Pending returns the number of samples waiting for the rest of their series
+func (a *Assembler) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.pending)
+}
+
+// Run flushes the merged samples until ctx is done, then stops accepting series and flushes the rest
+func (a *Assembler) Run(ctx context.Context) error {
+
+	ticker := time.NewTicker(a.mergeWindow / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.mu.Lock()
+			a.closed = true
+			a.mu.Unlock()
+			return a.Flush(true)
+		case <-ticker.C:
+			a.Flush(false)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+func TestAssemblerNetworkHeight(t *testing.T) {
+
+	var flushed []*models.CelestiaNode
+	a := NewAssembler(func(n *models.CelestiaNode) error {
+		flushed = append(flushed, n)
+		return nil
+	}, nil, "celestia", time.Second)
+
+	push := func(nodeId string, head uint64, at time.Time) {
+		_, _, err := a.Ingest([]Series{{
+			Name:    "celestia_hdr_sync_subjective_head",
+			Labels:  map[string]string{"instance": nodeId},
+			Samples: []Sample{{Value: float64(head), Time: at}},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	flush := func() uint64 {
+		flushed = nil
+		if err := a.Flush(true); err != nil {
+			t.Fatal(err)
+		}
+		if len(flushed) == 0 {
+			t.Fatal("nothing flushed")
+		}
+		return flushed[0].NetworkHeight
+	}
+
+	now := time.Now()
+
+	push("a", 100, now)
+	push("b", 101, now)
+	push("c", 102, now)
+	if h := flush(); h != 101 {
+		t.Errorf("network height %d, want the median 101", h)
+	}
+
+	// a single node claiming a far away head does not move it
+	push("d", 1<<60, now)
+	if h := flush(); h != 102 {
+		t.Errorf("network height %d after an outlier, want 102", h)
+	}
+
+	// an older sample arriving late does not take the head of a node back
+	push("a", 50, now.Add(-time.Minute))
+	push("e", 103, now)
+	if h := flush(); h != 102 {
+		t.Errorf("network height %d after a late sample, want 102", h)
+	}
+
+	// the nodes gone silent are forgotten
+	for nodeId, report := range a.heads {
+		if nodeId != "e" {
+			report.seen = now.Add(-headReportTTL - time.Second)
+			a.heads[nodeId] = report
+		}
+	}
+	push("e", 104, now.Add(time.Second))
+	if h := flush(); h != 104 {
+		t.Errorf("network height %d once the others expired, want 104", h)
+	}
+	if len(a.heads) != 1 {
+		t.Errorf("%d heads kept, want 1", len(a.heads))
+	}
+}
+
+// The series whose node id or version the database can not store are rejected,
+// the other series of the same request still make their samples
+func TestAssemblerRejectsBadLabels(t *testing.T) {
+
+	var flushed []*models.CelestiaNode
+	a := NewAssembler(func(n *models.CelestiaNode) error {
+		flushed = append(flushed, n)
+		return nil
+	}, nil, "celestia", time.Second)
+
+	now := time.Now()
+	series := func(labels map[string]string) Series {
+		return Series{
+			Name:    "celestia_hdr_sync_subjective_head",
+			Labels:  labels,
+			Samples: []Sample{{Value: 10, Time: now}},
+		}
+	}
+
+	used, rejected, err := a.Ingest([]Series{
+		series(map[string]string{"instance": "good"}),
+		series(map[string]string{"instance": "nul\x00byte"}),
+		series(map[string]string{"instance": "bad\xffutf8"}),
+		series(map[string]string{"instance": strings.Repeat("x", maxLabelLength+1)}),
+		series(map[string]string{"instance": "long-version", "version": strings.Repeat("v", maxLabelLength+1)}),
+		series(map[string]string{"instance": strings.Repeat("é", maxLabelLength)}), // 255 characters fit the column
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 2 || rejected != 4 {
+		t.Errorf("%d data points used and %d series rejected, want 2 and 4", used, rejected)
+	}
+
+	if err := a.Flush(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(flushed) != 2 {
+		t.Fatalf("%d samples flushed, want 2", len(flushed))
+	}
+	for _, n := range flushed {
+		if n.NodeId != "good" && n.NodeId != strings.Repeat("é", maxLabelLength) {
+			t.Errorf("sample of %q flushed", n.NodeId)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The OTLP attribute keys and metric names use dots where the Prometheus ones use underscores,
+// they are turned into the Prometheus form so the same labels and names are mapped
+func otlpName(name string) string {
+	return strings.ReplaceAll(name, ".", "_")
+}
+
+// otlpResource is the part of an OTLP export coming from one resource, a node
+type otlpResource struct {
+	attributes map[string]string
+	metrics    []otlpMetric
+}
+
+type otlpMetric struct {
+	name   string
+	points []otlpPoint
+}
+
+type otlpPoint struct {
+	attributes map[string]string
+	value      float64
+	time       uint64 // unix nanoseconds
+}
+
+// otlpSeries turns the resources into series. The points of a resource are collected together,
+// so they all get the time of the latest one and are merged into one sample.
+func otlpSeries(resources []otlpResource) []Series {
+
+	series := []Series{}
+	for _, r := range resources {
+
+		latest := uint64(0)
+		for _, m := range r.metrics {
+			for _, p := range m.points {
+				if p.time > latest {
+					latest = p.time
+				}
+			}
+		}
+		t := time.Now()
+		if latest > 0 {
+			t = time.Unix(0, int64(latest))
+		}
+
+		for _, m := range r.metrics {
+			for _, p := range m.points {
+				labels := map[string]string{}
+				for k, v := range r.attributes {
+					labels[otlpName(k)] = v
+				}
+				for k, v := range p.attributes {
+					labels[otlpName(k)] = v
+				}
+				series = append(series, Series{
+					Name:    otlpName(m.name),
+					Labels:  labels,
+					Samples: []Sample{{Value: p.value, Time: t}},
+				})
+			}
+		}
+	}
+
+	return series
+}
+
+/*------*/
+
+// DecodeOTLPProtobuf decodes an OTLP/HTTP ExportMetricsServiceRequest in the protobuf encoding.
+// Only the gauges and the sums are read, the fields used are:
+//
+//	ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//	ResourceMetrics { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//	Resource        { repeated KeyValue attributes = 1; }
+//	ScopeMetrics    { repeated Metric metrics = 2; }
+//	Metric          { string name = 1; Gauge gauge = 5; Sum sum = 7; }
+//	Gauge, Sum      { repeated NumberDataPoint data_points = 1; }
+//	NumberDataPoint { repeated KeyValue attributes = 7; fixed64 time_unix_nano = 3; double as_double = 4; sfixed64 as_int = 6; }
+//	KeyValue        { string key = 1; AnyValue value = 2; }
+func DecodeOTLPProtobuf(data []byte) ([]Series, error) {
+
+	resources := []otlpResource{}
+	err := walkMessage(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		r, err := decodeOTLPResourceMetrics(v)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return otlpSeries(resources), nil
+}
+
+func decodeOTLPResourceMetrics(data []byte) (otlpResource, error) {
+
+	r := otlpResource{attributes: map[string]string{}}
+
+	err := walkMessage(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1: // resource
+			return walkMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num != 1 || typ != protowire.BytesType {
+					return nil
+				}
+				return decodeOTLPKeyValue(v, r.attributes)
+			})
+		case 2: // scope metrics
+			return walkMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num != 2 || typ != protowire.BytesType {
+					return nil
+				}
+				m, err := decodeOTLPMetric(v)
+				if err != nil {
+					return err
+				}
+				r.metrics = append(r.metrics, m)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	return r, err
+}
+
+func decodeOTLPMetric(data []byte) (otlpMetric, error) {
+
+	var m otlpMetric
+
+	err := walkMessage(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			m.name = string(v)
+		case 5, 7: // gauge, sum
+			return walkMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num != 1 || typ != protowire.BytesType {
+					return nil
+				}
+				p, err := decodeOTLPNumberDataPoint(v)
+				if err != nil {
+					return err
+				}
+				m.points = append(m.points, p)
+				return nil
+			})
+		}
+		return nil
+	})
+
+	return m, err
+}
+
+func decodeOTLPNumberDataPoint(data []byte) (otlpPoint, error) {
+
+	p := otlpPoint{attributes: map[string]string{}}
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return p, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == 7 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return p, protowire.ParseError(n)
+			}
+			if err := decodeOTLPKeyValue(v, p.attributes); err != nil {
+				return p, err
+			}
+			data = data[n:]
+		case (num == 3 || num == 4 || num == 6) && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return p, protowire.ParseError(n)
+			}
+			switch num {
+			case 3:
+				p.time = v
+			case 4:
+				p.value = math.Float64frombits(v)
+			case 6:
+				p.value = float64(int64(v))
+			}
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return p, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+
+	return p, nil
+}
+
+// decodeOTLPKeyValue adds the attribute to attrs, the scalar values are kept as strings
+func decodeOTLPKeyValue(data []byte, attrs map[string]string) error {
+
+	var key, value string
+
+	err := walkMessage(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			key = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			var err error
+			value, err = decodeOTLPAnyValue(v)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if key != "" {
+		attrs[key] = value
+	}
+	return nil
+}
+
+func decodeOTLPAnyValue(data []byte) (string, error) {
+
+	value := ""
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return value, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return value, protowire.ParseError(n)
+			}
+			value, data = string(v), data[n:]
+		case (num == 2 || num == 3) && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return value, protowire.ParseError(n)
+			}
+			if num == 2 {
+				value = strconv.FormatBool(v != 0)
+			} else {
+				value = strconv.FormatInt(int64(v), 10)
+			}
+			data = data[n:]
+		case num == 4 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return value, protowire.ParseError(n)
+			}
+			value, data = strconv.FormatFloat(math.Float64frombits(v), 'f', -1, 64), data[n:]
+		default: // arrays, key-value lists and bytes are not used as labels
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return value, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+
+	return value, nil
+}
+
+/*------*/
+
+// The OTLP/JSON encoding uses the lowerCamelCase field names and strings for the 64 bit integers
+type otlpJSONRequest struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []otlpJSONKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Metrics []struct {
+				Name  string              `json:"name"`
+				Gauge *otlpJSONDataPoints `json:"gauge"`
+				Sum   *otlpJSONDataPoints `json:"sum"`
+			} `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+type otlpJSONDataPoints struct {
+	DataPoints []struct {
+		Attributes   []otlpJSONKeyValue `json:"attributes"`
+		TimeUnixNano otlpJSONNumber     `json:"timeUnixNano"`
+		AsDouble     *otlpJSONNumber    `json:"asDouble"`
+		AsInt        *otlpJSONNumber    `json:"asInt"`
+	} `json:"dataPoints"`
+}
+
+type otlpJSONKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string         `json:"stringValue"`
+		BoolValue   *bool           `json:"boolValue"`
+		IntValue    *otlpJSONNumber `json:"intValue"`
+		DoubleValue *otlpJSONNumber `json:"doubleValue"`
+	} `json:"value"`
+}
+
+// otlpJSONNumber accepts a number either as a JSON number or as a string, e.g. "NaN" or a 64 bit integer
+type otlpJSONNumber string
+
+func (n *otlpJSONNumber) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		s = ""
+	}
+	*n = otlpJSONNumber(s)
+	return nil
+}
+
+func (n otlpJSONNumber) float() (float64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(string(n), 64)
+}
+
+func (n otlpJSONNumber) uint() (uint64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(string(n), 10, 64)
+}
+
+func otlpJSONAttributes(kvs []otlpJSONKeyValue) map[string]string {
+
+	attrs := map[string]string{}
+	for _, kv := range kvs {
+		v := kv.Value
+		switch {
+		case v.StringValue != nil:
+			attrs[kv.Key] = *v.StringValue
+		case v.BoolValue != nil:
+			attrs[kv.Key] = strconv.FormatBool(*v.BoolValue)
+		case v.IntValue != nil:
+			attrs[kv.Key] = string(*v.IntValue)
+		case v.DoubleValue != nil:
+			attrs[kv.Key] = string(*v.DoubleValue)
+		}
+	}
+	return attrs
+}
+
+// DecodeOTLPJSON decodes an OTLP/HTTP ExportMetricsServiceRequest in the JSON encoding,
+// only the gauges and the sums are read
+func DecodeOTLPJSON(data []byte) ([]Series, error) {
+
+	var req otlpJSONRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	resources := []otlpResource{}
+	for _, rm := range req.ResourceMetrics {
+		r := otlpResource{attributes: otlpJSONAttributes(rm.Resource.Attributes)}
+
+		for _, sm := range rm.ScopeMetrics {
+			for _, jm := range sm.Metrics {
+				points := jm.Gauge
+				if points == nil {
+					points = jm.Sum
+				}
+				if points == nil {
+					continue
+				}
+
+				m := otlpMetric{name: jm.Name}
+				for _, dp := range points.DataPoints {
+					p := otlpPoint{attributes: otlpJSONAttributes(dp.Attributes)}
+
+					var err error
+					if p.time, err = dp.TimeUnixNano.uint(); err != nil {
+						return nil, fmt.Errorf("metric %q: timeUnixNano: %v", jm.Name, err)
+					}
+					switch {
+					case dp.AsDouble != nil:
+						p.value, err = dp.AsDouble.float()
+					case dp.AsInt != nil:
+						p.value, err = dp.AsInt.float()
+					}
+					if err != nil {
+						return nil, fmt.Errorf("metric %q: value: %v", jm.Name, err)
+					}
+					m.points = append(m.points, p)
+				}
+				r.metrics = append(r.metrics, m)
+			}
+		}
+		resources = append(resources, r)
+	}
+
+	return otlpSeries(resources), nil
+}
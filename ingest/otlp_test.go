@@ -0,0 +1,262 @@
+package ingest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func otlpStringKV(key, value string) []byte {
+	return pbBytes(pbString(nil, 1, key), 2, pbString(nil, 1, value))
+}
+
+func otlpIntKV(key string, value int64) []byte {
+	return pbBytes(pbString(nil, 1, key), 2, pbVarint(nil, 3, uint64(value)))
+}
+
+func otlpBoolKV(key string, value bool) []byte {
+	v := uint64(0)
+	if value {
+		v = 1
+	}
+	return pbBytes(pbString(nil, 1, key), 2, pbVarint(nil, 2, v))
+}
+
+func otlpDoubleKV(key string, value float64) []byte {
+	return pbBytes(pbString(nil, 1, key), 2, pbDouble(nil, 4, value))
+}
+
+func otlpDoublePoint(value float64, nanos uint64, attrs ...[]byte) []byte {
+	b := pbFixed64(nil, 3, nanos)
+	b = pbDouble(b, 4, value)
+	for _, kv := range attrs {
+		b = pbBytes(b, 7, kv)
+	}
+	return b
+}
+
+// otlpIntPoint encodes as_int, a sfixed64
+func otlpIntPoint(value int64, nanos uint64) []byte {
+	return pbFixed64(pbFixed64(nil, 3, nanos), 6, uint64(value))
+}
+
+// otlpMetricMsg encodes a Metric, kind is 5 for a gauge and 7 for a sum
+func otlpMetricMsg(name string, kind protowire.Number, points ...[]byte) []byte {
+	var data []byte
+	for _, p := range points {
+		data = pbBytes(data, 1, p)
+	}
+	return pbBytes(pbString(nil, 1, name), kind, data)
+}
+
+func otlpResourceMetrics(attrs [][]byte, metrics ...[]byte) []byte {
+	var resource []byte
+	for _, kv := range attrs {
+		resource = pbBytes(resource, 1, kv)
+	}
+	scope := pbBytes(nil, 1, pbString(nil, 1, "celestia-node")) // the InstrumentationScope is skipped
+	for _, m := range metrics {
+		scope = pbBytes(scope, 2, m)
+	}
+	return pbBytes(pbBytes(nil, 1, resource), 2, scope)
+}
+
+func otlpRequest(resources ...[]byte) []byte {
+	var b []byte
+	for _, r := range resources {
+		b = pbBytes(b, 1, r)
+	}
+	return b
+}
+
+func TestDecodeOTLPProtobuf(t *testing.T) {
+
+	t0 := time.Unix(1680000000, 500)
+	t1 := t0.Add(10 * time.Second)
+
+	node := [][]byte{
+		otlpStringKV("service.instance.id", "node-1"),
+		otlpStringKV("service.name", "light"),
+		otlpIntKV("process.pid", 42),
+		otlpBoolKV("debug", true),
+		otlpDoubleKV("ratio", 0.5),
+	}
+
+	full := otlpRequest(otlpResourceMetrics(node,
+		otlpMetricMsg("celestia.hdr_sync_subjective_head", 5, otlpDoublePoint(1234, uint64(t0.UnixNano()))),
+		otlpMetricMsg("celestia.pfb_count", 7, otlpIntPoint(-5, uint64(t1.UnixNano()))),
+		otlpMetricMsg("celestia.build_info", 5, otlpDoublePoint(1, uint64(t0.UnixNano()), otlpStringKV("semantic.version", "v0.9.1"))),
+	))
+
+	// a histogram (field 9), an unknown field in every message, an array attribute value and an exemplar are skipped
+	point := otlpDoublePoint(math.NaN(), uint64(t0.UnixNano()))
+	point = pbBytes(point, 5, pbDouble(nil, 3, 1)) // exemplar
+	point = pbVarint(point, 99, 7)
+	metric := otlpMetricMsg("celestia.das_network_head", 5, point)
+	metric = pbBytes(metric, 9, pbBytes(nil, 1, nil))
+	arrayKV := pbBytes(pbString(nil, 1, "tags"), 2, pbBytes(nil, 5, nil))
+	withUnknown := otlpRequest(otlpResourceMetrics([][]byte{otlpStringKV("service.instance.id", "node-2"), arrayKV}, metric))
+	withUnknown = pbVarint(withUnknown, 42, 1)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []Series
+		wantErr bool
+	}{
+		{
+			name: "gauge, sum and attributes",
+			data: full,
+			want: []Series{
+				{
+					Name:    "celestia_hdr_sync_subjective_head",
+					Labels:  map[string]string{"service_instance_id": "node-1", "service_name": "light", "process_pid": "42", "debug": "true", "ratio": "0.5"},
+					Samples: []Sample{{Value: 1234, Time: t1}},
+				},
+				{
+					Name:    "celestia_pfb_count",
+					Labels:  map[string]string{"service_instance_id": "node-1", "service_name": "light", "process_pid": "42", "debug": "true", "ratio": "0.5"},
+					Samples: []Sample{{Value: -5, Time: t1}},
+				},
+				{
+					Name:    "celestia_build_info",
+					Labels:  map[string]string{"service_instance_id": "node-1", "service_name": "light", "process_pid": "42", "debug": "true", "ratio": "0.5", "semantic_version": "v0.9.1"},
+					Samples: []Sample{{Value: 1, Time: t1}},
+				},
+			},
+		},
+		{
+			name: "NaN and unknown fields",
+			data: withUnknown,
+			want: []Series{
+				{
+					Name:    "celestia_das_network_head",
+					Labels:  map[string]string{"service_instance_id": "node-2", "tags": ""},
+					Samples: []Sample{{Value: math.NaN(), Time: t0}},
+				},
+			},
+		},
+		{
+			name: "empty request",
+			data: nil,
+			want: []Series{},
+		},
+		{
+			name:    "truncated message",
+			data:    full[:len(full)-4],
+			wantErr: true,
+		},
+		{
+			name:    "truncated as_int",
+			data:    otlpRequest(otlpResourceMetrics(nil, otlpMetricMsg("x", 5, otlpIntPoint(1, 1)[:12]))),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeOTLPProtobuf(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertSeries(t, got, tt.want)
+		})
+	}
+}
+
+func TestDecodeOTLPJSON(t *testing.T) {
+
+	t0 := time.Unix(1680000000, 500)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []Series
+		wantErr bool
+	}{
+		{
+			name: "gauge, sum and attributes",
+			data: `{"resourceMetrics": [{
+				"resource": {"attributes": [
+					{"key": "service.instance.id", "value": {"stringValue": "node-1"}},
+					{"key": "process.pid", "value": {"intValue": "42"}},
+					{"key": "debug", "value": {"boolValue": true}},
+					{"key": "ratio", "value": {"doubleValue": 0.5}}
+				]},
+				"scopeMetrics": [{"scope": {"name": "celestia-node"}, "metrics": [
+					{"name": "celestia.hdr_sync_subjective_head", "unit": "1", "gauge": {"dataPoints": [{"timeUnixNano": "1680000000000000500", "asDouble": 1234}]}},
+					{"name": "celestia.pfb_count", "sum": {"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": [
+						{"timeUnixNano": "1680000000000000500", "asInt": "-9007199254740993", "attributes": [{"key": "k", "value": {"stringValue": "v"}}]}
+					]}}
+				]}]
+			}]}`,
+			want: []Series{
+				{
+					Name:    "celestia_hdr_sync_subjective_head",
+					Labels:  map[string]string{"service_instance_id": "node-1", "process_pid": "42", "debug": "true", "ratio": "0.5"},
+					Samples: []Sample{{Value: 1234, Time: t0}},
+				},
+				{
+					Name:    "celestia_pfb_count",
+					Labels:  map[string]string{"service_instance_id": "node-1", "process_pid": "42", "debug": "true", "ratio": "0.5", "k": "v"},
+					Samples: []Sample{{Value: -9007199254740993, Time: t0}},
+				},
+			},
+		},
+		{
+			name: "NaN and a histogram",
+			data: `{"resourceMetrics": [{"resource": {"attributes": [{"key": "service.instance.id", "value": {"stringValue": "node-2"}}]},
+				"scopeMetrics": [{"metrics": [
+					{"name": "latency", "histogram": {"dataPoints": [{"count": "3"}]}},
+					{"name": "celestia.das_network_head", "gauge": {"dataPoints": [{"timeUnixNano": 1680000000000000500, "asDouble": "NaN"}]}}
+				]}]}]}`,
+			want: []Series{
+				{Name: "celestia_das_network_head", Labels: map[string]string{"service_instance_id": "node-2"}, Samples: []Sample{{Value: math.NaN(), Time: t0}}},
+			},
+		},
+		{
+			name: "empty request",
+			data: `{}`,
+			want: []Series{},
+		},
+		{
+			name:    "truncated",
+			data:    `{"resourceMetrics": [{"resource": {"attributes": [`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed time",
+			data:    `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [{"name": "x", "gauge": {"dataPoints": [{"timeUnixNano": "soon"}]}}]}]}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed value",
+			data:    `{"resourceMetrics": [{"scopeMetrics": [{"metrics": [{"name": "x", "sum": {"dataPoints": [{"asInt": "1e"}]}}]}]}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeOTLPJSON([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertSeries(t, got, tt.want)
+		})
+	}
+}
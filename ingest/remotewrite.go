@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Max size of a remote-write request once decompressed
+const maxRemoteWriteSize = 128 << 20
+
+// DecodeRemoteWrite decodes a snappy compressed Prometheus remote-write request.
+// Only the fields of prometheus.WriteRequest needed here are read:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; } // unix milliseconds
+func DecodeRemoteWrite(compressed []byte) ([]Series, error) {
+
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("snappy: %v", err)
+	}
+	if size > maxRemoteWriteSize {
+		return nil, fmt.Errorf("snappy: %d bytes once decompressed, at most %d accepted", size, maxRemoteWriteSize)
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("snappy: %v", err)
+	}
+
+	series := []Series{}
+	err = walkMessage(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		s, err := decodeTimeSeries(v)
+		if err != nil {
+			return err
+		}
+		series = append(series, s)
+		return nil
+	})
+
+	return series, err
+}
+
+func decodeTimeSeries(data []byte) (Series, error) {
+
+	s := Series{Labels: map[string]string{}}
+
+	err := walkMessage(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			name, value, err := decodeLabel(v)
+			if err != nil {
+				return err
+			}
+			if name == "__name__" {
+				s.Name = value
+			} else {
+				s.Labels[name] = value
+			}
+		case 2:
+			p, err := decodeSample(v)
+			if err != nil {
+				return err
+			}
+			s.Samples = append(s.Samples, p)
+		}
+		return nil
+	})
+
+	return s, err
+}
+
+func decodeLabel(data []byte) (name, value string, err error) {
+	err = walkMessage(data, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			name = string(v)
+		case 2:
+			value = string(v)
+		}
+		return nil
+	})
+	return name, value, err
+}
+
+func decodeSample(data []byte) (Sample, error) {
+
+	var p Sample
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return p, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return p, protowire.ParseError(n)
+			}
+			p.Value = math.Float64frombits(v)
+			data = data[n:]
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return p, protowire.ParseError(n)
+			}
+			p.Time = time.UnixMilli(int64(v))
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return p, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+
+	return p, nil
+}
+
+// walkMessage calls fn for every field of a protobuf message, v is the payload of the length delimited fields
+func walkMessage(data []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var v []byte
+		if typ == protowire.BytesType {
+			v, n = protowire.ConsumeBytes(data)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package ingest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// the helpers below encode the protobuf messages field by field, the way protoc generated code does
+
+func pbBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func pbString(b []byte, num protowire.Number, v string) []byte {
+	return pbBytes(b, num, []byte(v))
+}
+
+func pbDouble(b []byte, num protowire.Number, v float64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func pbFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func pbVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func rwLabel(name, value string) []byte {
+	return pbString(pbString(nil, 1, name), 2, value)
+}
+
+func rwSample(value float64, ms int64) []byte {
+	return pbVarint(pbDouble(nil, 1, value), 2, uint64(ms))
+}
+
+// rwSeries encodes a TimeSeries, labels are name, value pairs
+func rwSeries(labels []string, samples ...[]byte) []byte {
+	var b []byte
+	for i := 0; i+1 < len(labels); i += 2 {
+		b = pbBytes(b, 1, rwLabel(labels[i], labels[i+1]))
+	}
+	for _, s := range samples {
+		b = pbBytes(b, 2, s)
+	}
+	return b
+}
+
+func rwRequest(series ...[]byte) []byte {
+	var b []byte
+	for _, s := range series {
+		b = pbBytes(b, 1, s)
+	}
+	return b
+}
+
+func TestDecodeRemoteWrite(t *testing.T) {
+
+	ts := time.UnixMilli(1680000000123)
+
+	full := rwRequest(
+		rwSeries(
+			[]string{"__name__", "celestia_hdr_sync_subjective_head", "instance", "node-1", "job", "light"},
+			rwSample(1234, ts.UnixMilli()),
+			rwSample(1235, ts.UnixMilli()+15000),
+		),
+		rwSeries(
+			[]string{"__name__", "celestia_build_info", "instance", "node-1", "semantic_version", "v0.9.1"},
+			rwSample(1, ts.UnixMilli()),
+		),
+	)
+
+	// the metadata (field 3) and an unknown field of every message must be skipped
+	withUnknown := pbBytes(nil, 3, pbString(nil, 1, "metadata"))
+	series := rwSeries([]string{"__name__", "up", "instance", "node-2"}, pbVarint(rwSample(1, ts.UnixMilli()), 9, 42))
+	series = pbFixed64(series, 15, 7)
+	withUnknown = pbBytes(withUnknown, 1, series)
+	withUnknown = pbVarint(withUnknown, 100, 1)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		raw     bool // not snappy compressed
+		want    []Series
+		wantErr bool
+	}{
+		{
+			name: "labels and samples",
+			data: full,
+			want: []Series{
+				{
+					Name:   "celestia_hdr_sync_subjective_head",
+					Labels: map[string]string{"instance": "node-1", "job": "light"},
+					Samples: []Sample{
+						{Value: 1234, Time: ts},
+						{Value: 1235, Time: ts.Add(15 * time.Second)},
+					},
+				},
+				{
+					Name:    "celestia_build_info",
+					Labels:  map[string]string{"instance": "node-1", "semantic_version": "v0.9.1"},
+					Samples: []Sample{{Value: 1, Time: ts}},
+				},
+			},
+		},
+		{
+			name: "NaN value",
+			data: rwRequest(rwSeries([]string{"__name__", "stale", "instance", "node-1"}, rwSample(math.NaN(), ts.UnixMilli()))),
+			want: []Series{
+				{Name: "stale", Labels: map[string]string{"instance": "node-1"}, Samples: []Sample{{Value: math.NaN(), Time: ts}}},
+			},
+		},
+		{
+			name: "unknown fields",
+			data: withUnknown,
+			want: []Series{
+				{Name: "up", Labels: map[string]string{"instance": "node-2"}, Samples: []Sample{{Value: 1, Time: ts}}},
+			},
+		},
+		{
+			name: "empty request",
+			data: nil,
+			want: []Series{},
+		},
+		{
+			name:    "truncated message",
+			data:    full[:len(full)-3],
+			wantErr: true,
+		},
+		{
+			name:    "truncated sample",
+			data:    rwRequest(rwSeries(nil, rwSample(1, ts.UnixMilli())[:5])),
+			wantErr: true,
+		},
+		{
+			name:    "not snappy",
+			data:    []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+			raw:     true,
+			wantErr: true,
+		},
+		{
+			name:    "decompressed size over the limit",
+			data:    protowire.AppendVarint(nil, maxRemoteWriteSize+1),
+			raw:     true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			data := tt.data
+			if !tt.raw {
+				data = snappy.Encode(nil, data)
+			}
+
+			got, err := DecodeRemoteWrite(data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertSeries(t, got, tt.want)
+		})
+	}
+}
+
+// assertSeries compares the series in order, NaN values are equal
+func assertSeries(t *testing.T, got, want []Series) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d series, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Name != w.Name {
+			t.Errorf("series %d: name %q, want %q", i, g.Name, w.Name)
+		}
+		if len(g.Labels) != len(w.Labels) {
+			t.Errorf("series %d: labels %v, want %v", i, g.Labels, w.Labels)
+		}
+		for k, v := range w.Labels {
+			if g.Labels[k] != v {
+				t.Errorf("series %d: label %q is %q, want %q", i, k, g.Labels[k], v)
+			}
+		}
+		if len(g.Samples) != len(w.Samples) {
+			t.Fatalf("series %d: samples %+v, want %+v", i, g.Samples, w.Samples)
+		}
+		for j := range w.Samples {
+			gs, ws := g.Samples[j], w.Samples[j]
+			sameValue := gs.Value == ws.Value || (math.IsNaN(gs.Value) && math.IsNaN(ws.Value))
+			if !sameValue || !gs.Time.Equal(ws.Time) {
+				t.Errorf("series %d sample %d: %+v, want %+v", i, j, gs, ws)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package ingest
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+)
+
+// Series is a metric with its labels and samples, as pushed by Prometheus or an OTLP exporter
+type Series struct {
+	Name    string
+	Labels  map[string]string
+	Samples []Sample
+}
+
+type Sample struct {
+	Value float64
+	Time  time.Time
+}
+
+// The labels the node id and the node type are read from, the first one found is used.
+// The service_* ones are the OTEL resource attributes.
+var (
+	nodeIdLabels   = []string{"exported_instance", "instance_id", "instance", "service_instance_id"}
+	nodeTypeLabels = []string{"exported_job", "job", "node_type", "service_namespace", "service_name"}
+	versionLabels  = []string{"semantic_version", "version"}
+)
+
+// fieldSetters maps the celestia node metrics, without the namespace prefix, to the sample fields
+var fieldSetters = map[string]func(n *models.CelestiaNode, v float64){
+	"das_sampled_chain_head":      func(n *models.CelestiaNode, v float64) { n.DasSampledChainHead = toUint(v) },
+	"das_network_head":            func(n *models.CelestiaNode, v float64) { n.DasNetworkHead = toUint(v) },
+	"das_sampled_headers_counter": func(n *models.CelestiaNode, v float64) { n.DasSampledHeadersCounter = toUint(v) },
+	"das_total_sampled_headers":   func(n *models.CelestiaNode, v float64) { n.DasTotalSampledHeaders = toUint(v) },
+	"das_latest_sampled_ts":       func(n *models.CelestiaNode, v float64) { n.DasLatestSampledTimestamp = toTime(v) },
+	"pfb_count":                   func(n *models.CelestiaNode, v float64) { n.PfbCount = toUint(v) },
+	"last_pfb_timestamp":          func(n *models.CelestiaNode, v float64) { n.LastPfbTimestamp = toTime(v) },
+	"hdr_sync_subjective_head":    func(n *models.CelestiaNode, v float64) { n.Head = toUint(v) },
+	"total_synced_headers":        func(n *models.CelestiaNode, v float64) { n.TotalSyncedHeaders = toUint(v) },
+	"node_start_ts":               func(n *models.CelestiaNode, v float64) { n.StartTime = toTime(v) },
+	"node_last_restart_time":      func(n *models.CelestiaNode, v float64) { n.LastRestartTime = toTime(v) },
+	"node_runtime_counter_in_seconds": func(n *models.CelestiaNode, v float64) {
+		n.NodeRuntimeCounterInSeconds = toUint(v)
+	},
+	"last_accumulative_node_runtime_counter_in_seconds": func(n *models.CelestiaNode, v float64) {
+		n.LastAccumulativeNodeRuntimeCounterInSeconds = toUint(v)
+	},
+}
+
+// build_info only carries the version in its labels
+const buildInfoMetric = "build_info"
+
+// Longest label value stored, the node id and the version columns are varchar(255)
+const maxLabelLength = 255
+
+// validLabel tells if a label value can be stored. Postgres rejects the NUL bytes, the invalid UTF-8
+// and the values longer than the column, and such a sample would fail the whole batch it is written with.
+func validLabel(v string) bool {
+	return utf8.ValidString(v) && utf8.RuneCountInString(v) <= maxLabelLength && !strings.ContainsRune(v, 0)
+}
+
+func toUint(v float64) uint64 {
+	if v <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return uint64(v)
+}
+
+// toTime reads a unix timestamp in seconds, or in milliseconds for the large values
+func toTime(v float64) time.Time {
+	if v <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return time.Time{}
+	}
+	if v > 1e12 {
+		return time.UnixMilli(int64(v))
+	}
+	return time.Unix(int64(v), int64((v-math.Floor(v))*1e9))
+}
+
+func firstLabel(labels map[string]string, names []string) string {
+	for _, name := range names {
+		if v := labels[name]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// nodeTypeFromLabels finds the node type in values like "celestia/Light" or "bridge"
+func nodeTypeFromLabels(labels map[string]string) (receiver.NodeType, bool) {
+	for _, name := range nodeTypeLabels {
+		value := strings.ToLower(labels[name])
+		switch {
+		case strings.Contains(value, "bridge"):
+			return receiver.BridgeNodeType, true
+		case strings.Contains(value, "full"):
+			return receiver.FullNodeType, true
+		case strings.Contains(value, "light"):
+			return receiver.LightNodeType, true
+		}
+	}
+	return 0, false
+}
+
+// metricBaseName strips the namespace prefix and the suffixes the exporters add to the metric names
+func metricBaseName(name, prefix string) string {
+	name = strings.TrimPrefix(name, prefix)
+	name = strings.TrimPrefix(name, "_")
+	name = strings.TrimSuffix(name, "_total")
+	return name
+}
@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The metrics nodelogger exposes about itself on /metrics
+const namespace = "nodelogger"
+
+// Registry holds the nodelogger own metrics, apart from the global registry the dependencies may use
+var Registry = prometheus.NewRegistry()
+
+var (
+	SamplesIngested = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "samples_ingested_total",
+		Help:      "Node samples written into the database, by node type.",
+	}, []string{"node_type"})
+
+	InsertErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "insert_errors_total",
+		Help:      "Batch inserts into the database which failed.",
+	})
+
+	CachedQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cached_queries_total",
+		Help:      "Cached queries by result, hit or miss.",
+	}, []string{"result"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the REST API requests, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+)
+
+// the unix nanoseconds of the latest sync, the start time until the first one
+var lastSync atomic.Int64
+
+func init() {
+	lastSync.Store(time.Now().UnixNano())
+
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SamplesIngested,
+		InsertErrors,
+		CachedQueries,
+		HTTPRequestDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "receiver_sync_age_seconds",
+			Help:      "Time since the node metrics were last received from Prometheus or pushed, or since the start.",
+		}, func() float64 {
+			return time.Since(time.Unix(0, lastSync.Load())).Seconds()
+		}),
+	)
+}
+
+// MarkSync records that the node metrics have just been received
+func MarkSync() {
+	lastSync.Store(time.Now().UnixNano())
+}
+
+// LastSync returns when the node metrics were last received, or the start time if they never were
+func LastSync() time.Time {
+	return time.Unix(0, lastSync.Load())
+}
+
+func CountIngested(batch []*models.CelestiaNode) {
+	for _, node := range batch {
+		SamplesIngested.WithLabelValues(node.NodeType.String()).Inc()
+	}
+}
+
+// RegisterGaugeFunc exposes a value read on every scrape, e.g. from a component created at runtime
+func RegisterGaugeFunc(name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// RegisterCounterFunc exposes a counter read on every scrape
+func RegisterCounterFunc(name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/celestiaorg/leaderboard-backend/receiver"
+	"github.com/celestiaorg/nodelogger/database/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestCountIngested(t *testing.T) {
+
+	light := SamplesIngested.WithLabelValues(receiver.LightNodeType.String())
+	full := SamplesIngested.WithLabelValues(receiver.FullNodeType.String())
+	lightBefore, fullBefore := testutil.ToFloat64(light), testutil.ToFloat64(full)
+
+	CountIngested([]*models.CelestiaNode{
+		{NodeId: "light-1", NodeType: receiver.LightNodeType},
+		{NodeId: "light-2", NodeType: receiver.LightNodeType},
+		{NodeId: "full-1", NodeType: receiver.FullNodeType},
+	})
+
+	if got := testutil.ToFloat64(light) - lightBefore; got != 2 {
+		t.Errorf("%v light samples counted, want 2", got)
+	}
+	if got := testutil.ToFloat64(full) - fullBefore; got != 1 {
+		t.Errorf("%v full samples counted, want 1", got)
+	}
+}
+
+func TestMarkSync(t *testing.T) {
+
+	before := time.Now()
+	MarkSync()
+	if last := LastSync(); last.Before(before) || last.After(time.Now()) {
+		t.Errorf("last sync %v, want about %v", last, before)
+	}
+	if !strings.Contains(scrape(t), "nodelogger_receiver_sync_age_seconds ") {
+		t.Error("the sync age is not exposed")
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	RegisterGaugeFunc("test_gauge", "A gauge of the test.", func() float64 { return 42 })
+	RegisterCounterFunc("test_counter_total", "A counter of the test.", func() float64 { return 7 })
+
+	body := scrape(t)
+	for _, want := range []string{
+		"nodelogger_test_gauge 42",
+		"nodelogger_test_counter_total 7",
+		"# TYPE nodelogger_test_counter_total counter",
+		"# TYPE nodelogger_insert_errors_total counter",
+		"# TYPE nodelogger_receiver_sync_age_seconds gauge",
+		"go_goroutines ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("%q is not in the metrics", want)
+		}
+	}
+}